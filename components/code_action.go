@@ -0,0 +1,172 @@
+package components
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	protobuf "github.com/emicklei/proto"
+	"github.com/lasorda/protobuf-language-server/proto/view"
+
+	"github.com/lasorda/protobuf-language-server/go-lsp/logs"
+	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
+)
+
+// CodeAction offers quick fixes for the diagnostics of the request and the
+// organize imports source action, restricted to the kinds the client asks for.
+func CodeAction(ctx context.Context, req *defines.CodeActionParams) (*[]defines.CodeAction, error) {
+	res := []defines.CodeAction{}
+	if !view.IsProtoFile(req.TextDocument.Uri) {
+		return &res, nil
+	}
+	proto_file, err := view.ViewManager.GetFile(req.TextDocument.Uri)
+	if err != nil || proto_file.Proto() == nil {
+		return &res, nil
+	}
+
+	if wantCodeAction(req.Context.Only, defines.CodeActionKindQuickFix) {
+		var unused []defines.Diagnostic
+		for _, diagnostic := range req.Context.Diagnostics {
+			switch diagnostic.Code {
+			case DiagnosticCodeUnresolvedType:
+				res = append(res, addImportActions(proto_file, diagnostic)...)
+			case DiagnosticCodeUnusedImport:
+				unused = append(unused, diagnostic)
+			}
+		}
+		if len(unused) > 0 {
+			res = append(res, removeUnusedImportActions(proto_file, unused)...)
+		}
+	}
+	if wantCodeAction(req.Context.Only, defines.CodeActionKindSourceOrganizeImports) {
+		if action := organizeImportsAction(proto_file); action != nil {
+			res = append(res, *action)
+		}
+	}
+	return &res, nil
+}
+
+// wantCodeAction tells whether kind is requested, only lists kinds or their
+// parents, e.g. source matches source.organizeImports.
+func wantCodeAction(only *[]defines.CodeActionKind, kind defines.CodeActionKind) bool {
+	if only == nil || len(*only) == 0 {
+		return true
+	}
+	for _, k := range *only {
+		if k == kind || strings.HasPrefix(string(kind), string(k)+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// addImportActions offers to import every workspace file defining the type
+// of an unresolved-type diagnostic.
+func addImportActions(proto_file view.ProtoFile, diagnostic defines.Diagnostic) (res []defines.CodeAction) {
+	name, ok := diagnostic.Data.(string)
+	if !ok {
+		// clients without diagnostic data support, read the name back
+		line := proto_file.ReadLine(int(diagnostic.Range.Start.Line))
+		if int(diagnostic.Range.End.Character) > len(line) || diagnostic.Range.Start.Line != diagnostic.Range.End.Line {
+			return nil
+		}
+		name = line[diagnostic.Range.Start.Character:diagnostic.Range.End.Character]
+	}
+
+	seen := make(map[string]bool)
+	var paths []string
+	for _, candidate := range importCandidates(proto_file, name) {
+		import_path, err := view.ViewManager.ImportPath(proto_file.URI(), candidate.URI())
+		if err != nil {
+			logs.Printf("addImportActions: %v", err)
+			continue
+		}
+		if !seen[import_path] {
+			seen[import_path] = true
+			paths = append(paths, import_path)
+		}
+	}
+	sort.Strings(paths)
+
+	for _, import_path := range paths {
+		kind := defines.CodeActionKindQuickFix
+		preferred := len(paths) == 1
+		changes := map[string][]defines.TextEdit{
+			string(proto_file.URI()): {importEdit(proto_file, import_path)},
+		}
+		res = append(res, defines.CodeAction{
+			Title:       fmt.Sprintf("Add import %q", import_path),
+			Kind:        &kind,
+			Diagnostics: &[]defines.Diagnostic{diagnostic},
+			IsPreferred: &preferred,
+			Edit:        &defines.WorkspaceEdit{Changes: &changes},
+		})
+	}
+	return res
+}
+
+// importCandidates returns the workspace files, not imported yet, which
+// define a message or enum that name resolves to from proto_file.
+func importCandidates(proto_file view.ProtoFile, name string) (res []view.ProtoFile) {
+	names := make(map[string]bool)
+	for _, fullName := range scopedNames(proto_file, name) {
+		names[fullName] = true
+	}
+
+	visible, _ := visibleFiles(proto_file)
+	imported := make(map[defines.DocumentUri]bool)
+	for _, file := range visible {
+		imported[file.URI()] = true
+	}
+	for _, file := range view.ViewManager.WorkspaceFiles() {
+		if imported[file.URI()] || file.Proto() == nil {
+			continue
+		}
+		for _, symbol := range fileSymbols(file) {
+			if (symbol.kind == defines.SymbolKindClass || symbol.kind == defines.SymbolKindEnum) && names[symbol.fullName] {
+				res = append(res, file)
+				break
+			}
+		}
+	}
+	return res
+}
+
+// importEdit inserts an import of import_path before the first import which
+// sorts after it, or after the imports, the package or the syntax.
+func importEdit(proto_file view.ProtoFile, import_path string) defines.TextEdit {
+	text := fmt.Sprintf("import %q;\n", import_path)
+	imports := proto_file.Proto().Imports()
+
+	line := -1
+	for _, i := range imports {
+		if i.ProtoImport.Filename > import_path {
+			line = i.ProtoImport.Position.Line - 1
+			if i.ProtoImport.Comment != nil {
+				// keep the comment above the import it describes
+				line = i.ProtoImport.Comment.Position.Line - 1
+			}
+			break
+		}
+	}
+	if line == -1 && len(imports) > 0 {
+		line = imports[len(imports)-1].ProtoImport.Position.Line
+	}
+	if line == -1 {
+		line = 0
+		for _, element := range proto_file.Proto().Protobuf().Elements {
+			switch v := element.(type) {
+			case *protobuf.Syntax:
+				line = v.Position.Line
+			case *protobuf.Package:
+				line = v.Position.Line
+			}
+		}
+		if line > 0 {
+			text = "\n" + text
+		}
+	}
+	pos := defines.Position{Line: uint(line)}
+	return defines.TextEdit{Range: defines.Range{Start: pos, End: pos}, NewText: text}
+}
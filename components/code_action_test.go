@@ -0,0 +1,63 @@
+package components
+
+import (
+	"testing"
+
+	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_importEdit(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		path     string
+		wantLine uint
+		wantText string
+	}{
+		{
+			name: "sorted between imports",
+			content: `syntax = "proto3";
+package foo;
+import "a/a.proto";
+// c is needed for C
+import "c/c.proto";
+`,
+			path:     "b/b.proto",
+			wantLine: 3,
+			wantText: "import \"b/b.proto\";\n",
+		},
+		{
+			name: "after the last import",
+			content: `syntax = "proto3";
+package foo;
+import "a/a.proto";
+message A {}
+`,
+			path:     "z/z.proto",
+			wantLine: 3,
+			wantText: "import \"z/z.proto\";\n",
+		},
+		{
+			name: "after the package without imports",
+			content: `syntax = "proto3";
+
+package foo;
+
+message A {}
+`,
+			path:     "a/a.proto",
+			wantLine: 3,
+			wantText: "\nimport \"a/a.proto\";\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proto_file := newMockProtoFile(t, "file:///foo.proto", tt.content)
+			edit := importEdit(proto_file, tt.path)
+			pos := defines.Position{Line: tt.wantLine}
+			require.Equal(t, defines.Range{Start: pos, End: pos}, edit.Range)
+			require.Equal(t, tt.wantText, edit.NewText)
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package components
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	protobuf "github.com/emicklei/proto"
+	"github.com/lasorda/protobuf-language-server/proto/view"
+
+	"github.com/lasorda/protobuf-language-server/go-lsp/jsonrpc"
+	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
+)
+
+// ShowReferencesCommand returns the locations counted by a code lens, the
+// client shows them in its references view.
+const ShowReferencesCommand = "protobuf.showReferences"
+
+// kinds of code lenses
+const (
+	codeLensReferences = "references"
+	codeLensUsedByRPCs = "usedByRPCs"
+	codeLensRPCs       = "rpcs"
+)
+
+// codeLensData is kept in a code lens until it is resolved, and is the
+// argument of ShowReferencesCommand.
+type codeLensData struct {
+	Uri      defines.DocumentUri `json:"uri"`
+	Position defines.Position    `json:"position"`
+	Kind     string              `json:"kind"`
+}
+
+// CodeLens puts "N references" and "used by M RPCs" above every message, "N
+// references" above every enum and "K RPCs" above services. The counts are
+// left to CodeLensResolve, so that opening a file does not search the
+// workspace.
+func CodeLens(ctx context.Context, req *defines.CodeLensParams) (result *[]defines.CodeLens, err error) {
+	if !view.IsProtoFile(req.TextDocument.Uri) {
+		return nil, nil
+	}
+	proto_file, err := view.ViewManager.GetFile(req.TextDocument.Uri)
+	if err != nil || proto_file.Proto() == nil {
+		return nil, err
+	}
+	res := codeLenses(proto_file)
+	return &res, nil
+}
+
+// codeLenses returns the unresolved code lenses of proto_file.
+func codeLenses(proto_file view.ProtoFile) []defines.CodeLens {
+	res := []defines.CodeLens{}
+	add := func(rng defines.Range, kind string) {
+		res = append(res, defines.CodeLens{Range: rng, Data: codeLensData{Uri: proto_file.URI(), Position: rng.Start, Kind: kind}})
+	}
+	for _, decl := range declarations(proto_file) {
+		switch {
+		case decl.message != nil:
+			add(decl.rng, codeLensReferences)
+			add(decl.rng, codeLensUsedByRPCs)
+		case decl.enum != nil:
+			add(decl.rng, codeLensReferences)
+		default:
+			if _, ok := decl.element.(*protobuf.Service); ok {
+				add(decl.rng, codeLensRPCs)
+			}
+		}
+	}
+	return res
+}
+
+// CodeLensResolve counts the references or rpcs of a code lens with the
+// reference index.
+func CodeLensResolve(ctx context.Context, req *defines.CodeLens) (result *defines.CodeLens, err error) {
+	var data codeLensData
+	if err := decodeCodeLensData(req.Data, &data); err != nil {
+		return nil, codeLensError(err)
+	}
+	locations, err := codeLensLocations(data)
+	if err != nil {
+		return nil, codeLensError(err)
+	}
+	arguments := []interface{}{data}
+	req.Command = &defines.Command{
+		Title:     codeLensTitle(data.Kind, len(locations)),
+		Command:   ShowReferencesCommand,
+		Arguments: &arguments,
+	}
+	return req, nil
+}
+
+// ExecuteCommand runs ShowReferencesCommand, returning the locations counted
+// by the code lens it was clicked on.
+func ExecuteCommand(ctx context.Context, req *defines.ExecuteCommandParams) (result interface{}, err error) {
+	if req.Command != ShowReferencesCommand {
+		return nil, codeLensError(fmt.Errorf("unknown command %q", req.Command))
+	}
+	if req.Arguments == nil || len(*req.Arguments) != 1 {
+		return nil, codeLensError(fmt.Errorf("%s takes the data of a code lens", req.Command))
+	}
+	var data codeLensData
+	if err := decodeCodeLensData((*req.Arguments)[0], &data); err != nil {
+		return nil, codeLensError(err)
+	}
+	locations, err := codeLensLocations(data)
+	if err != nil {
+		return nil, codeLensError(err)
+	}
+	return locations, nil
+}
+
+func codeLensTitle(kind string, count int) string {
+	plural := func(singular string) string {
+		if count == 1 {
+			return fmt.Sprintf("1 %s", singular)
+		}
+		return fmt.Sprintf("%d %ss", count, singular)
+	}
+	switch kind {
+	case codeLensUsedByRPCs:
+		return "used by " + plural("RPC")
+	case codeLensRPCs:
+		return plural("RPC")
+	default:
+		return plural("reference")
+	}
+}
+
+// codeLensLocations returns the references of the message or enum, the rpcs
+// using the message or the rpcs of the service a code lens is put on. A lens
+// left over from before an edit counts nothing.
+func codeLensLocations(data codeLensData) ([]defines.Location, error) {
+	proto_file, err := view.ViewManager.GetFile(data.Uri)
+	if err != nil {
+		return nil, err
+	}
+	if proto_file.Proto() == nil {
+		return nil, fmt.Errorf("%s cannot be parsed", data.Uri)
+	}
+	for _, decl := range declarations(proto_file) {
+		if decl.rng.Start != data.Position {
+			continue
+		}
+		var def SymbolDefinition
+		switch {
+		case decl.message != nil:
+			def = messageSymbolDefinition(proto_file, decl.message)
+		case decl.enum != nil:
+			def = enumSymbolDefinition(proto_file, decl.enum)
+		default:
+			if service, ok := decl.element.(*protobuf.Service); ok && data.Kind == codeLensRPCs {
+				return serviceRPCLocations(proto_file, service), nil
+			}
+			continue
+		}
+		return referenceLocations(workspaceReferences(definitionKeyOf(def)), data.Kind), nil
+	}
+	return []defines.Location{}, nil
+}
+
+// referenceLocations returns the locations of references, or of the rpcs
+// among them once each for codeLensUsedByRPCs.
+func referenceLocations(references []indexedReference, kind string) []defines.Location {
+	res := []defines.Location{}
+	seen := make(map[defines.Location]bool)
+	for _, reference := range references {
+		location := reference.location
+		if kind == codeLensUsedByRPCs {
+			if reference.rpc == nil {
+				continue
+			}
+			location = *reference.rpc
+		}
+		if !seen[location] {
+			seen[location] = true
+			res = append(res, location)
+		}
+	}
+	return res
+}
+
+func serviceRPCLocations(proto_file view.ProtoFile, service *protobuf.Service) []defines.Location {
+	res := []defines.Location{}
+	for _, element := range service.Elements {
+		if rpc, ok := element.(*protobuf.RPC); ok {
+			res = append(res, defines.Location{Uri: proto_file.URI(), Range: view.TokenRange(proto_file, rpc.Position, "rpc", rpc.Name)})
+		}
+	}
+	return res
+}
+
+// decodeCodeLensData decodes the data of a code lens, which the client sends
+// back as a JSON object.
+func decodeCodeLensData(value interface{}, data *codeLensData) error {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, data); err != nil || data.Uri == "" {
+		return fmt.Errorf("invalid code lens data %s", raw)
+	}
+	return nil
+}
+
+// codeLensError turns err into a response error, so that the client is answered.
+func codeLensError(err error) error {
+	return jsonrpc.ResponseError{Code: jsonrpc.InvalidParamsCode, Message: err.Error()}
+}
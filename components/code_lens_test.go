@@ -0,0 +1,107 @@
+package components
+
+import (
+	"testing"
+
+	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_codeLenses(t *testing.T) {
+	content := `syntax = "proto3";
+package foo;
+message Req {
+  message Inner {}
+  Inner inner = 1;
+  Kind kind = 2;
+}
+message Resp {
+  Req.Inner inner = 1;
+}
+enum Kind {
+  KIND_UNSPECIFIED = 0;
+}
+service Svc {
+  rpc Get(Req) returns (Resp);
+  rpc List(Req) returns (.foo.Req);
+}
+`
+	proto_file := newMockProtoFile(t, "file:///test.proto", content)
+
+	var lenses []string
+	for _, lens := range codeLenses(proto_file) {
+		lenses = append(lenses, lens.Data.(codeLensData).Kind+" "+formatRange(lens.Range))
+	}
+	require.Equal(t, []string{
+		"references 2:8-2:11", "usedByRPCs 2:8-2:11",
+		"references 3:10-3:15", "usedByRPCs 3:10-3:15",
+		"references 7:8-7:12", "usedByRPCs 7:8-7:12",
+		"references 10:5-10:9",
+		"rpcs 13:8-13:11",
+	}, lenses)
+
+	references := resolveReferences(proto_file)
+	locations := func(name, kind string) (res []string) {
+		for _, decl := range declarations(proto_file) {
+			if decl.name != name {
+				continue
+			}
+			var def SymbolDefinition
+			if decl.message != nil {
+				def = messageSymbolDefinition(proto_file, decl.message)
+			} else {
+				def = enumSymbolDefinition(proto_file, decl.enum)
+			}
+			for _, location := range referenceLocations(references[definitionKeyOf(def)], kind) {
+				res = append(res, formatRange(location.Range))
+			}
+		}
+		return res
+	}
+	// the prefix of a qualified name and a fully qualified name are references too
+	require.Equal(t, []string{"8:2-8:5", "14:10-14:13", "15:11-15:14", "15:30-15:33"}, locations("Req", codeLensReferences))
+	require.Equal(t, []string{"4:2-4:7", "8:6-8:11"}, locations("Inner", codeLensReferences))
+	require.Equal(t, []string{"5:2-5:6"}, locations("Kind", codeLensReferences))
+	// an rpc taking and returning a message counts once
+	require.Equal(t, []string{"14:6-14:9", "15:6-15:10"}, locations("Req", codeLensUsedByRPCs))
+	require.Equal(t, []string{"14:6-14:9"}, locations("Resp", codeLensUsedByRPCs))
+
+	require.Equal(t, "1 reference", codeLensTitle(codeLensReferences, 1))
+	require.Equal(t, "used by 2 RPCs", codeLensTitle(codeLensUsedByRPCs, 2))
+	require.Equal(t, "0 RPCs", codeLensTitle(codeLensRPCs, 0))
+
+	var data codeLensData
+	require.NoError(t, decodeCodeLensData(map[string]interface{}{
+		"uri": "file:///test.proto", "position": map[string]interface{}{"line": 2, "character": 8}, "kind": "rpcs",
+	}, &data))
+	require.Equal(t, codeLensData{Uri: "file:///test.proto", Position: defines.Position{Line: 2, Character: 8}, Kind: codeLensRPCs}, data)
+	require.Error(t, decodeCodeLensData("nope", &data))
+
+	// messages declared on one line are counted apart
+	proto_file = newMockProtoFile(t, "file:///test.proto", `syntax = "proto3";
+message Outer { message Inner {} }
+message Other {
+  Outer a = 1;
+  Outer.Inner b = 2;
+}
+`)
+	references = resolveReferences(proto_file)
+	require.Equal(t, []string{"3:2-3:7", "4:2-4:7"}, locations("Outer", codeLensReferences))
+	require.Equal(t, []string{"4:8-4:13"}, locations("Inner", codeLensReferences))
+
+	// closed and deleted files leave the index
+	indexedReferences(proto_file)
+	require.Contains(t, referenceIndex.files, proto_file.URI())
+	ForgetReferences(proto_file.URI())
+	require.NotContains(t, referenceIndex.files, proto_file.URI())
+
+	// a message named like the type of an rpc is not used by it
+	proto_file = newMockProtoFile(t, "file:///test.proto", `syntax = "proto3";
+message Req {}
+message Other { message Req {} }
+service S { rpc Get(Req) returns (Req); }
+`)
+	references = resolveReferences(proto_file)
+	require.Equal(t, []string{"3:16-3:19"}, locations("Req", codeLensUsedByRPCs))
+	require.Empty(t, locations("Other", codeLensUsedByRPCs))
+}
@@ -1,40 +1,31 @@
 package components
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"strings"
 	"time"
 
+	protobuf "github.com/emicklei/proto"
+	"github.com/lasorda/protobuf-language-server/proto/parser"
+	"github.com/lasorda/protobuf-language-server/proto/types"
 	"github.com/lasorda/protobuf-language-server/proto/view"
 
+	"github.com/lasorda/protobuf-language-server/go-lsp/logs"
 	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
 )
 
 var (
-	protoKeywordCompletionItems []defines.CompletionItem
-
 	kindKeyword = defines.CompletionItemKindKeyword
-	kindModule  = defines.CompletionItemKindModule
 	kindClass   = defines.CompletionItemKindClass
 	kindEnum    = defines.CompletionItemKindEnum
 
 	defaultCompletionTimeout = time.Millisecond * 500
-)
 
-func init() {
-	for _, keyword := range []string{"string", "bytes", "double", "float", "int32", "int64",
-		"uint32", "uint64", "sint32", "sint64", "fixed32", "fixed64", "sfixed32", "sfixed64", "bool",
-		"message", "enum", "service", "rpc", "optional", "repeated", "required",
-		"option", "default", "syntax", "package", "import", "extend", "oneof", "map", "reserved",
-	} {
-		insertText := keyword
-		protoKeywordCompletionItems = append(protoKeywordCompletionItems, defines.CompletionItem{
-			Kind:       &kindKeyword,
-			Label:      keyword,
-			InsertText: &insertText,
-		})
-	}
-}
+	topLevelKeywords = []string{"syntax", "package", "import", "option", "message", "enum", "service", "extend"}
+	messageKeywords  = []string{"message", "enum", "oneof", "map", "reserved", "option", "extend", "extensions"}
+)
 
 func Completion(ctx context.Context, req *defines.CompletionParams) (*[]defines.CompletionItem, error) {
 	if !view.IsProtoFile(req.TextDocument.Uri) {
@@ -47,146 +38,365 @@ func Completion(ctx context.Context, req *defines.CompletionParams) (*[]defines.
 	if err != nil || proto_file.Proto() == nil {
 		return nil, nil
 	}
-	line_str := proto_file.ReadLine(int(req.Position.Line))
-	wordWithDot := getWord(line_str, int(req.Position.Character-1), true)
+	data, _, err := proto_file.Read(ctx)
+	if err != nil {
+		logs.Printf("Completion read err: %v", err)
+		return nil, nil
+	}
+	offset := positionOffset(data, req.Position)
+	proto_file = withoutCurrentLine(proto_file, data, offset)
+	line_start := bytes.LastIndexByte(data[:offset], '\n') + 1
+	if typed, ok := importPathPrefix(data[line_start:offset]); ok {
+		res := append([]defines.CompletionItem{}, importPathCompletionItems(proto_file, typed, data[offset:], req.Position)...)
+		return &res, nil
+	}
+	if req.Context != nil && req.Context.TriggerCharacter != nil &&
+		(*req.Context.TriggerCharacter == "/" || *req.Context.TriggerCharacter == "\"") {
+		// only paths of imports are completed on these
+		return &[]defines.CompletionItem{}, nil
+	}
+	c := getCompletionContext(data[:offset])
+
+	// the typed word, dots included, is replaced by the qualified name
+	word_range := defines.Range{Start: req.Position, End: req.Position}
+	word_range.Start.Character -= uint(len(c.word))
+
+	snippets := view.ViewManager.SnippetSupport()
+	res := []defines.CompletionItem{}
+	switch c.kind {
+	case completionContextTopLevel:
+		res = append(res, keywordCompletionItems(topLevelKeywords...)...)
+		if snippets {
+			res = append(res, messageSnippet(), enumSnippet(), serviceSnippet(proto_file, data, offset))
+		}
+	case completionContextField:
+		block := c.blocks[len(c.blocks)-1].kind
+		if c.label() == "" && block != blockOneof {
+			labels := []string{"optional", "repeated"}
+			if fileSyntax(proto_file) == "proto2" {
+				labels = append(labels, "required")
+			}
+			res = append(res, keywordCompletionItems(labels...)...)
+			if block == blockMessage {
+				res = append(res, keywordCompletionItems(messageKeywords...)...)
+				if snippets {
+					res = append(res, messageSnippet(), enumSnippet(), oneofSnippet(proto_file, c.messagePath()))
+				}
+			}
+		}
+		res = append(res, scalarCompletionItems(types.BuildInProtoTypes)...)
+		res = append(res, typeCompletionItems(ctx, proto_file, scopeMessage(proto_file, c.messagePath()), false, word_range)...)
+	case completionContextMapKey:
+		res = append(res, scalarCompletionItems(types.MapKeyProtoTypes)...)
+	case completionContextMapValue:
+		res = append(res, scalarCompletionItems(types.BuildInProtoTypes)...)
+		res = append(res, typeCompletionItems(ctx, proto_file, scopeMessage(proto_file, c.messagePath()), false, word_range)...)
+	case completionContextEnum:
+		res = append(res, keywordCompletionItems("option", "reserved")...)
+	case completionContextService:
+		res = append(res, keywordCompletionItems("rpc", "option")...)
+		if snippets {
+			res = append(res, rpcSnippet(proto_file, c.blocks[len(c.blocks)-1].name, data, offset))
+		}
+	case completionContextRpc:
+		res = append(res, keywordCompletionItems("option")...)
+	case completionContextRpcType:
+		if c.statement[len(c.statement)-1] == "(" {
+			res = append(res, keywordCompletionItems("stream")...)
+		}
+		res = append(res, typeCompletionItems(ctx, proto_file, nil, true, word_range)...)
+	case completionContextFieldNumber, completionContextEnumNumber:
+		res = append(res, numberCompletionItems(proto_file, c, data[offset:], word_range)...)
+	case completionContextOptionName:
+		if c.paren {
+			// replace the ( typed and the ) the editor may have closed it with
+			word_range.Start.Character--
+			if bytes.HasPrefix(data[offset:], []byte(")")) {
+				word_range.End.Character++
+			}
+		}
+		res = append(res, optionNameCompletionItems(proto_file, c.options, c.paren, word_range)...)
+	case completionContextOptionValue, completionContextAggregateValue:
+		res = append(res, optionValueCompletionItems(proto_file, c.options, c.option, c.aggregate)...)
+	case completionContextAggregateField:
+		res = append(res, aggregateFieldCompletionItems(proto_file, c.options, c.option, c.aggregate)...)
+	}
+	return &res, nil
+}
 
-	var res []defines.CompletionItem
+// completionProtoFile is a file parsed without the line being edited.
+type completionProtoFile struct {
+	view.ProtoFile
+	proto parser.Proto
+}
 
-	// suggest imported packages that match what user has typed so far
-	//
-	// word = "google"
-	// suggest = [ google.protobuf , google.api ]
-	for _, pkg := range GetImportedPackages(ctx, proto_file) {
-		if strings.HasPrefix(*pkg.InsertText, wordWithDot) {
-			res = append(res, pkg)
-		}
+func (f *completionProtoFile) Proto() parser.Proto {
+	return f.proto
+}
+
+// withoutCurrentLine parses proto_file again with the line of offset blanked.
+// The statement being typed is incomplete, it makes the declaration around
+// it fail to parse.
+func withoutCurrentLine(proto_file view.ProtoFile, data []byte, offset int) view.ProtoFile {
+	start := bytes.LastIndexByte(data[:offset], '\n') + 1
+	end := len(data)
+	if next := bytes.IndexByte(data[offset:], '\n'); next != -1 {
+		end = offset + next
+	}
+	if len(bytes.TrimSpace(data[start:end])) == 0 {
+		return proto_file
 	}
+	blanked := make([]byte, len(data))
+	copy(blanked, data)
+	for i := start; i < end; i++ {
+		blanked[i] = ' '
+	}
+	proto, _ := parser.ParseProtoWithRecovery(proto_file.URI(), blanked)
+	return &completionProtoFile{ProtoFile: proto_file, proto: proto}
+}
 
-	if req.Context.TriggerKind != defines.CompletionTriggerKindTriggerCharacter {
-		res = append(res, protoKeywordCompletionItems...)
-		res = append(res, CompletionInThisFile(ctx, proto_file)...)
-		return &res, err
+// positionOffset returns the byte offset of pos in data.
+func positionOffset(data []byte, pos defines.Position) int {
+	offset := 0
+	for line := uint(0); line < pos.Line; line++ {
+		next := bytes.IndexByte(data[offset:], '\n')
+		if next == -1 {
+			return len(data)
+		}
+		offset += next + 1
+	}
+	end := offset + int(pos.Character)
+	if next := bytes.IndexByte(data[offset:], '\n'); next != -1 && end > offset+next {
+		end = offset + next
 	}
+	if end > len(data) {
+		end = len(data)
+	}
+	return end
+}
 
-	packageName := strings.TrimSuffix(wordWithDot, ".")
-	res = append(res, CompletionInPackage(ctx, proto_file, packageName)...)
+func keywordCompletionItems(keywords ...string) (res []defines.CompletionItem) {
+	for _, keyword := range keywords {
+		insertText := keyword
+		res = append(res, defines.CompletionItem{
+			Kind:       &kindKeyword,
+			Label:      keyword,
+			InsertText: &insertText,
+		})
+	}
+	return res
+}
 
-	return &res, nil
+func scalarCompletionItems(scalars []types.ProtoType) (res []defines.CompletionItem) {
+	for _, scalar := range scalars {
+		res = append(res, keywordCompletionItems(string(scalar))...)
+	}
+	return res
 }
 
-func GetImportedPackages(ctx context.Context, proto_file view.ProtoFile) (res []defines.CompletionItem) {
-	unique := make(map[string]struct{})
-	for _, im := range proto_file.Proto().Imports() {
-		select {
-		case <-ctx.Done():
-			return
-		default:
+func fileSyntax(proto_file view.ProtoFile) string {
+	for _, element := range proto_file.Proto().Protobuf().Elements {
+		if syntax, ok := element.(*protobuf.Syntax); ok {
+			return syntax.Value
 		}
+	}
+	return "proto2"
+}
 
-		import_uri, err := view.ViewManager.GetDocumentUriFromImportPath(proto_file.URI(), im.ProtoImport.Filename)
-		if err != nil {
-			continue
+// scopeMessage returns the innermost message of path declared in proto_file,
+// path holds message names, outermost first.
+func scopeMessage(proto_file view.ProtoFile, path []string) (scope parser.Message) {
+	messages := proto_file.Proto().Messages()
+	for _, name := range path {
+		var found parser.Message
+		for _, message := range messages {
+			if !message.Protobuf().IsExtend && message.Protobuf().Name == name {
+				found = message
+				break
+			}
 		}
-
-		file, err := view.ViewManager.GetFile(import_uri)
-		if err != nil {
-			continue
+		if found == nil {
+			break
 		}
+		scope, messages = found, found.NestedMessages()
+	}
+	return scope
+}
 
-		if len(file.Proto().Packages()) == 0 {
-			continue
-		}
+// protoType is a message or enum with its fully qualified name.
+type protoType struct {
+	fullName string
+	message  parser.Message
+	enum     parser.Enum
+}
+
+func (t protoType) protobuf() protobuf.Visitee {
+	if t.message != nil {
+		return t.message.Protobuf()
+	}
+	return t.enum.Protobuf()
+}
 
-		packageName := file.Proto().Packages()[0].ProtoPackage.Name
+func (t protoType) completionItem(insertText string, word_range defines.Range) defines.CompletionItem {
+	symbol := SymbolDefinition{Type: DefinitionTypeMessage, Message: t.message}
+	kind := &kindClass
+	if t.enum != nil {
+		symbol = SymbolDefinition{Type: DefinitionTypeEnum, Enum: t.enum}
+		kind = &kindEnum
+	}
+	return defines.CompletionItem{
+		Label:      insertText,
+		Kind:       kind,
+		Detail:     &t.fullName,
+		FilterText: &insertText,
+		TextEdit:   defines.TextEdit{Range: word_range, NewText: insertText},
+		Documentation: defines.MarkupContent{
+			Kind:  defines.MarkupKindMarkdown,
+			Value: formatHover(symbol),
+		},
+	}
+}
 
-		if _, exist := unique[packageName]; exist {
+// nestedTypes lists messages, enums and, recursively, the types nested in
+// them, with names prefixed by prefix.
+func nestedTypes(prefix string, messages []parser.Message, enums []parser.Enum) (res []protoType) {
+	for _, message := range messages {
+		if message.Protobuf().IsExtend {
 			continue
 		}
+		name := prefix + message.Protobuf().Name
+		res = append(res, protoType{fullName: name, message: message})
+		res = append(res, nestedTypes(name+".", message.NestedMessages(), message.NestedEnums())...)
+	}
+	for _, enum := range enums {
+		res = append(res, protoType{fullName: prefix + enum.Protobuf().Name, enum: enum})
+	}
+	return res
+}
 
-		unique[packageName] = struct{}{}
-		res = append(res, defines.CompletionItem{
-			Label:      packageName,
-			Kind:       &kindModule,
-			InsertText: &packageName,
-		})
-
+// messageFullName returns the fully qualified name of message declared in
+// proto_file.
+func messageFullName(proto_file view.ProtoFile, message parser.Message) string {
+	name := message.Protobuf().Name
+	for m := message.GetParentMessage(); m != nil; m = m.GetParentMessage() {
+		name = m.Protobuf().Name + "." + name
+	}
+	if pkg := filePackage(proto_file); pkg != "" {
+		name = pkg + "." + name
 	}
+	return name
+}
 
-	return res
+// fileTypes lists every message and enum declared in proto_file.
+func fileTypes(proto_file view.ProtoFile) []protoType {
+	prefix := ""
+	if pkg := filePackage(proto_file); pkg != "" {
+		prefix = pkg + "."
+	}
+	return nestedTypes(prefix, proto_file.Proto().Messages(), proto_file.Proto().Enums())
 }
 
-func CompletionInPackage(ctx context.Context, file view.ProtoFile, packageName string) (res []defines.CompletionItem) {
-	for _, im := range file.Proto().Imports() {
-		select {
-		case <-ctx.Done():
-			return
-		default:
+// typeCompletionItems offers the messages, and unless messagesOnly the enums,
+// usable as a type from scope: types nested in scope and its parents, types
+// of the file and its imports, and types of files declaring the same package
+// which are imported on selection.
+func typeCompletionItems(ctx context.Context, proto_file view.ProtoFile, scope parser.Message, messagesOnly bool, word_range defines.Range) (res []defines.CompletionItem) {
+	seen := make(map[protobuf.Visitee]bool)
+	add := func(t protoType, insertText string) *defines.CompletionItem {
+		if (messagesOnly && t.message == nil) || seen[t.protobuf()] {
+			return nil
 		}
+		seen[t.protobuf()] = true
+		res = append(res, t.completionItem(insertText, word_range))
+		return &res[len(res)-1]
+	}
 
-		import_uri, err := view.ViewManager.GetDocumentUriFromImportPath(file.URI(), im.ProtoImport.Filename)
-		if err != nil {
-			continue
+	// nearest types first
+	for m := scope; m != nil; m = m.GetParentMessage() {
+		for _, t := range nestedTypes(messageFullName(proto_file, m)+".", m.NestedMessages(), m.NestedEnums()) {
+			add(t, qualifiedTypeName(proto_file, scope, t))
 		}
+	}
 
-		imported_file, err := view.ViewManager.GetFile(import_uri)
-		if err != nil {
-			continue
+	files, _ := visibleFiles(proto_file)
+	visible := make(map[defines.DocumentUri]bool)
+	for _, file := range files {
+		visible[file.URI()] = true
+		for _, t := range fileTypes(file) {
+			select {
+			case <-ctx.Done():
+				return res
+			default:
+			}
+			if !seen[t.protobuf()] {
+				add(t, qualifiedTypeName(proto_file, scope, t))
+			}
 		}
+	}
 
-		if len(imported_file.Proto().Packages()) == 0 {
+	pkg := filePackage(proto_file)
+	if pkg == "" {
+		return res
+	}
+	for _, file := range view.ViewManager.WorkspaceFiles() {
+		if visible[file.URI()] || file.Proto() == nil || filePackage(file) != pkg {
 			continue
 		}
-
-		importedPackage := imported_file.Proto().Packages()[0].ProtoPackage.Name
-		if importedPackage == packageName {
-			res = append(res, CompletionInThisFile(ctx, imported_file)...)
+		import_path, err := view.ViewManager.ImportPath(proto_file.URI(), file.URI())
+		if err != nil {
+			continue
+		}
+		edits := []defines.TextEdit{importEdit(proto_file, import_path)}
+		for _, t := range fileTypes(file) {
+			item := add(t, strings.TrimPrefix(t.fullName, pkg+"."))
+			if item == nil {
+				continue
+			}
+			detail := fmt.Sprintf("%s, import %q", t.fullName, import_path)
+			item.Detail = &detail
+			item.AdditionalTextEdits = &edits
 		}
-
 	}
 	return res
 }
 
-func CompletionInThisFile(ctx context.Context, file view.ProtoFile) (res []defines.CompletionItem) {
-	select {
-	case <-ctx.Done():
-		return
-	default:
+// qualifiedTypeName returns the shortest name resolving to t from scope, the
+// fully qualified name with a leading dot when every other one is shadowed.
+func qualifiedTypeName(proto_file view.ProtoFile, scope parser.Message, t protoType) string {
+	same := func(defs []SymbolDefinition, v protobuf.Visitee) bool {
+		return len(defs) > 0 && ((defs[0].Message != nil && defs[0].Message.Protobuf() == v) ||
+			(defs[0].Enum != nil && defs[0].Enum.Protobuf() == v))
 	}
-
-	for _, enums := range file.Proto().Enums() {
-
-		doc := formatHover(SymbolDefinition{
-			Type: DefinitionTypeEnum,
-			Enum: enums,
-		})
-
-		res = append(res, defines.CompletionItem{
-			Label:      enums.Protobuf().Name,
-			Kind:       &kindEnum,
-			InsertText: &enums.Protobuf().Name,
-			Documentation: defines.MarkupContent{
-				Kind:  defines.MarkupKindMarkdown,
-				Value: doc,
-			},
-		})
+	resolves := func(name string) bool {
+		if !same(resolveType(proto_file, scope, name), t.protobuf()) {
+			return false
+		}
+		// protoc binds the first part to the innermost type of that name and
+		// does not look further out when the rest is not found in it
+		first, rest, qualified := strings.Cut(name, ".")
+		if !qualified {
+			return true
+		}
+		defs := resolveType(proto_file, scope, first)
+		if len(defs) == 0 {
+			// a package
+			return true
+		}
+		if defs[0].Message == nil {
+			return false
+		}
+		for _, nested := range nestedTypes("", defs[0].Message.NestedMessages(), defs[0].Message.NestedEnums()) {
+			if nested.fullName == rest && nested.protobuf() == t.protobuf() {
+				return true
+			}
+		}
+		return false
 	}
-
-	for _, message := range file.Proto().Messages() {
-
-		doc := formatHover(SymbolDefinition{
-			Type:    DefinitionTypeMessage,
-			Message: message,
-		})
-
-		res = append(res, defines.CompletionItem{
-			Label:      message.Protobuf().Name,
-			Kind:       &kindClass,
-			InsertText: &message.Protobuf().Name,
-			Documentation: defines.MarkupContent{
-				Kind:  defines.MarkupKindMarkdown,
-				Value: doc,
-			},
-		})
+	parts := strings.Split(t.fullName, ".")
+	for i := len(parts) - 1; i >= 0; i-- {
+		if name := strings.Join(parts[i:], "."); resolves(name) {
+			return name
+		}
 	}
-	return res
+	return "." + t.fullName
 }
@@ -0,0 +1,441 @@
+package components
+
+import (
+	"bytes"
+	"strings"
+)
+
+// syntactic contexts of the cursor
+const (
+	completionContextUnknown = iota
+	// top level of the file, at the start of a statement
+	completionContextTopLevel
+	// start of a field in a message, oneof or extend body, possibly after a label
+	completionContextField
+	// after map<
+	completionContextMapKey
+	// after map<key,
+	completionContextMapValue
+	// start of a statement in an enum body
+	completionContextEnum
+	// start of a statement in a service body
+	completionContextService
+	// after rpc X( or returns (, possibly after stream
+	completionContextRpcType
+	// start of a statement in an rpc body
+	completionContextRpc
+	// after the = of a field in a message or oneof body
+	completionContextFieldNumber
+	// after the = of an enum value
+	completionContextEnumNumber
+	// after option or [, the name of an option
+	completionContextOptionName
+	// after the = of an option
+	completionContextOptionValue
+	// a field name of an aggregate option value
+	completionContextAggregateField
+	// after the : of a field of an aggregate option value
+	completionContextAggregateValue
+)
+
+// block kinds of completionContext.blocks
+const (
+	blockMessage = "message"
+	blockEnum    = "enum"
+	blockService = "service"
+	blockOneof   = "oneof"
+	blockExtend  = "extend"
+	blockRpc     = "rpc"
+	blockOther   = ""
+)
+
+type completionBlock struct {
+	kind string
+	name string
+	// the statement around an aggregate value, which goes on after it
+	outer []string
+}
+
+// completionContext describes where the cursor is, as told by the text
+// before it. The text is scanned rather than the parsed model, which lags
+// behind or is broken while the user types.
+type completionContext struct {
+	kind int
+	// enclosing blocks, outermost first
+	blocks []completionBlock
+	// tokens of the current statement before the word being typed
+	statement []string
+	// the word being typed, dots included
+	word string
+	// unclosed [ of the current statement
+	inBrackets bool
+
+	// the options message of the declaration an option is completed for,
+	// e.g. FieldOptions
+	options string
+	// the option whose value is completed, e.g. deprecated or (foo.bar)
+	option string
+	// ( is typed before the word, only custom option names fit
+	paren bool
+	// fields of the aggregate option value leading to the cursor
+	aggregate []string
+}
+
+// messagePath returns the names of the messages enclosing the cursor,
+// outermost first. Fields of an extend belong to the scope around it.
+func (c *completionContext) messagePath() (res []string) {
+	for _, block := range c.blocks {
+		if block.kind == blockMessage {
+			res = append(res, block.name)
+		}
+	}
+	return res
+}
+
+// label returns the field label of the statement, or "".
+func (c *completionContext) label() string {
+	if len(c.statement) > 0 && isFieldLabel(c.statement[0]) {
+		return c.statement[0]
+	}
+	return ""
+}
+
+func isFieldLabel(word string) bool {
+	return word == "optional" || word == "repeated" || word == "required"
+}
+
+func isWordByte(ch byte) bool {
+	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (ch >= '0' && ch <= '9') || ch == '_' || ch == '.'
+}
+
+// protoTokens splits data into words and punctuation, skipping comments and
+// string literals.
+func protoTokens(data []byte) (tokens []string) {
+	for i := 0; i < len(data); {
+		ch := data[i]
+		switch {
+		case ch == '/' && i+1 < len(data) && data[i+1] == '/':
+			end := bytes.IndexByte(data[i:], '\n')
+			if end == -1 {
+				return tokens
+			}
+			i += end
+		case ch == '/' && i+1 < len(data) && data[i+1] == '*':
+			end := bytes.Index(data[i+2:], []byte("*/"))
+			if end == -1 {
+				return tokens
+			}
+			i += end + 4
+		case ch == '"' || ch == '\'':
+			j := i + 1
+			for j < len(data) && data[j] != ch && data[j] != '\n' {
+				if data[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			tokens = append(tokens, `""`)
+			i = j + 1
+		case isWordByte(ch):
+			j := i
+			for j < len(data) && isWordByte(data[j]) {
+				j++
+			}
+			tokens = append(tokens, string(data[i:j]))
+			i = j
+		case ch == ' ' || ch == '\t' || ch == '\r' || ch == '\n':
+			i++
+		default:
+			tokens = append(tokens, string(ch))
+			i++
+		}
+	}
+	return tokens
+}
+
+// getCompletionContext classifies the cursor at the end of data, the text of
+// the document up to the cursor.
+func getCompletionContext(data []byte) *completionContext {
+	c := &completionContext{}
+	if n := len(data); n > 0 && isWordByte(data[n-1]) {
+		start := n
+		for start > 0 && isWordByte(data[start-1]) {
+			start--
+		}
+		c.word = string(data[start:])
+		data = data[:start]
+	}
+
+	var statement []string
+	for _, token := range protoTokens(data) {
+		switch token {
+		case "{":
+			block := completionBlock{kind: blockOther}
+			if n := len(c.blocks); n > 0 && c.blocks[n-1].kind == blockOther && c.blocks[n-1].outer != nil {
+				// a message value nested in an aggregate
+				block.outer = append([]string{}, statement...)
+			} else if len(statement) > 0 {
+				switch statement[0] {
+				case blockMessage, blockEnum, blockService, blockOneof, blockExtend, blockRpc:
+					block.kind = statement[0]
+					if len(statement) > 1 {
+						block.name = statement[1]
+					}
+				}
+				// an aggregate option value or a field option, not a block
+				if statement[0] == "option" || countToken(statement, "[") > countToken(statement, "]") ||
+					statement[len(statement)-1] == ":" {
+					block.kind = blockOther
+					block.outer = statement
+				}
+			}
+			c.blocks = append(c.blocks, block)
+			statement = nil
+		case "}":
+			statement = nil
+			if n := len(c.blocks); n > 0 {
+				if outer := c.blocks[n-1].outer; outer != nil {
+					// the aggregate is a single value of the statement
+					statement = append(outer[:len(outer):len(outer)], "{}")
+				}
+				c.blocks = c.blocks[:n-1]
+			}
+		case ";":
+			statement = nil
+		default:
+			statement = append(statement, token)
+		}
+	}
+	c.statement = statement
+	c.inBrackets = countToken(statement, "[") > countToken(statement, "]")
+
+	if c.classifyOption() {
+		return c
+	}
+
+	block := ""
+	if len(c.blocks) > 0 {
+		block = c.blocks[len(c.blocks)-1].kind
+	}
+	switch {
+	case c.inBrackets:
+	case len(c.blocks) == 0:
+		if len(statement) == 0 {
+			c.kind = completionContextTopLevel
+		}
+	case block == blockMessage || block == blockOneof || block == blockExtend:
+		switch {
+		case block != blockExtend && isFieldNumberPosition(statement):
+			c.kind = completionContextFieldNumber
+		case len(statement) == 0, len(statement) == 1 && isFieldLabel(statement[0]) && block != blockOneof:
+			c.kind = completionContextField
+		case len(statement) == 2 && statement[0] == "map" && statement[1] == "<":
+			c.kind = completionContextMapKey
+		case len(statement) == 4 && statement[0] == "map" && statement[1] == "<" && statement[3] == ",":
+			c.kind = completionContextMapValue
+		}
+	case block == blockEnum:
+		if len(statement) == 0 {
+			c.kind = completionContextEnum
+		} else if len(statement) == 2 && statement[0] != "option" && statement[1] == "=" {
+			c.kind = completionContextEnumNumber
+		}
+	case block == blockService:
+		if len(statement) == 0 {
+			c.kind = completionContextService
+		} else if isRpcTypePosition(statement) {
+			c.kind = completionContextRpcType
+		}
+	case block == blockRpc:
+		if len(statement) == 0 {
+			c.kind = completionContextRpc
+		}
+	}
+	return c
+}
+
+// classifyOption recognizes option names, option values and aggregate
+// option values. It returns false when the cursor is outside of an option.
+func (c *completionContext) classifyOption() bool {
+	// the aggregate values around the cursor, inside the declaration block
+	i := len(c.blocks)
+	for i > 0 && c.blocks[i-1].kind == blockOther && c.blocks[i-1].outer != nil {
+		i--
+	}
+	block := ""
+	if i > 0 {
+		block = c.blocks[i-1].kind
+	}
+	aggregates := c.blocks[i:]
+	statement := c.statement
+	n := len(statement)
+
+	if len(aggregates) > 0 {
+		outer := aggregates[0].outer
+		c.options = optionsMessage(block, countToken(outer, "[") > countToken(outer, "]"))
+		c.option = optionAssigned(outer)
+		for _, aggregate := range aggregates[1:] {
+			key := aggregateKey(aggregate.outer)
+			if key == "" {
+				return true
+			}
+			c.aggregate = append(c.aggregate, key)
+		}
+		switch {
+		case c.option == "":
+		case n >= 2 && statement[n-1] == ":":
+			c.aggregate = append(c.aggregate, statement[n-2])
+			c.kind = completionContextAggregateValue
+		case n == 0 || statement[n-1] == "," || statement[n-1] == ";" || statement[n-1] == "{}" || n >= 2 && statement[n-2] == ":":
+			c.kind = completionContextAggregateField
+		}
+		return true
+	}
+
+	var option []string
+	switch {
+	case c.inBrackets:
+		c.options = optionsMessage(block, true)
+		option = statement[lastToken(statement, "[", ",")+1:]
+	case n > 0 && statement[0] == "option":
+		c.options = optionsMessage(block, false)
+		option = statement[1:]
+	default:
+		return false
+	}
+	switch {
+	case c.options == "":
+	case len(option) == 0:
+		c.kind = completionContextOptionName
+	case len(option) == 1 && option[0] == "(":
+		c.kind = completionContextOptionName
+		c.paren = true
+	case option[len(option)-1] == "=":
+		c.kind = completionContextOptionValue
+		c.option = strings.Join(option[:len(option)-1], "")
+	}
+	return true
+}
+
+// optionsMessage returns the options message of the declarations of block,
+// or of their fields and enum values when inBrackets.
+func optionsMessage(block string, inBrackets bool) string {
+	if inBrackets {
+		if block == blockEnum {
+			return enumValueOptions
+		}
+		return fieldOptions
+	}
+	switch block {
+	case "":
+		return fileOptions
+	case blockMessage:
+		return messageOptions
+	case blockEnum:
+		return enumOptions
+	case blockOneof:
+		return oneofOptions
+	case blockService:
+		return serviceOptions
+	case blockRpc:
+		return methodOptions
+	}
+	return ""
+}
+
+// optionAssigned returns the name of the option a statement ending with =
+// assigns, "" when there is none.
+func optionAssigned(statement []string) string {
+	n := len(statement)
+	if n < 2 || statement[n-1] != "=" {
+		return ""
+	}
+	start := 1
+	if countToken(statement, "[") > countToken(statement, "]") {
+		start = lastToken(statement, "[", ",") + 1
+	} else if statement[0] != "option" {
+		return ""
+	}
+	return strings.Join(statement[start:n-1], "")
+}
+
+// aggregateKey returns the field whose message value an aggregate block
+// opened after statement is: name {, name: { or name: [{.
+func aggregateKey(statement []string) string {
+	n := len(statement)
+	switch {
+	case n > 0 && isWordByte(statement[n-1][0]):
+		return statement[n-1]
+	case n > 1 && statement[n-1] == ":":
+		return statement[n-2]
+	}
+	if i := lastToken(statement, "["); i >= 2 && statement[i-1] == ":" && !containsToken(statement[i:], "]") {
+		return statement[i-2]
+	}
+	return ""
+}
+
+// lastToken returns the index of the last of tokens in statement, or -1.
+func lastToken(statement []string, tokens ...string) int {
+	for i := len(statement) - 1; i >= 0; i-- {
+		for _, token := range tokens {
+			if statement[i] == token {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func containsToken(statement []string, token string) bool {
+	return lastToken(statement, token) != -1
+}
+
+// isFieldNumberPosition tells whether a field statement expects its number
+// next, after [label] type name = or map<key, value> name =.
+func isFieldNumberPosition(statement []string) bool {
+	n := len(statement)
+	if n < 3 || statement[n-1] != "=" {
+		return false
+	}
+	switch statement[0] {
+	case "option", "reserved", "extensions":
+		return false
+	case "map":
+		return n == 8 && statement[1] == "<" && statement[3] == "," && statement[5] == ">"
+	}
+	if isFieldLabel(statement[0]) {
+		return n == 4
+	}
+	return n == 3
+}
+
+// isRpcTypePosition tells whether an rpc statement expects the request or
+// response type next.
+func isRpcTypePosition(statement []string) bool {
+	if len(statement) < 3 || statement[0] != "rpc" {
+		return false
+	}
+	last := len(statement) - 1
+	if statement[last] == "stream" {
+		last--
+	}
+	if statement[last] != "(" {
+		return false
+	}
+	// rpc Name (
+	if last == 2 {
+		return true
+	}
+	// rpc Name ( [stream] Request ) returns (
+	return statement[last-1] == "returns" && countToken(statement, "(") == 2
+}
+
+func countToken(tokens []string, token string) (n int) {
+	for _, t := range tokens {
+		if t == token {
+			n++
+		}
+	}
+	return n
+}
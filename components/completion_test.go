@@ -0,0 +1,110 @@
+package components
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_getCompletionContext(t *testing.T) {
+	header := "syntax = \"proto3\";\npackage foo;\n"
+	tests := []struct {
+		name      string
+		text      string
+		wantKind  int
+		wantPath  []string
+		wantWord  string
+		wantLabel string
+	}{
+		{"top level", "// message A {\n", completionContextTopLevel, nil, "", ""},
+		{"top level word", "mess", completionContextTopLevel, nil, "mess", ""},
+		{"after package keyword", "package ", completionContextUnknown, nil, "", ""},
+		{"message body", "message A {\n  message B {\n    enum C { C_UNSPECIFIED = 0; }\n    ", completionContextField, []string{"A", "B"}, "", ""},
+		{"after closed message", "message A {\n  message B {}\n  ", completionContextField, []string{"A"}, "", ""},
+		{"after label", "message A {\n  repeated foo.B", completionContextField, []string{"A"}, "foo.B", "repeated"},
+		{"field name", "message A {\n  string ", completionContextUnknown, []string{"A"}, "", ""},
+		{"oneof", "message A {\n  oneof o {\n    ", completionContextField, []string{"A"}, "", ""},
+		{"extend", "message A {\n  extend B {\n    ", completionContextField, []string{"A"}, "", ""},
+		{"after aggregate option", "message A {\n  option (x) = { a: 1 b { c: 2 } };\n  ", completionContextField, []string{"A"}, "", ""},
+		{"in aggregate option", "message A {\n  option (x) = { a: 1 ", completionContextAggregateField, []string{"A"}, "", ""},
+		{"in field options", "message A {\n  int32 a = 1 [(x) = { a: 1 }, ", completionContextOptionName, []string{"A"}, "", ""},
+		{"map key", "message A {\n  map<str", completionContextMapKey, []string{"A"}, "str", ""},
+		{"map value", "message A {\n  map<string, ", completionContextMapValue, []string{"A"}, "", ""},
+		{"enum body", "enum E {\n  ", completionContextEnum, nil, "", ""},
+		{"service body", "service S {\n  ", completionContextService, nil, "", ""},
+		{"rpc request", "service S {\n  rpc Get(", completionContextRpcType, nil, "", ""},
+		{"rpc stream request", "service S {\n  rpc Get(stream Re", completionContextRpcType, nil, "Re", ""},
+		{"rpc response", "service S {\n  rpc Get(Req) returns (", completionContextRpcType, nil, "", ""},
+		{"rpc after request", "service S {\n  rpc Get(Req", completionContextRpcType, nil, "Req", ""},
+		{"rpc before returns", "service S {\n  rpc Get(Req) ", completionContextUnknown, nil, "", ""},
+		{"rpc body", "service S {\n  rpc Get(Req) returns (Res) {\n    ", completionContextRpc, nil, "", ""},
+		{"field number", "message A {\n  repeated string a = ", completionContextFieldNumber, []string{"A"}, "", "repeated"},
+		{"typed field number", "message A {\n  oneof o {\n    B b = 1", completionContextFieldNumber, []string{"A"}, "1", ""},
+		{"map field number", "message A {\n  map<string, B> m = ", completionContextFieldNumber, []string{"A"}, "", ""},
+		{"option value", "message A {\n  option deprecated = ", completionContextOptionValue, []string{"A"}, "", ""},
+		{"enum value number", "message A {\n  enum E {\n    E_A = ", completionContextEnumNumber, []string{"A"}, "", ""},
+		{"string with brace", "message A {\n  option (x) = \"{\";\n  ", completionContextField, []string{"A"}, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := getCompletionContext([]byte(header + tt.text))
+			require.Equal(t, tt.wantKind, c.kind)
+			require.Equal(t, tt.wantPath, c.messagePath())
+			require.Equal(t, tt.wantWord, c.word)
+			require.Equal(t, tt.wantLabel, c.label())
+		})
+	}
+}
+
+func Test_qualifiedTypeName(t *testing.T) {
+	content := `syntax = "proto3";
+package foo.bar;
+message A {
+  message B {
+    message C {}
+  }
+  message D {
+    message B {}
+  }
+}
+enum E { E_UNSPECIFIED = 0; }
+`
+	proto_file := newMockProtoFile(t, "file:///foo.proto", content)
+	a := proto_file.Proto().Messages()[0]
+	d := a.NestedMessages()[1]
+
+	names := func(scopePath ...string) map[string]string {
+		scope := scopeMessage(proto_file, scopePath)
+		res := make(map[string]string)
+		for _, typ := range fileTypes(proto_file) {
+			res[typ.fullName] = qualifiedTypeName(proto_file, scope, typ)
+		}
+		return res
+	}
+	require.Equal(t, map[string]string{
+		"foo.bar.A":     "A",
+		"foo.bar.A.B":   "A.B",
+		"foo.bar.A.B.C": "A.B.C",
+		"foo.bar.A.D":   "A.D",
+		"foo.bar.A.D.B": "A.D.B",
+		"foo.bar.E":     "E",
+	}, names())
+	// B inside D shadows A.B
+	require.Equal(t, map[string]string{
+		"foo.bar.A":     "A",
+		"foo.bar.A.B":   "A.B",
+		"foo.bar.A.B.C": "A.B.C",
+		"foo.bar.A.D":   "D",
+		"foo.bar.A.D.B": "B",
+		"foo.bar.E":     "E",
+	}, names("A", "D"))
+	require.Equal(t, d, scopeMessage(proto_file, []string{"A", "D", "Missing"}))
+	require.Equal(t, "foo.bar.A.D.B", messageFullName(proto_file, d.NestedMessages()[0]))
+
+	var nested []string
+	for _, typ := range nestedTypes("", a.NestedMessages(), a.NestedEnums()) {
+		nested = append(nested, typ.fullName)
+	}
+	require.Equal(t, "B,B.C,D,D.B", strings.Join(nested, ","))
+}
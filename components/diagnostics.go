@@ -0,0 +1,44 @@
+package components
+
+import (
+	"fmt"
+
+	"github.com/lasorda/protobuf-language-server/proto/types"
+	"github.com/lasorda/protobuf-language-server/proto/view"
+
+	"github.com/lasorda/protobuf-language-server/go-lsp/logs"
+	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
+)
+
+const DiagnosticCodeUnresolvedType = "unresolved-type"
+
+// DiagnoseUnresolvedTypes reports every field, map value, oneof field, extend
+// and rpc type which cannot be resolved from the file or its imports.
+func DiagnoseUnresolvedTypes(proto_file view.ProtoFile) (res []defines.Diagnostic) {
+	if proto_file.Proto() == nil {
+		return nil
+	}
+	// a missing import may define anything, do not guess
+	if _, complete := visibleFiles(proto_file); !complete {
+		logs.Printf("DiagnoseUnresolvedTypes: %v has unresolved imports, skip", proto_file.URI())
+		return nil
+	}
+
+	for _, ref := range typeReferences(proto_file) {
+		if types.IsBuildInProtoType(ref.name) {
+			continue
+		}
+		if len(resolveType(proto_file, ref.scope, ref.name)) > 0 {
+			continue
+		}
+		severity := defines.DiagnosticSeverityError
+		res = append(res, defines.Diagnostic{
+			Range:    ref.Range(proto_file),
+			Severity: &severity,
+			Code:     DiagnosticCodeUnresolvedType,
+			Message:  fmt.Sprintf("unresolved type %q", ref.name),
+			Data:     ref.name,
+		})
+	}
+	return res
+}
@@ -0,0 +1,79 @@
+package components
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
+	"github.com/lasorda/protobuf-language-server/proto/parser"
+)
+
+func newMockProtoFile(t *testing.T, uri defines.DocumentUri, content string) *mockProtoFile {
+	t.Helper()
+	proto, err := parser.ParseProto(uri, bytes.NewBufferString(content))
+	if err != nil {
+		t.Fatalf("ParseProto() error = %v", err)
+	}
+	return &mockProtoFile{
+		uri:   uri,
+		data:  []byte(content),
+		proto: proto,
+	}
+}
+
+func TestDiagnoseUnresolvedTypes(t *testing.T) {
+	content := `syntax = "proto3";
+package demo.api;
+message Outer {
+  message Inner {
+    Inner self = 1;
+    Missing missing = 2;
+  }
+  Inner inner = 1;
+  Outer.Inner qualified = 2;
+  .demo.api.Outer absolute = 3;
+  api.Outer relative = 4;
+  map<string, Nope> values = 5;
+  oneof choice {
+    Status status = 6;
+    Unknown unknown = 7;
+  }
+}
+enum Status {
+  STATUS_UNSPECIFIED = 0;
+}
+service Service {
+  rpc Call(Outer) returns (foo.Bar);
+}`
+	file := newMockProtoFile(t, "file:///demo.proto", content)
+
+	got := DiagnoseUnresolvedTypes(file)
+
+	want := []struct {
+		typeName string
+		line     uint
+		start    uint
+	}{
+		{"Nope", 11, 14},
+		{"Unknown", 14, 4},
+		{"Missing", 5, 4},
+		{"foo.Bar", 21, 27},
+	}
+	if len(got) != len(want) {
+		for _, d := range got {
+			t.Logf("diagnostic: %v at %+v", d.Message, d.Range)
+		}
+		t.Fatalf("DiagnoseUnresolvedTypes() returned %d diagnostics, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		if got[i].Data != w.typeName {
+			t.Errorf("diagnostic[%d] type = %v, want %v", i, got[i].Data, w.typeName)
+		}
+		if got[i].Range.Start.Line != w.line || got[i].Range.Start.Character != w.start {
+			t.Errorf("diagnostic[%d] start = %+v, want %d:%d", i, got[i].Range.Start, w.line, w.start)
+		}
+		if got[i].Range.End.Character != w.start+uint(len(w.typeName)) {
+			t.Errorf("diagnostic[%d] end = %+v", i, got[i].Range.End)
+		}
+	}
+}
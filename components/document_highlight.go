@@ -0,0 +1,67 @@
+package components
+
+import (
+	"context"
+
+	"github.com/lasorda/protobuf-language-server/proto/view"
+
+	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
+)
+
+var (
+	highlightWrite = defines.DocumentHighlightKindWrite
+	highlightRead  = defines.DocumentHighlightKindRead
+)
+
+// DocumentHighlight highlights the symbol under the cursor in the current
+// file, its declaration as Write and every type reference resolving to it as
+// Read, so that nested types of the same name are told apart.
+func DocumentHighlight(ctx context.Context, req *defines.DocumentHighlightParams) (result *[]defines.DocumentHighlight, err error) {
+	if !view.IsProtoFile(req.TextDocument.Uri) {
+		return nil, nil
+	}
+	proto_file, err := view.ViewManager.GetFile(req.TextDocument.Uri)
+	if err != nil || proto_file.Proto() == nil {
+		return nil, err
+	}
+	target, err := symbolTargetAt(proto_file, req.Position)
+	if err != nil {
+		// like a type name in an option, resolved as go to definition does
+		symbols, _ := findSymbolDefinition(ctx, &req.TextDocumentPositionParams)
+		if len(symbols) == 0 || symbols[0].Type != DefinitionTypeMessage && symbols[0].Type != DefinitionTypeEnum {
+			return &[]defines.DocumentHighlight{}, nil
+		}
+		target = &symbolTarget{name: symbolName(symbols[0]), definition: &symbols[0]}
+	}
+	highlights := documentHighlights(proto_file, target)
+	return &highlights, nil
+}
+
+func documentHighlights(proto_file view.ProtoFile, target *symbolTarget) (res []defines.DocumentHighlight) {
+	def := target.definition
+	if def == nil {
+		// fields, enum values, services and rpcs are not referenced by type
+		return []defines.DocumentHighlight{{Range: target.rng, Kind: &highlightWrite}}
+	}
+	if defines.DocumentUri(def.Filename) == proto_file.URI() {
+		res = append(res, defines.DocumentHighlight{
+			Range: defines.Range{
+				Start: def.Position,
+				End:   defines.Position{Line: def.Position.Line, Character: def.Position.Character + uint(len(target.name))},
+			},
+			Kind: &highlightWrite,
+		})
+	}
+	for _, location := range typeReferenceLocations(proto_file, *def, target.name) {
+		res = append(res, defines.DocumentHighlight{Range: location.Range, Kind: &highlightRead})
+	}
+	return res
+}
+
+// symbolName returns the name of the message or enum of symbol.
+func symbolName(symbol SymbolDefinition) string {
+	if symbol.Type == DefinitionTypeEnum {
+		return symbol.Enum.Protobuf().Name
+	}
+	return symbol.Message.Protobuf().Name
+}
@@ -0,0 +1,50 @@
+package components
+
+import (
+	"testing"
+
+	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_documentHighlights(t *testing.T) {
+	content := `syntax = "proto3";
+package foo;
+message A {
+  message Inner {}
+  Inner a = 1;
+  string name = 2;
+}
+message B {
+  message Inner {}
+  Inner b = 1;
+  A.Inner c = 2;
+}
+`
+	proto_file := newMockProtoFile(t, "file:///test.proto", content)
+
+	type want struct {
+		line, start, end uint
+		kind             defines.DocumentHighlightKind
+	}
+	highlights := func(line, character uint) (res []want) {
+		target, err := symbolTargetAt(proto_file, defines.Position{Line: line, Character: character})
+		require.NoError(t, err)
+		for _, h := range documentHighlights(proto_file, target) {
+			res = append(res, want{h.Range.Start.Line, h.Range.Start.Character, h.Range.End.Character, *h.Kind})
+		}
+		return res
+	}
+	read, write := defines.DocumentHighlightKindRead, defines.DocumentHighlightKindWrite
+
+	// A.Inner, from its declaration and from a reference
+	aInner := []want{{3, 10, 15, write}, {4, 2, 7, read}, {10, 4, 9, read}}
+	require.Equal(t, aInner, highlights(3, 12))
+	require.Equal(t, aInner, highlights(10, 5))
+	// B.Inner is not conflated with A.Inner
+	require.Equal(t, []want{{8, 10, 15, write}, {9, 2, 7, read}}, highlights(9, 3))
+	// the A of A.Inner
+	require.Equal(t, []want{{2, 8, 9, write}, {10, 2, 3, read}}, highlights(10, 2))
+	// fields are only declared
+	require.Equal(t, []want{{5, 9, 13, write}}, highlights(5, 10))
+}
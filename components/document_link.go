@@ -0,0 +1,66 @@
+package components
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lasorda/protobuf-language-server/proto/parser"
+	"github.com/lasorda/protobuf-language-server/proto/view"
+
+	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
+)
+
+// DocumentLinks links the path of every import to the file it resolves to,
+// the tooltip tells the import root it was found under. Unresolved imports
+// are reported by DiagnoseUnresolvedImports instead.
+func DocumentLinks(ctx context.Context, req *defines.DocumentLinkParams) (result *[]defines.DocumentLink, err error) {
+	if !view.IsProtoFile(req.TextDocument.Uri) {
+		return nil, nil
+	}
+	proto_file, err := view.ViewManager.GetFile(req.TextDocument.Uri)
+	if err != nil || proto_file.Proto() == nil {
+		return nil, err
+	}
+	res := documentLinks(proto_file)
+	return &res, nil
+}
+
+func documentLinks(proto_file view.ProtoFile) []defines.DocumentLink {
+	res := []defines.DocumentLink{}
+	for _, im := range proto_file.Proto().Imports() {
+		import_uri, root, err := view.ViewManager.ResolveImport(proto_file.URI(), im.ProtoImport.Filename)
+		if err != nil {
+			continue
+		}
+		rng, ok := importPathRange(proto_file.Proto(), im)
+		if !ok {
+			continue
+		}
+		target := string(import_uri)
+		tooltip := fmt.Sprintf("%s resolved under %s", im.ProtoImport.Filename, root)
+		res = append(res, defines.DocumentLink{Range: rng, Target: &target, Tooltip: &tooltip})
+	}
+	return res
+}
+
+// importPathRange returns the range of the path of im, inside its quotes.
+func importPathRange(proto parser.Proto, im *parser.Import) (defines.Range, bool) {
+	span, ok := proto.Span(im.ProtoImport)
+	if !ok {
+		return defines.Range{}, false
+	}
+	tokens := proto.Tokens()
+	for i := span.Start; i <= span.End; i++ {
+		text := tokens[i].Text
+		if text[0] != '"' && text[0] != '\'' {
+			continue
+		}
+		rng := defines.Range{Start: tokenPosition(tokens[i].Start), End: tokenPosition(tokens[i].End)}
+		rng.Start.Character++
+		if len(text) > 1 && text[len(text)-1] == text[0] {
+			rng.End.Character--
+		}
+		return rng, true
+	}
+	return defines.Range{}, false
+}
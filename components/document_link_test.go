@@ -0,0 +1,76 @@
+package components
+
+import (
+	"os"
+	"testing"
+
+	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
+	"github.com/lasorda/protobuf-language-server/proto/view"
+	"github.com/stretchr/testify/require"
+)
+
+// mockFS finds the files of existingFiles, it lists no directories.
+type mockFS struct {
+	existingFiles []string
+}
+
+func (m *mockFS) FileExists(path string) bool {
+	for _, f := range m.existingFiles {
+		if f == path {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *mockFS) ReadDir(path string) ([]os.DirEntry, error) {
+	return nil, os.ErrNotExist
+}
+
+func Test_documentLinks(t *testing.T) {
+	view.InitFS(&mockFS{existingFiles: []string{"/project/api/foo.proto", "/project/common/types.proto"}})
+	t.Cleanup(func() { view.ViewManager = nil })
+
+	content := `syntax = "proto3";
+import "foo.proto";
+import "common/types.proto";
+import "missing.proto";
+`
+	proto_file := newMockProtoFile(t, "file:///project/api/api.proto", content)
+
+	type link struct{ rng, target, tooltip string }
+	var links []link
+	for _, l := range documentLinks(proto_file) {
+		links = append(links, link{formatRange(l.Range), *l.Target, *l.Tooltip})
+	}
+	// unresolved imports are not linked
+	require.Equal(t, []link{
+		{"1:8-1:17", "file:///project/api/foo.proto", "foo.proto resolved under /project/api"},
+		{"2:8-2:26", "file:///project/common/types.proto", "common/types.proto resolved under /project"},
+	}, links)
+
+	diagnostics := DiagnoseUnresolvedImports(proto_file)
+	require.Len(t, diagnostics, 1)
+	require.Equal(t, "3:8-3:21", formatRange(diagnostics[0].Range))
+	require.Equal(t, defines.DiagnosticSeverityError, *diagnostics[0].Severity)
+	require.Equal(t, DiagnosticCodeUnresolvedImport, diagnostics[0].Code)
+	require.Equal(t, `import "missing.proto" was not found`, diagnostics[0].Message)
+}
+
+func Test_importPathRange(t *testing.T) {
+	content := `syntax = "proto3";
+import "foo.proto";
+import public 'a/b.proto'; import "c/d.proto";
+`
+	proto_file := newMockProtoFile(t, "file:///test.proto", content)
+	var got []string
+	for _, im := range proto_file.Proto().Imports() {
+		rng, ok := importPathRange(proto_file.Proto(), im)
+		require.True(t, ok)
+		got = append(got, formatRange(rng))
+	}
+	require.Equal(t, []string{"1:8-1:17", "2:15-2:24", "2:35-2:44"}, got)
+
+	// paths without a directory are found too
+	require.Equal(t, "foo.proto", importPathRegexp.FindStringSubmatch(`import "foo.proto";`)[1])
+}
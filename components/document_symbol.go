@@ -2,6 +2,8 @@ package components
 
 import (
 	"context"
+	"fmt"
+	"text/scanner"
 
 	protobuf "github.com/emicklei/proto"
 	"github.com/lasorda/protobuf-language-server/proto/view"
@@ -10,210 +12,135 @@ import (
 	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
 )
 
-// calculateEndPosition calculates the end position for a symbol based on its elements
-func calculateEndPosition(startLine int, elements []protobuf.Visitee) uint {
-	if len(elements) == 0 {
-		return uint(startLine)
-	}
-
-	maxLine := startLine
-	for _, element := range elements {
-		switch v := element.(type) {
-		case *protobuf.NormalField:
-			if v.Position.Line > maxLine {
-				maxLine = v.Position.Line
-			}
-		case *protobuf.MapField:
-			if v.Position.Line > maxLine {
-				maxLine = v.Position.Line
-			}
-		case *protobuf.Oneof:
-			if v.Position.Line > maxLine {
-				maxLine = v.Position.Line
-			}
-		case *protobuf.EnumField:
-			if v.Position.Line > maxLine {
-				maxLine = v.Position.Line
-			}
-		case *protobuf.Enum:
-			if v.Position.Line > maxLine {
-				maxLine = v.Position.Line
-			}
-			// Recursively check nested enum elements
-			if nestedEnd := int(calculateEndPosition(v.Position.Line, v.Elements)); nestedEnd > maxLine {
-				maxLine = nestedEnd
-			}
-		case *protobuf.Message:
-			if v.Position.Line > maxLine {
-				maxLine = v.Position.Line
-			}
-			// Recursively check nested message elements
-			if nestedEnd := int(calculateEndPosition(v.Position.Line, v.Elements)); nestedEnd > maxLine {
-				maxLine = nestedEnd
-			}
-		case *protobuf.RPC:
-			if v.Position.Line > maxLine {
-				maxLine = v.Position.Line
-			}
-		case *protobuf.Option:
-			if v.Position.Line > maxLine {
-				maxLine = v.Position.Line
-			}
-		}
-	}
-
-	return uint(maxLine)
-}
-
-// calculateMessageEndPosition calculates the end position for a message considering all nested elements
-func calculateMessageEndPosition(messageProto *protobuf.Message) uint {
-	if messageProto == nil {
-		return 0
-	}
-	return calculateEndPosition(messageProto.Position.Line, messageProto.Elements)
-}
-
-// calculateEnumEndPosition calculates the end position for an enum considering all its fields
-func calculateEnumEndPosition(enumProto *protobuf.Enum) uint {
-	if enumProto == nil {
-		return 0
-	}
-	return calculateEndPosition(enumProto.Position.Line, enumProto.Elements)
-}
-
-// calculateServiceEndPosition calculates the end position for a service considering all its RPCs
-func calculateServiceEndPosition(serviceProto *protobuf.Service) uint {
-	if serviceProto == nil {
-		return 0
-	}
-	return calculateEndPosition(serviceProto.Position.Line, serviceProto.Elements)
-}
-
+// ProvideDocumentSymbol returns the symbol tree of the file: package,
+// imports, messages with their fields, oneofs, nested messages and enums,
+// enums with their values and services with their rpcs.
 func ProvideDocumentSymbol(ctx context.Context, req *defines.DocumentSymbolParams) (result *[]defines.DocumentSymbol, err error) {
 	if !view.IsProtoFile(req.TextDocument.Uri) {
 		return nil, nil
 	}
-	file, err := view.ViewManager.GetFile(req.TextDocument.Uri)
 	res := []defines.DocumentSymbol{}
+	proto_file, err := view.ViewManager.GetFile(req.TextDocument.Uri)
 	if err != nil {
 		logs.Printf("GetFile err: %v", err)
 		return &res, nil
 	}
-	for _, pack := range file.Proto().Packages() {
-		packLine := pack.ProtoPackage.Position.Line - 1
-		res = append(res, defines.DocumentSymbol{
-			Name: pack.ProtoPackage.Name,
-			Kind: defines.SymbolKindPackage,
-			SelectionRange: defines.Range{
-				Start: defines.Position{Line: uint(packLine)},
-				End:   defines.Position{Line: uint(packLine)},
-			},
-			Range: defines.Range{
-				Start: defines.Position{Line: uint(packLine)},
-				End:   defines.Position{Line: uint(packLine)},
-			},
-		})
+	if proto_file.Proto() == nil {
+		return &res, nil
 	}
-	for _, imp := range file.Proto().Imports() {
-		impLine := imp.ProtoImport.Position.Line - 1
-		res = append(res, defines.DocumentSymbol{
-			Name: imp.ProtoImport.Filename,
-			Kind: defines.SymbolKindFile,
-			SelectionRange: defines.Range{
-				Start: defines.Position{Line: uint(impLine)},
-				End:   defines.Position{Line: uint(impLine)},
-			},
-			Range: defines.Range{
-				Start: defines.Position{Line: uint(impLine)},
-				End:   defines.Position{Line: uint(impLine)},
-			},
-		})
+	res = documentSymbols(proto_file)
+	return &res, nil
+}
 
+// ProvideSymbolInformation flattens the symbol tree for clients without
+// hierarchical document symbol support, nesting is kept in the container name.
+func ProvideSymbolInformation(ctx context.Context, req *defines.DocumentSymbolParams) (result *[]defines.SymbolInformation, err error) {
+	symbols, err := ProvideDocumentSymbol(ctx, req)
+	if symbols == nil || err != nil {
+		return nil, err
 	}
-	for _, enums := range file.Proto().Enums() {
-		enumProto := enums.Protobuf()
-		startLine := enumProto.Position.Line - 1
-		endLine := int(calculateEnumEndPosition(enumProto)) - 1
-		if endLine < startLine {
-			endLine = startLine
+	res := symbolInformation(req.TextDocument.Uri, *symbols, "")
+	return &res, nil
+}
+
+func symbolInformation(document_uri defines.DocumentUri, symbols []defines.DocumentSymbol, container string) (res []defines.SymbolInformation) {
+	res = []defines.SymbolInformation{}
+	for _, symbol := range symbols {
+		information := defines.SymbolInformation{
+			Name:     symbol.Name,
+			Kind:     symbol.Kind,
+			Location: defines.Location{Uri: document_uri, Range: symbol.Range},
 		}
-		res = append(res, defines.DocumentSymbol{
-			Name: enumProto.Name,
-			Kind: defines.SymbolKindEnum,
-			SelectionRange: defines.Range{
-				Start: defines.Position{Line: uint(startLine)},
-				End:   defines.Position{Line: uint(startLine)},
-			},
-			Range: defines.Range{
-				Start: defines.Position{Line: uint(startLine)},
-				End:   defines.Position{Line: uint(endLine)},
-			},
-		})
-	}
-	for _, message := range file.Proto().Messages() {
-		message_proto := message.Protobuf()
-		startLine := message_proto.Position.Line - 1
-		endLine := int(calculateMessageEndPosition(message_proto)) - 1
-		if endLine < startLine {
-			endLine = startLine
+		if container != "" {
+			information.ContainerName = &container
 		}
-		res = append(res, defines.DocumentSymbol{
-			Name: message_proto.Name,
-			Kind: defines.SymbolKindClass,
-			SelectionRange: defines.Range{
-				Start: defines.Position{Line: uint(startLine)},
-				End:   defines.Position{Line: uint(startLine)},
-			},
-			Range: defines.Range{
-				Start: defines.Position{Line: uint(startLine)},
-				End:   defines.Position{Line: uint(endLine)},
-			},
-		})
-	}
-	for _, service := range file.Proto().Services() {
-		serviceProto := service.Protobuf()
-		startLine := serviceProto.Position.Line - 1
-		endLine := int(calculateServiceEndPosition(serviceProto)) - 1
-		if endLine < startLine {
-			endLine = startLine
+		res = append(res, information)
+		if symbol.Children != nil {
+			name := symbol.Name
+			if container != "" {
+				name = container + "." + name
+			}
+			res = append(res, symbolInformation(document_uri, *symbol.Children, name)...)
 		}
-		service_sym := defines.DocumentSymbol{
-			Name: serviceProto.Name,
-			Kind: defines.SymbolKindNamespace,
-			SelectionRange: defines.Range{
-				Start: defines.Position{Line: uint(startLine)},
-				End:   defines.Position{Line: uint(startLine)},
-			},
-			Range: defines.Range{
-				Start: defines.Position{Line: uint(startLine)},
-				End:   defines.Position{Line: uint(endLine)},
-			},
+	}
+	return res
+}
+
+func documentSymbols(proto_file view.ProtoFile) []defines.DocumentSymbol {
+	proto := proto_file.Proto()
 
-			Children: &[]defines.DocumentSymbol{},
+	symbol := func(e protobuf.Visitee, name string, kind defines.SymbolKind, pos scanner.Position, after, token, detail string) defines.DocumentSymbol {
+		res := defines.DocumentSymbol{Name: name, Kind: kind, SelectionRange: view.TokenRange(proto_file, pos, after, token)}
+		res.Range = res.SelectionRange
+		if span, ok := proto.Span(e); ok {
+			if rng := spanRange(proto.Tokens(), span); containsRange(rng, res.SelectionRange) {
+				res.Range = rng
+			}
 		}
-		child := []defines.DocumentSymbol{}
-		for _, rpc := range service.RPCs() {
-			rpcLine := rpc.ProtoRPC.Position.Line - 1
-			rpc := defines.DocumentSymbol{
-				Name: rpc.ProtoRPC.Name,
-				Kind: defines.SymbolKindMethod,
-				SelectionRange: defines.Range{
-					Start: defines.Position{Line: uint(rpcLine)},
-					End:   defines.Position{Line: uint(rpcLine)},
-				},
-				Range: defines.Range{
-					Start: defines.Position{Line: uint(rpcLine)},
-					End:   defines.Position{Line: uint(rpcLine)},
-				},
+		if detail != "" {
+			res.Detail = &detail
+		}
+		return res
+	}
+
+	var walk func(elements []protobuf.Visitee) []defines.DocumentSymbol
+	walk = func(elements []protobuf.Visitee) []defines.DocumentSymbol {
+		res := []defines.DocumentSymbol{}
+		for _, element := range elements {
+			var s defines.DocumentSymbol
+			var children []protobuf.Visitee
+			switch v := element.(type) {
+			case *protobuf.Package:
+				s = symbol(v, v.Name, defines.SymbolKindPackage, v.Position, "package", v.Name, "")
+			case *protobuf.Import:
+				s = symbol(v, v.Filename, defines.SymbolKindFile, v.Position, "import", fmt.Sprintf("%q", v.Filename), v.Kind)
+			case *protobuf.Message:
+				if v.IsExtend {
+					s = symbol(v, v.Name, defines.SymbolKindClass, v.Position, "extend", v.Name, "extend")
+				} else {
+					s = symbol(v, v.Name, defines.SymbolKindClass, v.Position, "message", v.Name, "")
+				}
+				children = v.Elements
+			case *protobuf.Enum:
+				s = symbol(v, v.Name, defines.SymbolKindEnum, v.Position, "enum", v.Name, "")
+				children = v.Elements
+			case *protobuf.EnumField:
+				s = symbol(v, v.Name, defines.SymbolKindEnumMember, v.Position, "", v.Name, fmt.Sprintf("= %d", v.Integer))
+			case *protobuf.NormalField:
+				detail := fmt.Sprintf("%s%s = %d", fieldLabel(v.Repeated, v.Optional, v.Required), v.Type, v.Sequence)
+				s = symbol(v, v.Name, defines.SymbolKindField, v.Position, v.Type, v.Name, detail)
+			case *protobuf.MapField:
+				detail := fmt.Sprintf("map<%s, %s> = %d", v.KeyType, v.Type, v.Sequence)
+				s = symbol(v, v.Name, defines.SymbolKindField, v.Position, ">", v.Name, detail)
+			case *protobuf.Oneof:
+				s = symbol(v, v.Name, defines.SymbolKindStruct, v.Position, "oneof", v.Name, "oneof")
+				children = v.Elements
+			case *protobuf.OneOfField:
+				s = symbol(v, v.Name, defines.SymbolKindField, v.Position, v.Type, v.Name, fmt.Sprintf("%s = %d", v.Type, v.Sequence))
+			case *protobuf.Service:
+				s = symbol(v, v.Name, defines.SymbolKindNamespace, v.Position, "service", v.Name, "")
+				children = v.Elements
+			case *protobuf.RPC:
+				detail := fmt.Sprintf("(%s%s) returns (%s%s)", streamPrefix(v.StreamsRequest), v.RequestType, streamPrefix(v.StreamsReturns), v.ReturnsType)
+				s = symbol(v, v.Name, defines.SymbolKindMethod, v.Position, "rpc", v.Name, detail)
+			default:
+				continue
 			}
-			child = append(child, rpc)
+			if children != nil {
+				nested := walk(children)
+				s.Children = &nested
+			}
+			res = append(res, s)
 		}
-		service_sym.Children = &child
-		res = append(res, service_sym)
+		return res
 	}
-	// for i := 0; i < len(res); i++ {
-	//     res[i].Range = res[i].SelectionRange
-	// }
-	// logs.Printf("dddddddd %+v", res)
-	return &res, nil
+	return walk(proto.Protobuf().Elements)
+}
+
+// containsRange reports whether inner lies within outer.
+func containsRange(outer, inner defines.Range) bool {
+	before := func(a, b defines.Position) bool {
+		return a.Line < b.Line || a.Line == b.Line && a.Character <= b.Character
+	}
+	return before(outer.Start, inner.Start) && before(inner.End, outer.End)
 }
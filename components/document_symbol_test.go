@@ -0,0 +1,83 @@
+package components
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_documentSymbols(t *testing.T) {
+	content := `syntax = "proto3";
+package foo;
+import "bar.proto";
+message Outer {
+  message Inner {
+    repeated string names = 1;
+  }
+  enum Kind {
+    KIND_UNSPECIFIED = 0;
+  }
+  map<string, Inner> inners = 2;
+  oneof value {
+    int32 number = 3;
+  }
+}
+service Svc {
+  rpc Call(stream Outer) returns (Outer.Inner);
+}
+`
+	proto_file := newMockProtoFile(t, "file:///test.proto", content)
+	symbols := documentSymbols(proto_file)
+
+	// name [detail] start-end selection, one line per symbol indented by depth
+	var lines []string
+	var dump func(symbols []defines.DocumentSymbol, indent string)
+	dump = func(symbols []defines.DocumentSymbol, indent string) {
+		for _, s := range symbols {
+			line := indent + s.Name
+			if s.Detail != nil {
+				line += " [" + *s.Detail + "]"
+			}
+			lines = append(lines, line+" "+formatRange(s.Range)+" "+formatRange(s.SelectionRange))
+			if s.Children != nil {
+				dump(*s.Children, indent+"  ")
+			}
+		}
+	}
+	dump(symbols, "")
+	require.Equal(t, []string{
+		"foo 1:0-1:12 1:8-1:11",
+		`bar.proto 2:0-2:19 2:7-2:18`,
+		"Outer 3:0-14:1 3:8-3:13",
+		"  Inner 4:2-6:3 4:10-4:15",
+		"    names [repeated string = 1] 5:4-5:30 5:20-5:25",
+		"  Kind 7:2-9:3 7:7-7:11",
+		"    KIND_UNSPECIFIED [= 0] 8:4-8:25 8:4-8:20",
+		"  inners [map<string, Inner> = 2] 10:2-10:32 10:21-10:27",
+		"  value [oneof] 11:2-13:3 11:8-11:13",
+		"    number [int32 = 3] 12:4-12:21 12:10-12:16",
+		"Svc 15:0-17:1 15:8-15:11",
+		"  Call [(stream Outer) returns (Outer.Inner)] 16:2-16:47 16:6-16:10",
+	}, lines)
+
+	information := symbolInformation("file:///test.proto", symbols, "")
+	var containers []string
+	for _, s := range information {
+		container := ""
+		if s.ContainerName != nil {
+			container = *s.ContainerName
+		}
+		containers = append(containers, strings.TrimPrefix(container+"/"+s.Name, "/"))
+	}
+	require.Equal(t, []string{
+		"foo", "bar.proto", "Outer", "Outer/Inner", "Outer.Inner/names", "Outer/Kind", "Outer.Kind/KIND_UNSPECIFIED",
+		"Outer/inners", "Outer/value", "Outer.value/number", "Svc", "Svc/Call",
+	}, containers)
+}
+
+func formatRange(r defines.Range) string {
+	return fmt.Sprintf("%d:%d-%d:%d", r.Start.Line, r.Start.Character, r.End.Line, r.End.Character)
+}
@@ -0,0 +1,158 @@
+package components
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	protobuf "github.com/emicklei/proto"
+	"github.com/lasorda/protobuf-language-server/proto/parser"
+	"github.com/lasorda/protobuf-language-server/proto/view"
+
+	"github.com/lasorda/protobuf-language-server/go-lsp/logs"
+	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
+)
+
+var kindValue = defines.CompletionItemKindValue
+
+// FormatOnType fills in the next free number and the semicolon when = is
+// typed after the name of a field or an enum value at the end of a line.
+func FormatOnType(ctx context.Context, req *defines.DocumentOnTypeFormattingParams) (*[]defines.TextEdit, error) {
+	if req.Ch != "=" || !view.IsProtoFile(req.TextDocument.Uri) {
+		return nil, nil
+	}
+	proto_file, err := view.ViewManager.GetFile(req.TextDocument.Uri)
+	if err != nil || proto_file.Proto() == nil {
+		return nil, nil
+	}
+	data, _, err := proto_file.Read(ctx)
+	if err != nil {
+		logs.Printf("FormatOnType read err: %v", err)
+		return nil, nil
+	}
+	edit, ok := formatOnType(proto_file, data, req.Position)
+	if !ok {
+		return nil, nil
+	}
+	return &[]defines.TextEdit{edit}, nil
+}
+
+// formatOnType returns the edit completing the field or enum value whose =
+// was typed before position.
+func formatOnType(proto_file view.ProtoFile, data []byte, position defines.Position) (defines.TextEdit, bool) {
+	offset := positionOffset(data, position)
+	line := proto_file.ReadLine(int(position.Line))
+	if int(position.Character) > len(line) || !strings.HasSuffix(line[:position.Character], "=") ||
+		strings.TrimSpace(line[position.Character:]) != "" {
+		return defines.TextEdit{}, false
+	}
+
+	c := getCompletionContext(data[:offset])
+	number, ok := nextNumber(withoutCurrentLine(proto_file, data, offset), c)
+	if !ok {
+		return defines.TextEdit{}, false
+	}
+	edit := defines.TextEdit{
+		Range:   defines.Range{Start: position, End: position},
+		NewText: fmt.Sprintf(" %d;", number),
+	}
+	if before := line[:position.Character-1]; before != "" && !strings.HasSuffix(before, " ") {
+		// name= becomes name = N;
+		edit.Range.Start.Character--
+		edit.NewText = " =" + edit.NewText
+	}
+	return edit, true
+}
+
+// numberCompletionItems offers the next free number of the field or enum
+// value being typed, and the numbers of the other values of an enum allowing
+// aliases. rest is the text after the cursor.
+func numberCompletionItems(proto_file view.ProtoFile, c *completionContext, rest []byte, word_range defines.Range) (res []defines.CompletionItem) {
+	number, ok := nextNumber(proto_file, c)
+	if !ok {
+		return nil
+	}
+	// end the statement unless something follows on the line
+	suffix := ";"
+	if end := bytes.IndexByte(rest, '\n'); end != -1 {
+		rest = rest[:end]
+	}
+	if len(bytes.TrimSpace(rest)) > 0 {
+		suffix = ""
+	}
+	add := func(n int, detail string, preferred bool) {
+		label := strconv.Itoa(n)
+		// the next free number goes first
+		sortText := "1"
+		if preferred {
+			sortText = "0"
+		}
+		res = append(res, defines.CompletionItem{
+			Label:      label,
+			Kind:       &kindValue,
+			Detail:     &detail,
+			Preselect:  &preferred,
+			SortText:   &sortText,
+			FilterText: &label,
+			TextEdit:   defines.TextEdit{Range: word_range, NewText: label + suffix},
+		})
+	}
+
+	if c.kind == completionContextFieldNumber {
+		add(number, "next free field number", true)
+		return res
+	}
+	add(number, "next free value", true)
+	enum := scopeEnum(proto_file, c)
+	if !view.EnumAllowsAlias(enum) {
+		return res
+	}
+	seen := map[int]bool{number: true}
+	for _, e := range enum.Protobuf().Elements {
+		if value, ok := e.(*protobuf.EnumField); ok && !seen[value.Integer] {
+			seen[value.Integer] = true
+			add(value.Integer, "alias of "+value.Name, false)
+		}
+	}
+	return res
+}
+
+// nextNumber returns the next free number of the message or enum c is in.
+func nextNumber(proto_file view.ProtoFile, c *completionContext) (int, bool) {
+	switch c.kind {
+	case completionContextFieldNumber:
+		path := c.messagePath()
+		scope := scopeMessage(proto_file, path)
+		if scope == nil || scope.Protobuf().Name != path[len(path)-1] {
+			return 0, false
+		}
+		number := view.NextFieldNumber(scope)
+		return number, number != 0
+	case completionContextEnumNumber:
+		if enum := scopeEnum(proto_file, c); enum != nil {
+			return view.NextEnumNumber(enum), true
+		}
+	}
+	return 0, false
+}
+
+// scopeEnum returns the enum whose body c is in.
+func scopeEnum(proto_file view.ProtoFile, c *completionContext) parser.Enum {
+	name := c.blocks[len(c.blocks)-1].name
+	enums := proto_file.Proto().Enums()
+	if path := c.messagePath(); len(path) > 0 {
+		scope := scopeMessage(proto_file, path)
+		if scope == nil || scope.Protobuf().Name != path[len(path)-1] {
+			return nil
+		}
+		enums = scope.NestedEnums()
+	}
+	for _, enum := range enums {
+		if enum.Protobuf().Name == name {
+			return enum
+		}
+	}
+	return nil
+}
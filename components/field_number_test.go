@@ -0,0 +1,46 @@
+package components
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
+	"github.com/lasorda/protobuf-language-server/proto/parser"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_formatOnType(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		// the = is typed before the cursor, marked by |
+		want string
+	}{
+		{"field", "message M {\n  int32 a = 1;\n  int32 b = 3;\n  string c =|\n}", "4:12-4:12  4;"},
+		{"field without space", "message M {\n  int32 a = 1;\n  string c=|\n}", "3:10-3:11  = 2;"},
+		{"nested message", "message M {\n  message N {\n    int32 a = 5;\n    int32 b =|\n  }\n  int32 c = 9;\n}", "4:13-4:13  6;"},
+		{"enum value", "enum E {\n  A = 0;\n  B = 2;\n  C =|\n}", "4:5-4:5  3;"},
+		{"enum allowing aliases", "enum E {\n  option allow_alias = true;\n  A = 0;\n  B = 0;\n  C =|\n}", "5:5-5:5  1;"},
+		{"text after the cursor", "message M {\n  int32 a = 1;\n  string c =| 2;\n}", ""},
+		{"outside a message", "message M {\n  int32 a = 1;\n}\noption x =|", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := "syntax = \"proto3\";\n" + tt.content
+			cursor := strings.Index(content, "|")
+			content = content[:cursor] + content[cursor+1:]
+			position := defines.Position{
+				Line:      uint(strings.Count(content[:cursor], "\n")),
+				Character: uint(cursor - strings.LastIndex(content[:cursor], "\n") - 1),
+			}
+			proto, _ := parser.ParseProtoWithRecovery("file:///test.proto", []byte(content))
+			proto_file := &mockProtoFile{uri: "file:///test.proto", data: []byte(content), proto: proto}
+
+			got := ""
+			if edit, ok := formatOnType(proto_file, []byte(content), position); ok {
+				got = formatRange(edit.Range) + " " + edit.NewText
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
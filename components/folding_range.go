@@ -0,0 +1,127 @@
+package components
+
+import (
+	"context"
+	"strings"
+
+	protobuf "github.com/emicklei/proto"
+	"github.com/lasorda/protobuf-language-server/proto/view"
+
+	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
+)
+
+var (
+	foldingComment = string(defines.FoldingRangeKindComment)
+	foldingImports = string(defines.FoldingRangeKindImports)
+)
+
+// FoldingRanges folds the blocks of messages, oneofs, enums, services and
+// rpcs, multi-line aggregate option values, comment blocks and the imports.
+func FoldingRanges(ctx context.Context, req *defines.FoldingRangeParams) (result *[]defines.FoldingRange, err error) {
+	if !view.IsProtoFile(req.TextDocument.Uri) {
+		return nil, nil
+	}
+	proto_file, err := view.ViewManager.GetFile(req.TextDocument.Uri)
+	if err != nil || proto_file.Proto() == nil {
+		return nil, err
+	}
+	data, _, err := proto_file.Read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := foldingRanges(proto_file, data)
+	return &res, nil
+}
+
+func foldingRanges(proto_file view.ProtoFile, data []byte) (res []defines.FoldingRange) {
+	spans := newSpanIndex(data)
+	tokens := spans.tokens()
+
+	// fold from the line of open to the line before close, or the line of
+	// close when something precedes it on that line
+	fold := func(open, close int) {
+		start, end := tokens[open].line, tokens[close].line
+		if close > 0 && tokens[close-1].endLine < end {
+			end--
+		}
+		if end > start {
+			res = append(res, defines.FoldingRange{StartLine: uint(start), EndLine: uint(end)})
+		}
+	}
+
+	var walk func(elements []protobuf.Visitee)
+	walk = func(elements []protobuf.Visitee) {
+		for _, element := range elements {
+			var nested []protobuf.Visitee
+			switch v := element.(type) {
+			case *protobuf.Message:
+				nested = v.Elements
+			case *protobuf.Enum:
+				nested = v.Elements
+			case *protobuf.Service:
+				nested = v.Elements
+			case *protobuf.Oneof:
+				nested = v.Elements
+			case *protobuf.RPC:
+				nested = v.Elements
+			default:
+				continue
+			}
+			if span, ok := spans.span(element); ok && span.open != -1 {
+				fold(span.open, span.end)
+			}
+			walk(nested)
+		}
+	}
+	elements := proto_file.Proto().Protobuf().Elements
+	walk(elements)
+
+	walkOptions(elements, func(o *protobuf.Option) {
+		if o.Constant.Map == nil && o.Constant.OrderedMap == nil {
+			return
+		}
+		start := spans.p.tokenAt(spans.offset(o.Position))
+		if open := spans.openBrace(start, len(tokens)-1); open != -1 {
+			fold(open, spans.closeBrace(open))
+		}
+	})
+
+	res = append(res, commentFoldingRanges(tokens)...)
+
+	if imports := proto_file.Proto().Imports(); len(imports) > 1 {
+		first, last := imports[0].ProtoImport.Position.Line-1, imports[len(imports)-1].ProtoImport.Position.Line-1
+		if last > first {
+			res = append(res, defines.FoldingRange{StartLine: uint(first), EndLine: uint(last), Kind: &foldingImports})
+		}
+	}
+	return res
+}
+
+// commentFoldingRanges folds /* */ comments over several lines and blocks of
+// consecutive // comments, leaving out comments trailing a declaration.
+func commentFoldingRanges(tokens []formatToken) (res []defines.FoldingRange) {
+	add := func(start, end int) {
+		if end > start {
+			res = append(res, defines.FoldingRange{StartLine: uint(start), EndLine: uint(end), Kind: &foldingComment})
+		}
+	}
+	blockStart, blockEnd := -1, -1
+	for i, token := range tokens {
+		ownLine := i == 0 || tokens[i-1].endLine < token.line
+		lineComment := token.comment && ownLine && strings.HasPrefix(token.text, "//")
+		if lineComment && blockEnd == token.line-1 {
+			blockEnd = token.line
+			continue
+		}
+		add(blockStart, blockEnd)
+		blockStart, blockEnd = -1, -1
+		switch {
+		case lineComment:
+			blockStart, blockEnd = token.line, token.line
+		case token.comment:
+			add(token.line, token.endLine)
+		}
+	}
+	add(blockStart, blockEnd)
+	return res
+}
@@ -0,0 +1,77 @@
+package components
+
+import (
+	"testing"
+
+	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_foldingRanges(t *testing.T) {
+	content := `syntax = "proto3";
+package foo;
+
+// A is
+// documented
+message A {
+  message B { int32 b = 1; }
+  oneof kind {
+    int32 a = 1;
+    string c = 2 [(rule) = {
+      min: 1
+    }];
+  }
+  int32 d = 3; // trailing
+  // not a block
+}
+/* a block
+   comment */
+enum E {
+  E_UNSPECIFIED = 0; }
+service S {
+  rpc Get(A) returns (A) {
+    option (http) = {
+      get: "/a"
+    };
+  }
+  rpc Put(A) returns (A);
+}
+`
+	proto_file := newMockProtoFile(t, "file:///test.proto", content)
+
+	type want struct {
+		start, end uint
+		kind       string
+	}
+	var got []want
+	for _, r := range foldingRanges(proto_file, []byte(content)) {
+		kind := ""
+		if r.Kind != nil {
+			kind = *r.Kind
+		}
+		got = append(got, want{r.StartLine, r.EndLine, kind})
+	}
+	comment := string(defines.FoldingRangeKindComment)
+	require.Equal(t, []want{
+		{5, 14, ""},  // message A
+		{7, 11, ""},  // oneof kind
+		{18, 19, ""}, // enum E, closed after a value
+		{20, 26, ""}, // service S
+		{21, 24, ""}, // rpc Get
+		{9, 10, ""},  // field option
+		{22, 23, ""}, // rpc option
+		{3, 4, comment},
+		{16, 17, comment},
+	}, got)
+}
+
+func Test_foldingRanges_imports(t *testing.T) {
+	content := `syntax = "proto3";
+import "a.proto";
+// b
+import "b.proto";
+`
+	proto_file := newMockProtoFile(t, "file:///test.proto", content)
+	imports := string(defines.FoldingRangeKindImports)
+	require.Equal(t, []defines.FoldingRange{{StartLine: 1, EndLine: 3, Kind: &imports}}, foldingRanges(proto_file, []byte(content)))
+}
@@ -1,83 +1,207 @@
 package components
 
 import (
+	"bytes"
 	"context"
 	"fmt"
-	"io"
-	"log"
-	"math"
 	"os/exec"
 	"path/filepath"
+	"strings"
+	"unicode/utf16"
+
 	"github.com/lasorda/protobuf-language-server/proto/view"
 
+	"github.com/lasorda/protobuf-language-server/go-lsp/jsonrpc"
+	"github.com/lasorda/protobuf-language-server/go-lsp/logs"
 	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
 )
 
+// maxDiffCells bounds the table used to diff the lines of a document, bigger
+// changes are replaced in one edit.
+const maxDiffCells = 1 << 22
+
 func Format(ctx context.Context, req *defines.DocumentFormattingParams) (result *[]defines.TextEdit, err error) {
 	if !view.IsProtoFile(req.TextDocument.Uri) {
 		return nil, nil
 	}
-	format := exec.Command("clang-format", fmt.Sprintf("--assume-filename=%v", filepath.Base(string(req.TextDocument.Uri))))
-	in, err := format.StdinPipe()
-	if err != nil {
-		return nil, err
-	}
-
 	proto_file, err := view.ViewManager.GetFile(req.TextDocument.Uri)
 	if err != nil {
 		return nil, err
 	}
 	data, _, _ := proto_file.Read(ctx)
-	go func() {
-		io.WriteString(in, string(data))
-		defer in.Close()
-	}()
+	before := splitLinesAfter(string(data))
+
+	if view.ViewManager.Settings().Formatter == view.FormatterClangFormat {
+		res, err := clangFormat(req.TextDocument.Uri, data)
+		if err != nil {
+			logs.Printf("Format %v: %v", req.TextDocument.Uri, err)
+			return nil, jsonrpc.ResponseError{Code: jsonrpc.InternalErrorCode, Message: err.Error()}
+		}
+		edits := lineEdits(before, splitLinesAfter(string(res)), 0)
+		return &edits, nil
+	}
 
-	res, err := format.CombinedOutput()
+	res, err := formatProto(data)
 	if err != nil {
-		log.Fatal(err)
-	}
-	return &[]defines.TextEdit{
-		{
-			Range: defines.Range{
-				Start: defines.Position{Line: 0, Character: 0},
-				End:   defines.Position{Line: math.MaxInt32, Character: math.MaxInt32},
-			},
-			NewText: string(res),
-		},
-	}, nil
+		logs.Printf("Format %v: %v", req.TextDocument.Uri, err)
+		return nil, nil
+	}
+	edits := lineEdits(before, splitLinesAfter(res.text), 0)
+	return &edits, nil
 }
 
+// FormatRange formats the top-level declarations touching the range, the
+// rest of the document is left alone.
 func FormatRange(ctx context.Context, req *defines.DocumentRangeFormattingParams) (result *[]defines.TextEdit, err error) {
 	if !view.IsProtoFile(req.TextDocument.Uri) {
 		return nil, nil
 	}
-	format := exec.Command("clang-format", fmt.Sprintf("--assume-filename=%v", filepath.Base(string(req.TextDocument.Uri))), fmt.Sprintf("--lines=%v:%v", req.Range.Start.Line+1, req.Range.End.Line+1))
-	in, err := format.StdinPipe()
-	if err != nil {
-		return nil, err
-	}
 	proto_file, err := view.ViewManager.GetFile(req.TextDocument.Uri)
 	if err != nil {
 		return nil, err
 	}
 	data, _, _ := proto_file.Read(ctx)
-	go func() {
-		io.WriteString(in, string(data))
-		defer in.Close()
-	}()
+	before := splitLinesAfter(string(data))
+
+	if view.ViewManager.Settings().Formatter == view.FormatterClangFormat {
+		res, err := clangFormat(req.TextDocument.Uri, data, fmt.Sprintf("--lines=%v:%v", req.Range.Start.Line+1, req.Range.End.Line+1))
+		if err != nil {
+			logs.Printf("FormatRange %v: %v", req.TextDocument.Uri, err)
+			return nil, jsonrpc.ResponseError{Code: jsonrpc.InternalErrorCode, Message: err.Error()}
+		}
+		edits := lineEdits(before, splitLinesAfter(string(res)), 0)
+		return &edits, nil
+	}
+
+	res, err := formatProto(data)
+	if err != nil {
+		logs.Printf("FormatRange %v: %v", req.TextDocument.Uri, err)
+		return nil, nil
+	}
+	edits := rangeEdits(before, res, req.Range)
+	return &edits, nil
+}
+
+// rangeEdits returns the edits formatting the top-level declarations which
+// overlap rng.
+func rangeEdits(before []string, res *formattedProto, rng defines.Range) []defines.TextEdit {
+	last := int(rng.End.Line)
+	if rng.End.Character == 0 && rng.End.Line > rng.Start.Line {
+		// a selection of whole lines ends at the start of the next one
+		last--
+	}
+	after := splitLinesAfter(res.text)
+	edits := []defines.TextEdit{}
+	for _, span := range res.spans {
+		if span.end < int(rng.Start.Line) || span.start > last || span.end >= len(before) {
+			continue
+		}
+		out := after[res.outLines[span.firstOut]:res.outLines[span.endOut+1]]
+		edits = append(edits, lineEdits(before[span.start:span.end+1], out, span.start)...)
+	}
+	return edits
+}
 
-	res, err := format.CombinedOutput()
+func clangFormat(document_uri defines.DocumentUri, data []byte, args ...string) ([]byte, error) {
+	args = append([]string{fmt.Sprintf("--assume-filename=%v", filepath.Base(string(document_uri)))}, args...)
+	format := exec.Command("clang-format", args...)
+	format.Stdin = bytes.NewReader(data)
+	var stderr bytes.Buffer
+	format.Stderr = &stderr
+	res, err := format.Output()
 	if err != nil {
-		log.Fatal(err)
-	}
-	return &[]defines.TextEdit{
-		{
-			Range: defines.Range{
-				Start: defines.Position{Line: 0, Character: 0},
-				End:   defines.Position{Line: math.MaxInt32, Character: math.MaxInt32},
-			},
-			NewText: string(res),
-		},
-	}, nil
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("clang-format failed: %v: %v", err, msg)
+		}
+		return nil, fmt.Errorf("clang-format failed: %v", err)
+	}
+	return res, nil
+}
+
+// splitLinesAfter splits text after every newline, the last line may lack one.
+func splitLinesAfter(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// lineEdits returns the edits replacing the changed lines of before, the
+// lines of the document starting at line first, by after.
+func lineEdits(before, after []string, first int) []defines.TextEdit {
+	prefix := 0
+	for prefix < len(before) && prefix < len(after) && before[prefix] == after[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(before)-prefix && suffix < len(after)-prefix &&
+		before[len(before)-1-suffix] == after[len(after)-1-suffix] {
+		suffix++
+	}
+	b, a := before[prefix:len(before)-suffix], after[prefix:len(after)-suffix]
+
+	// matches[i] is the line of a equal to line i of b, -1 if it changed
+	matches := make([]int, len(b))
+	for i := range matches {
+		matches[i] = -1
+	}
+	if len(b)*len(a) <= maxDiffCells {
+		// lcs[i*(m+1)+j] is the longest common subsequence of b[i:] and a[j:]
+		n, m := len(b), len(a)
+		lcs := make([]int32, (n+1)*(m+1))
+		for i := n - 1; i >= 0; i-- {
+			for j := m - 1; j >= 0; j-- {
+				switch {
+				case b[i] == a[j]:
+					lcs[i*(m+1)+j] = lcs[(i+1)*(m+1)+j+1] + 1
+				case lcs[(i+1)*(m+1)+j] >= lcs[i*(m+1)+j+1]:
+					lcs[i*(m+1)+j] = lcs[(i+1)*(m+1)+j]
+				default:
+					lcs[i*(m+1)+j] = lcs[i*(m+1)+j+1]
+				}
+			}
+		}
+		for i, j := 0, 0; i < n && j < m; {
+			switch {
+			case b[i] == a[j]:
+				matches[i] = j
+				i++
+				j++
+			case lcs[(i+1)*(m+1)+j] >= lcs[i*(m+1)+j+1]:
+				i++
+			default:
+				j++
+			}
+		}
+	}
+
+	edits := []defines.TextEdit{}
+	addEdit := func(from, to, newFrom, newTo int) {
+		if from == to && newFrom == newTo {
+			return
+		}
+		rng := defines.Range{
+			Start: defines.Position{Line: uint(first + prefix + from)},
+			End:   defines.Position{Line: uint(first + prefix + to)},
+		}
+		if prefix+to == len(before) && len(before) > 0 && !strings.HasSuffix(before[len(before)-1], "\n") {
+			// the last line of the document has no newline to end the range at
+			rng.End = defines.Position{
+				Line:      uint(first + len(before) - 1),
+				Character: uint(len(utf16.Encode([]rune(before[len(before)-1])))),
+			}
+		}
+		edits = append(edits, defines.TextEdit{Range: rng, NewText: strings.Join(a[newFrom:newTo], "")})
+	}
+	from, newFrom := 0, 0
+	for i, j := range matches {
+		if j == -1 {
+			continue
+		}
+		addEdit(from, i, newFrom, j)
+		from, newFrom = i+1, j+1
+	}
+	addEdit(from, len(b), newFrom, len(a))
+	return edits
 }
@@ -0,0 +1,199 @@
+package components
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
+	"github.com/stretchr/testify/require"
+)
+
+// applyEdits applies non-overlapping line based edits to text.
+func applyEdits(text string, edits []defines.TextEdit) string {
+	lines := splitLinesAfter(text)
+	offset := func(pos defines.Position) int {
+		res := 0
+		for i := 0; i < int(pos.Line) && i < len(lines); i++ {
+			res += len(lines[i])
+		}
+		if pos.Character > 0 {
+			line := utf16.Encode([]rune(lines[pos.Line]))
+			res += len(string(utf16.Decode(line[:pos.Character])))
+		}
+		return res
+	}
+	for i := len(edits) - 1; i >= 0; i-- {
+		start, end := offset(edits[i].Range.Start), offset(edits[i].Range.End)
+		text = text[:start] + edits[i].NewText + text[end:]
+	}
+	return text
+}
+
+func Test_formatProto(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "spacing and alignment",
+			in: `syntax='proto3';
+package  demo.api ;
+message A {
+  int32 id=1;
+  repeated   string   long_name=2 [deprecated=true];
+  map<string,int32> counts = 3;
+
+  bool ok = 4;
+}
+`,
+			want: `syntax = "proto3";
+package demo.api;
+message A {
+  int32 id                  = 1;
+  repeated string long_name = 2 [deprecated = true];
+  map<string, int32> counts = 3;
+
+  bool ok = 4;
+}
+`,
+		},
+		{
+			name: "comments are kept in place",
+			in: `// header
+
+
+syntax = "proto3"; // syntax
+// lead
+message A { // open
+    // field
+    int32 a = 1; // trailing
+    /* block
+       comment */
+    int32 b = 2;
+    // last
+} // close
+`,
+			want: `// header
+
+syntax = "proto3"; // syntax
+// lead
+message A { // open
+  // field
+  int32 a = 1; // trailing
+  /* block
+       comment */
+  int32 b = 2;
+  // last
+} // close
+`,
+		},
+		{
+			name: "nested blocks",
+			in: `syntax = "proto3";
+message A { message B {} enum E { E_UNSPECIFIED = 0; E_ONE = 1 [deprecated = true]; }
+oneof kind { string s = 10; int64 i = 11; } reserved 4, 6 to 8; reserved "foo"; }
+service S {
+rpc X(A) returns (A) {}
+rpc Y(stream A) returns (stream A) { option deprecated = true; }
+rpc Z(A) returns (A);
+}`,
+			want: `syntax = "proto3";
+message A {
+  message B {}
+  enum E {
+    E_UNSPECIFIED = 0;
+    E_ONE         = 1 [deprecated = true];
+  }
+  oneof kind {
+    string s = 10;
+    int64 i  = 11;
+  }
+  reserved 4, 6 to 8;
+  reserved "foo";
+}
+service S {
+  rpc X(A) returns (A) {}
+  rpc Y(stream A) returns (stream A) {
+    option deprecated = true;
+  }
+  rpc Z(A) returns (A);
+}
+`,
+		},
+		{
+			name: "aggregate options keep their layout",
+			in: `syntax = "proto3";
+service S {
+rpc X(A) returns (A) {
+option (google.api.http) = {
+  get: "/v1/x" // route
+  body: "*"
+};
+}
+}
+`,
+			want: `syntax = "proto3";
+service S {
+  rpc X(A) returns (A) {
+    option (google.api.http) = {
+      get: "/v1/x" // route
+      body: "*"
+    };
+  }
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := formatProto([]byte(tt.in))
+			require.NoError(t, err)
+			require.Equal(t, tt.want, res.text)
+
+			again, err := formatProto([]byte(res.text))
+			require.NoError(t, err)
+			require.Equal(t, res.text, again.text)
+
+			edits := lineEdits(splitLinesAfter(tt.in), splitLinesAfter(res.text), 0)
+			require.Equal(t, tt.want, applyEdits(tt.in, edits))
+		})
+	}
+}
+
+func Test_formatProtoErrors(t *testing.T) {
+	_, err := formatProto([]byte("syntax = \"proto3\";\nmessage A {\n  int32 a = ;\n}\n"))
+	require.Error(t, err)
+}
+
+func Test_lineEdits(t *testing.T) {
+	before := "a\nb\nc\nd\ne"
+	after := "a\nB\nc\nd\ne\n"
+	edits := lineEdits(splitLinesAfter(before), splitLinesAfter(after), 0)
+	require.Len(t, edits, 2)
+	require.Equal(t, defines.Range{Start: defines.Position{Line: 1}, End: defines.Position{Line: 2}}, edits[0].Range)
+	require.Equal(t, defines.Range{Start: defines.Position{Line: 4}, End: defines.Position{Line: 4, Character: 1}}, edits[1].Range)
+	require.Equal(t, after, applyEdits(before, edits))
+
+	require.Empty(t, lineEdits(splitLinesAfter(after), splitLinesAfter(after), 0))
+}
+
+func Test_rangeEdits(t *testing.T) {
+	in := `syntax = "proto3";
+message A {
+int32 a=1;
+}
+
+message B {
+int32 b=1;
+}
+`
+	res, err := formatProto([]byte(in))
+	require.NoError(t, err)
+
+	rng := defines.Range{Start: defines.Position{Line: 6, Character: 2}, End: defines.Position{Line: 6, Character: 4}}
+	edits := rangeEdits(splitLinesAfter(in), res, rng)
+	got := applyEdits(in, edits)
+	require.Equal(t, strings.Replace(in, "int32 b=1;", "  int32 b = 1;", 1), got)
+}
@@ -0,0 +1,626 @@
+package components
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	protobuf "github.com/emicklei/proto"
+	"github.com/lasorda/protobuf-language-server/proto/parser"
+)
+
+// formatIndent is the indentation of one nesting level.
+const formatIndent = "  "
+
+// formatToken is a token of the proto source, comments included.
+type formatToken struct {
+	text    string
+	offset  int
+	line    int
+	endLine int
+	comment bool
+}
+
+// lexProto splits data into tokens, see parser.Lex.
+func lexProto(data []byte) (tokens []formatToken) {
+	for _, token := range parser.Lex(data) {
+		tokens = append(tokens, formatToken{
+			text:    token.Text,
+			offset:  token.Start.Offset,
+			line:    token.Start.Line - 1,
+			endLine: token.End.Line - 1,
+			comment: token.Comment,
+		})
+	}
+	return tokens
+}
+
+// formatLine is a line of output. Fields and enum values keep their parts in
+// columns, so that consecutive ones can be aligned.
+type formatLine struct {
+	indent  int
+	text    string
+	columns []string
+	comment string
+	// commentEnd is the original line the trailing comment ends on
+	commentEnd int
+	group      int
+	blank      bool
+}
+
+// formatSpan maps a top-level declaration from its original lines to the
+// formatLines printed for it, both inclusive.
+type formatSpan struct {
+	start, end       int
+	firstOut, endOut int
+}
+
+type protoPrinter struct {
+	data     []byte
+	tokens   []formatToken
+	comments []formatToken
+	// nextComment is the first comment not printed yet
+	nextComment int
+	// verbatim are the offsets of option values copied from the source
+	verbatim [][2]int
+
+	lines  []formatLine
+	indent int
+	group  int
+	// lastLine is the original line of the last printed token, comments on
+	// it are printed at the end of the last output line
+	lastLine   int
+	blockStart bool
+	spans      []formatSpan
+	err        error
+}
+
+// formattedProto is the result of formatting a whole file.
+type formattedProto struct {
+	text  string
+	spans []formatSpan
+	// outLines[i] is the first output line of formatLine i
+	outLines []int
+}
+
+// formatProto pretty-prints data, keeping every comment. It refuses files
+// with syntax errors and checks that only whitespace and comment placement
+// changed.
+func formatProto(data []byte) (*formattedProto, error) {
+	crlf := bytes.Contains(data, []byte("\r\n"))
+	if crlf {
+		data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	}
+	definition, err := protobuf.NewParser(bytes.NewReader(data)).Parse()
+	if err != nil {
+		return nil, fmt.Errorf("cannot format a file with errors: %w", err)
+	}
+
+	p := &protoPrinter{data: data, tokens: lexProto(data), lastLine: -1, blockStart: true}
+	for _, token := range p.tokens {
+		if token.comment {
+			p.comments = append(p.comments, token)
+		}
+	}
+	p.printElements(definition.Elements, len(data), true)
+	if p.err != nil {
+		return nil, p.err
+	}
+	for i, span := range p.spans {
+		// a block comment after a declaration may go on for more lines
+		if end := p.lines[span.endOut].commentEnd; end > span.end {
+			p.spans[i].end = end
+		}
+	}
+
+	res := p.render()
+	if err := sameTokens(p.tokens, lexProto([]byte(res.text))); err != nil {
+		return nil, err
+	}
+	if crlf {
+		res.text = strings.ReplaceAll(res.text, "\n", "\r\n")
+	}
+	return res, nil
+}
+
+func (p *protoPrinter) fail(err error) {
+	if p.err == nil {
+		p.err = err
+	}
+}
+
+// tokenAt returns the index of the first token at or after offset.
+func (p *protoPrinter) tokenAt(offset int) int {
+	return sort.Search(len(p.tokens), func(i int) bool { return p.tokens[i].offset >= offset })
+}
+
+// nextToken returns the index of the first token after i which is not a
+// comment, len(p.tokens) if there is none.
+func (p *protoPrinter) nextToken(i int) int {
+	for i++; i < len(p.tokens) && p.tokens[i].comment; i++ {
+	}
+	return i
+}
+
+// statementEnd returns the index of the token ending the statement starting
+// at i, the ';' or for blocks the matching '}'.
+func (p *protoPrinter) statementEnd(i int, block bool) int {
+	depth := 0
+	last := i
+	for ; i < len(p.tokens); i = p.nextToken(i) {
+		last = i
+		switch p.tokens[i].text {
+		case "{", "[", "(":
+			depth++
+		case "}", "]", ")":
+			depth--
+			if depth == 0 && block && p.tokens[i].text == "}" {
+				return i
+			}
+		case ";":
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return last
+}
+
+// elementStart returns the index of the first token of e.
+func (p *protoPrinter) elementStart(e protobuf.Visitee) int {
+	pos, ok := parser.ElementPosition(e)
+	if !ok {
+		return -1
+	}
+	return p.labelStart(p.tokenAt(pos.Offset), e)
+}
+
+// labelStart moves i, the token at the position of e, back to the label of
+// a field.
+func (p *protoPrinter) labelStart(i int, e protobuf.Visitee) int {
+	switch e.(type) {
+	case *protobuf.NormalField, *protobuf.Group:
+		// the position is at the type, a label comes before it
+		for j := i - 1; j >= 0 && !p.tokens[j].comment; j-- {
+			if t := p.tokens[j].text; t != "repeated" && t != "optional" && t != "required" {
+				break
+			}
+			i = j
+		}
+	}
+	return i
+}
+
+// printElements prints the elements of a block up to the offset of its
+// closing brace.
+func (p *protoPrinter) printElements(elements []protobuf.Visitee, end int, topLevel bool) {
+	for _, e := range elements {
+		if _, ok := e.(*protobuf.Comment); ok {
+			// comments are printed from the source, in order
+			continue
+		}
+		start := p.elementStart(e)
+		if start < 0 || start >= len(p.tokens) {
+			p.fail(fmt.Errorf("cannot format %T", e))
+			return
+		}
+		startLine := p.tokens[start].line
+		p.flushComments(p.tokens[start].offset, startLine)
+		p.separate(startLine)
+		firstOut := len(p.lines)
+		p.flushComments(p.tokens[start].offset, -1)
+		p.printElement(e, start)
+		if topLevel {
+			p.spans = append(p.spans, formatSpan{start: startLine, end: p.lastLine, firstOut: firstOut, endOut: len(p.lines) - 1})
+		}
+		if p.err != nil {
+			return
+		}
+	}
+	p.flushComments(end, -1)
+}
+
+// separate keeps one blank line before something starting at line, if the
+// source has at least one.
+func (p *protoPrinter) separate(line int) {
+	if !p.blockStart && line > p.lastLine+1 {
+		p.lines = append(p.lines, formatLine{blank: true})
+		p.group++
+		p.lastLine = line - 1
+	}
+	p.blockStart = false
+}
+
+// flushComments prints the comments before offset, comments on the line of
+// the last printed token go at the end of its output line. With beforeLine
+// not -1 only comments starting before that line are printed.
+func (p *protoPrinter) flushComments(offset int, beforeLine int) {
+	for ; p.nextComment < len(p.comments); p.nextComment++ {
+		c := p.comments[p.nextComment]
+		if c.offset >= offset || beforeLine != -1 && c.line >= beforeLine {
+			return
+		}
+		if c.line == p.lastLine && len(p.lines) > 0 && !p.lines[len(p.lines)-1].blank {
+			p.addComment(c)
+		} else {
+			p.separate(c.line)
+			p.lines = append(p.lines, formatLine{indent: p.indent, text: c.text})
+			p.group++
+		}
+		p.lastLine = c.endLine
+	}
+}
+
+// skipComments handles the comments inside a printed element, up to offset.
+// Comments inside copied option values are already printed, the others are
+// moved to the end of the element.
+func (p *protoPrinter) skipComments(offset int) {
+	for ; p.nextComment < len(p.comments) && p.comments[p.nextComment].offset < offset; p.nextComment++ {
+		c := p.comments[p.nextComment]
+		if !p.isVerbatim(c.offset) {
+			p.addComment(c)
+		}
+	}
+}
+
+func (p *protoPrinter) addComment(c formatToken) {
+	last := &p.lines[len(p.lines)-1]
+	if last.comment != "" {
+		last.comment += " "
+	}
+	last.comment += c.text
+	last.commentEnd = c.endLine
+}
+
+func (p *protoPrinter) isVerbatim(offset int) bool {
+	for _, span := range p.verbatim {
+		if offset >= span[0] && offset < span[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// line prints text, ending the statement at token end.
+func (p *protoPrinter) line(text string, end int) {
+	p.lines = append(p.lines, formatLine{indent: p.indent, text: text})
+	p.group++
+	p.finish(end)
+}
+
+// alignedLine prints a field or enum value, columns are the name part, the
+// number and the options.
+func (p *protoPrinter) alignedLine(columns []string, end int) {
+	if strings.Contains(columns[2], "\n") {
+		p.line(joinColumns(columns, 0, 0), end)
+		return
+	}
+	p.lines = append(p.lines, formatLine{indent: p.indent, columns: columns, group: p.group})
+	p.finish(end)
+}
+
+func (p *protoPrinter) finish(end int) {
+	p.skipComments(p.tokens[end].offset)
+	p.lastLine = p.tokens[end].line
+}
+
+func (p *protoPrinter) printElement(e protobuf.Visitee, start int) {
+	switch v := e.(type) {
+	case *protobuf.Syntax:
+		p.line(fmt.Sprintf("syntax = %v;", quote(v.Value)), p.statementEnd(start, false))
+	case *protobuf.Edition:
+		p.line(fmt.Sprintf("edition = %v;", quote(v.Value)), p.statementEnd(start, false))
+	case *protobuf.Package:
+		p.line(fmt.Sprintf("package %v;", v.Name), p.statementEnd(start, false))
+	case *protobuf.Import:
+		kind := ""
+		if v.Kind != "" {
+			kind = v.Kind + " "
+		}
+		p.line(fmt.Sprintf("import %v%v;", kind, quote(v.Filename)), p.statementEnd(start, false))
+	case *protobuf.Option:
+		p.line(fmt.Sprintf("option %v = %v;", v.Name, p.optionValue(start, false)), p.statementEnd(start, false))
+	case *protobuf.Message:
+		keyword := "message"
+		if v.IsExtend {
+			keyword = "extend"
+		}
+		p.printBlock(fmt.Sprintf("%v %v", keyword, v.Name), v.Elements, start)
+	case *protobuf.Enum:
+		p.printBlock("enum "+v.Name, v.Elements, start)
+	case *protobuf.Service:
+		p.printBlock("service "+v.Name, v.Elements, start)
+	case *protobuf.Oneof:
+		p.printBlock("oneof "+v.Name, v.Elements, start)
+	case *protobuf.Group:
+		p.printBlock(fmt.Sprintf("%vgroup %v = %v", fieldLabel(v.Repeated, v.Optional, v.Required), v.Name, p.number(start)), v.Elements, start)
+	case *protobuf.RPC:
+		header := fmt.Sprintf("rpc %v(%v%v) returns (%v%v)", v.Name, streamPrefix(v.StreamsRequest), v.RequestType, streamPrefix(v.StreamsReturns), v.ReturnsType)
+		end := p.statementEnd(start, true)
+		if p.tokens[end].text == "}" {
+			p.printBlock(header, v.Elements, start)
+		} else {
+			p.line(header+";", end)
+		}
+	case *protobuf.NormalField:
+		name := fmt.Sprintf("%v%v %v", fieldLabel(v.Repeated, v.Optional, v.Required), v.Type, v.Name)
+		p.printField(name, v.Options, start)
+	case *protobuf.MapField:
+		p.printField(fmt.Sprintf("map<%v, %v> %v", v.KeyType, v.Type, v.Name), v.Options, start)
+	case *protobuf.OneOfField:
+		p.printField(fmt.Sprintf("%v %v", v.Type, v.Name), v.Options, start)
+	case *protobuf.EnumField:
+		var options []*protobuf.Option
+		for _, element := range v.Elements {
+			if o, ok := element.(*protobuf.Option); ok {
+				options = append(options, o)
+			}
+		}
+		p.printField(v.Name, options, start)
+	case *protobuf.Reserved:
+		var parts []string
+		for _, r := range v.Ranges {
+			parts = append(parts, r.SourceRepresentation())
+		}
+		for _, name := range v.FieldNames {
+			parts = append(parts, quote(name))
+		}
+		p.line(fmt.Sprintf("reserved %v;", strings.Join(parts, ", ")), p.statementEnd(start, false))
+	case *protobuf.Extensions:
+		var parts []string
+		for _, r := range v.Ranges {
+			parts = append(parts, r.SourceRepresentation())
+		}
+		p.line(fmt.Sprintf("extensions %v;", strings.Join(parts, ", ")), p.statementEnd(start, false))
+	default:
+		p.fail(fmt.Errorf("cannot format %T", e))
+	}
+}
+
+// printBlock prints header, the elements inside braces and the closing brace.
+func (p *protoPrinter) printBlock(header string, elements []protobuf.Visitee, start int) {
+	end := p.statementEnd(start, true)
+	open := start
+	for open < end && p.tokens[open].text != "{" {
+		open++
+	}
+	if p.tokens[end].text != "}" || p.tokens[open].text != "{" {
+		p.fail(fmt.Errorf("cannot format %v, missing braces", header))
+		return
+	}
+
+	if open+1 == end {
+		p.line(header+" {}", end)
+		return
+	}
+
+	p.line(header+" {", open)
+	p.indent++
+	p.blockStart = true
+	p.printElements(elements, p.tokens[end].offset, false)
+	p.indent--
+	p.blockStart = false
+	p.lines = append(p.lines, formatLine{indent: p.indent, text: "}"})
+	p.group++
+	p.lastLine = p.tokens[end].line
+}
+
+func (p *protoPrinter) printField(name string, options []*protobuf.Option, start int) {
+	end := p.statementEnd(start, false)
+	number := p.number(start)
+	var values []string
+	for _, o := range options {
+		values = append(values, fmt.Sprintf("%v = %v", o.Name, p.optionValue(p.tokenAt(o.Position.Offset), true)))
+	}
+	opts := ""
+	if len(values) > 0 {
+		opts = "[" + strings.Join(values, ", ") + "]"
+	}
+	p.alignedLine([]string{name, "= " + number, opts}, end)
+}
+
+// number returns the number assigned in the statement starting at token i,
+// spelled as in the source.
+func (p *protoPrinter) number(i int) string {
+	for i < len(p.tokens) && p.tokens[i].text != "=" {
+		i++
+	}
+	i = p.nextToken(i)
+	if i >= len(p.tokens) {
+		return ""
+	}
+	if p.tokens[i].text == "-" {
+		if next := p.nextToken(i); next < len(p.tokens) {
+			return "-" + p.tokens[next].text
+		}
+	}
+	return p.tokens[i].text
+}
+
+// optionValue copies the value of the option starting at token i from the
+// source, so that aggregates keep their layout and comments.
+func (p *protoPrinter) optionValue(i int, embedded bool) string {
+	for i < len(p.tokens) && p.tokens[i].text != "=" {
+		i++
+	}
+	first := p.nextToken(i)
+	if first >= len(p.tokens) {
+		p.fail(errors.New("cannot format option, missing value"))
+		return ""
+	}
+	last, depth := first, 0
+	for j := first; j < len(p.tokens); j = p.nextToken(j) {
+		t := p.tokens[j].text
+		if depth == 0 && (t == ";" || embedded && (t == "," || t == "]")) {
+			break
+		}
+		switch t {
+		case "{", "[", "(":
+			depth++
+		case "}", "]", ")":
+			depth--
+		}
+		last = j
+	}
+	from, to := p.tokens[first].offset, p.tokens[last].offset+len(p.tokens[last].text)
+	p.verbatim = append(p.verbatim, [2]int{from, to})
+	return p.reindent(string(p.data[from:to]), p.tokens[first].line)
+}
+
+// reindent shifts the continuation lines of text, which starts on line of
+// the source, by the change of indentation of that line.
+func (p *protoPrinter) reindent(text string, line int) string {
+	if !strings.Contains(text, "\n") {
+		return text
+	}
+	lineStart := lineOffset(p.data, line)
+	old := 0
+	for lineStart+old < len(p.data) && p.data[lineStart+old] == ' ' {
+		old++
+	}
+	shift := p.indent*len(formatIndent) - old
+
+	lines := strings.Split(text, "\n")
+	for i := 1; i < len(lines); i++ {
+		if shift > 0 {
+			lines[i] = strings.Repeat(" ", shift) + lines[i]
+		} else {
+			trimmed := strings.TrimLeft(lines[i], " ")
+			if removed := len(lines[i]) - len(trimmed); removed > -shift {
+				lines[i] = lines[i][-shift:]
+			} else {
+				lines[i] = trimmed
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// lineOffset returns the offset of the start of line in data.
+func lineOffset(data []byte, line int) int {
+	offset := 0
+	for ; line > 0; line-- {
+		i := bytes.IndexByte(data[offset:], '\n')
+		if i == -1 {
+			return len(data)
+		}
+		offset += i + 1
+	}
+	return offset
+}
+
+func quote(s string) string {
+	return `"` + s + `"`
+}
+
+func streamPrefix(stream bool) string {
+	if stream {
+		return "stream "
+	}
+	return ""
+}
+
+func fieldLabel(repeated, optional, required bool) string {
+	switch {
+	case repeated:
+		return "repeated "
+	case optional:
+		return "optional "
+	case required:
+		return "required "
+	}
+	return ""
+}
+
+func joinColumns(columns []string, nameWidth, numberWidth int) string {
+	text := pad(columns[0], nameWidth) + " " + columns[1]
+	if columns[2] != "" {
+		text = pad(text, nameWidth+1+numberWidth) + " " + columns[2]
+	}
+	return text + ";"
+}
+
+func pad(s string, width int) string {
+	if len(s) >= width {
+		return s
+	}
+	return s + strings.Repeat(" ", width-len(s))
+}
+
+// render aligns the numbers and options of consecutive fields and joins the
+// lines.
+func (p *protoPrinter) render() *formattedProto {
+	nameWidth := make(map[int]int)
+	numberWidth := make(map[int]int)
+	for _, l := range p.lines {
+		if l.columns == nil {
+			continue
+		}
+		if len(l.columns[0]) > nameWidth[l.group] {
+			nameWidth[l.group] = len(l.columns[0])
+		}
+		if l.columns[2] != "" && len(l.columns[1]) > numberWidth[l.group] {
+			numberWidth[l.group] = len(l.columns[1])
+		}
+	}
+
+	var sb strings.Builder
+	res := &formattedProto{spans: p.spans}
+	out := 0
+	for _, l := range p.lines {
+		res.outLines = append(res.outLines, out)
+		text := l.text
+		if l.columns != nil {
+			text = joinColumns(l.columns, nameWidth[l.group], numberWidth[l.group])
+		}
+		if l.comment != "" {
+			text += " " + l.comment
+		}
+		if !l.blank {
+			sb.WriteString(strings.Repeat(formatIndent, l.indent))
+			sb.WriteString(text)
+		}
+		sb.WriteString("\n")
+		out += strings.Count(text, "\n") + 1
+	}
+	res.outLines = append(res.outLines, out)
+	res.text = sb.String()
+	return res
+}
+
+// sameTokens checks that formatting kept the tokens and comments of the
+// source. Quotes, empty statements and whitespace in comments may change.
+func sameTokens(before, after []formatToken) error {
+	normalize := func(tokens []formatToken) (code, comments []string) {
+		previous := ";"
+		for _, token := range tokens {
+			if token.comment {
+				comments = append(comments, strings.Join(strings.Fields(token.text), " "))
+				continue
+			}
+			text := token.text
+			if text == ";" && (previous == ";" || previous == "{" || previous == "}") {
+				continue
+			}
+			previous = text
+			if len(text) >= 2 && (text[0] == '"' || text[0] == '\'') {
+				text = `"` + text[1:len(text)-1] + `"`
+			}
+			code = append(code, text)
+		}
+		return code, comments
+	}
+	beforeCode, beforeComments := normalize(before)
+	afterCode, afterComments := normalize(after)
+	for i := 0; i < len(beforeCode) || i < len(afterCode); i++ {
+		if i >= len(beforeCode) || i >= len(afterCode) || beforeCode[i] != afterCode[i] {
+			return fmt.Errorf("cannot format, the formatter would change token %v", i)
+		}
+	}
+	if strings.Join(beforeComments, "\n") != strings.Join(afterComments, "\n") {
+		return errors.New("cannot format, the formatter would change comments")
+	}
+	return nil
+}
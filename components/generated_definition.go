@@ -0,0 +1,103 @@
+package components
+
+import (
+	"context"
+	"strings"
+
+	"github.com/lasorda/protobuf-language-server/proto/view"
+
+	"github.com/lasorda/protobuf-language-server/go-lsp/logs"
+	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
+)
+
+// generatedLanguage returns the language of the generated file document_uri.
+func generatedLanguage(document_uri defines.DocumentUri) string {
+	switch {
+	case strings.HasSuffix(string(document_uri), ".go"):
+		return languageGo
+	case strings.HasSuffix(string(document_uri), ".py"):
+		return languagePython
+	}
+	return languageCpp
+}
+
+// JumpGeneratedDefine jumps from a name in generated code, like GetUserId,
+// set_user_id or user_pb2.User, to its declaration in the proto file the
+// code is generated from.
+func JumpGeneratedDefine(ctx context.Context, req *defines.TextDocumentPositionParams) (result []SymbolDefinition, err error) {
+	proto_uri, err := view.ViewManager.GeneratedProtoFile(req.TextDocument.Uri)
+	if err != nil {
+		return nil, err
+	}
+	proto_file, err := view.ViewManager.GetFile(proto_uri)
+	if err != nil {
+		return nil, err
+	}
+	line := view.ViewManager.GetGeneratedLine(req.TextDocument.Uri, int(req.Position.Line))
+	word := getWord(line, int(req.Position.Character), false)
+	logs.Printf("line %v, word %v", line, word)
+	if res := generatedDeclarations(generatedFileOf(proto_file), generatedLanguage(req.TextDocument.Uri), word); len(res) > 0 {
+		return res, nil
+	}
+	res, err := searchType(proto_file, word)
+	// better than nothing
+	if len(res) == 0 && strings.Contains(word, "_") {
+		res, err = searchType(proto_file, strings.Split(word, "_")[0])
+	}
+	return res, err
+}
+
+// generatedDeclarations returns the declarations of the proto file of g one
+// of whose names in the code generated for language is word.
+func generatedDeclarations(g *generatedFile, language, word string) (result []SymbolDefinition) {
+	if word == "" {
+		return nil
+	}
+	proto_file := g.proto_file
+	for _, decl := range declarations(proto_file) {
+		if !generatedNamesContain(g.names(language, decl.element), word) {
+			continue
+		}
+		switch {
+		case decl.message != nil:
+			decl.message.Protobuf().Position.Filename = string(proto_file.URI())
+			result = append(result, messageSymbolDefinition(proto_file, decl.message))
+		case decl.enum != nil:
+			decl.enum.Protobuf().Position.Filename = string(proto_file.URI())
+			result = append(result, enumSymbolDefinition(proto_file, decl.enum))
+		default:
+			result = append(result, SymbolDefinition{
+				Filename: string(proto_file.URI()),
+				Position: decl.rng.Start,
+				Type:     DefinitionTypeDeclaration,
+				Range:    decl.rng,
+			})
+		}
+	}
+	return result
+}
+
+// generatedNamesContain reports whether word is the identifier of one of
+// names, like id of ::api::User::id(), or of HasField("id") in python.
+func generatedNamesContain(names []string, word string) bool {
+	for _, name := range names {
+		if generatedIdentifier(name) == word {
+			return true
+		}
+	}
+	return false
+}
+
+func generatedIdentifier(name string) string {
+	if i := strings.IndexByte(name, '('); i != -1 {
+		if argument := name[i+1 : len(name)-1]; strings.HasPrefix(argument, `"`) {
+			// the field of HasField("id") is named by the string
+			return strings.Trim(argument, `"`)
+		}
+		name = name[:i]
+	}
+	if i := strings.LastIndexAny(name, ".:"); i != -1 {
+		name = name[i+1:]
+	}
+	return name
+}
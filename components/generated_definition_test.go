@@ -0,0 +1,55 @@
+package components
+
+import (
+	"testing"
+
+	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_generatedIdentifier(t *testing.T) {
+	require.Equal(t, "GetId", generatedIdentifier("GetId()"))
+	require.Equal(t, "id", generatedIdentifier("id(int)"))
+	require.Equal(t, "Hov_E", generatedIdentifier("::api::Hov_E"))
+	require.Equal(t, "Get", generatedIdentifier("HovServiceClient.Get()"))
+	require.Equal(t, "id", generatedIdentifier(`HasField("id")`))
+	require.Equal(t, "Hov", generatedIdentifier("api.hov_pb2.Hov"))
+}
+
+func Test_generatedDeclarations(t *testing.T) {
+	content := `syntax = "proto3";
+package foo;
+message User {
+  string user_id = 1;
+  enum State {
+    STATE_UNSPECIFIED = 0;
+  }
+}
+`
+	proto_file := newMockProtoFile(t, "file:///foo/user.proto", content)
+	g := newGeneratedFile(proto_file, "foo/user.proto")
+	field := defines.Range{Start: defines.Position{Line: 3, Character: 9}, End: defines.Position{Line: 3, Character: 16}}
+	for _, tt := range []struct{ language, word string }{
+		{languageGo, "GetUserId"},
+		{languageGo, "UserId"},
+		{languageCpp, "set_user_id"},
+		{languageCpp, "mutable_user_id"},
+		{languagePython, "user_id"},
+	} {
+		res := generatedDeclarations(g, tt.language, tt.word)
+		require.Len(t, res, 1, tt.word)
+		require.Equal(t, DefinitionTypeDeclaration, res[0].Type, tt.word)
+		require.Equal(t, field, res[0].Range, tt.word)
+	}
+
+	res := generatedDeclarations(g, languageGo, "User_State")
+	require.Len(t, res, 1)
+	require.Equal(t, DefinitionTypeEnum, res[0].Type)
+	require.Equal(t, "State", res[0].Enum.Protobuf().Name)
+
+	res = generatedDeclarations(g, languageCpp, "User_State_STATE_UNSPECIFIED")
+	require.Len(t, res, 1)
+	require.Equal(t, defines.Position{Line: 5, Character: 4}, res[0].Position)
+
+	require.Empty(t, generatedDeclarations(g, languageGo, "Missing"))
+}
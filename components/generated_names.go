@@ -0,0 +1,555 @@
+package components
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	protobuf "github.com/emicklei/proto"
+	"github.com/lasorda/protobuf-language-server/proto/types"
+	"github.com/lasorda/protobuf-language-server/proto/view"
+	"go.lsp.dev/uri"
+)
+
+// languages of the generated-code-hover setting
+const (
+	languageGo     = "go"
+	languageCpp    = "cpp"
+	languageJava   = "java"
+	languagePython = "python"
+	languageJSON   = "json"
+)
+
+var languageTitles = map[string]string{
+	languageGo:     "Go",
+	languageCpp:    "C++",
+	languageJava:   "Java",
+	languagePython: "Python",
+	languageJSON:   "JSON",
+}
+
+// kinds of field types, they decide which accessors are generated
+const (
+	fieldTypeScalar = iota
+	fieldTypeString
+	fieldTypeEnum
+	fieldTypeMessage
+)
+
+// generatedCodeHover lists the names element has in the code generated for
+// the languages of the generated-code-hover setting, "" when it is not set.
+func generatedCodeHover(proto_file view.ProtoFile, element protobuf.Visitee) string {
+	languages := view.ViewManager.Settings().GeneratedCodeHover
+	if len(languages) == 0 {
+		return ""
+	}
+	return generatedFileOf(proto_file).hover(element, languages)
+}
+
+// generatedFileOf returns the generated file of proto_file, named after the
+// path it is imported with.
+func generatedFileOf(proto_file view.ProtoFile) *generatedFile {
+	import_path, err := view.ViewManager.ImportPath(proto_file.URI(), proto_file.URI())
+	if err != nil {
+		import_path = path.Base(uri.URI(proto_file.URI()).Filename())
+	}
+	return newGeneratedFile(proto_file, import_path)
+}
+
+// generatedFile derives the names of generated code from the declarations
+// and the options of a file, following the naming rules of each protoc
+// plugin.
+type generatedFile struct {
+	proto_file view.ProtoFile
+	// the path the file is imported with, like api/user.proto
+	importPath string
+	options    map[string]string
+}
+
+func newGeneratedFile(proto_file view.ProtoFile, import_path string) *generatedFile {
+	g := &generatedFile{proto_file: proto_file, importPath: import_path, options: make(map[string]string)}
+	for _, element := range proto_file.Proto().Protobuf().Elements {
+		if o, ok := element.(*protobuf.Option); ok {
+			g.options[o.Name] = o.Constant.Source
+		}
+	}
+	return g
+}
+
+func (g *generatedFile) hover(element protobuf.Visitee, languages []string) string {
+	var lines []string
+	for _, language := range languages {
+		if names := g.names(language, element); len(names) > 0 {
+			lines = append(lines, fmt.Sprintf("- **%s** `%s`", languageTitles[language], strings.Join(names, "`, `")))
+		}
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return "\n\n---\n\n" + strings.Join(lines, "\n")
+}
+
+// names returns the names element has in the code generated for language.
+func (g *generatedFile) names(language string, element protobuf.Visitee) []string {
+	switch language {
+	case languageGo:
+		return g.goNames(element)
+	case languageCpp:
+		return g.cppNames(element)
+	case languageJava:
+		return g.javaNames(element)
+	case languagePython:
+		return g.pythonNames(element)
+	case languageJSON:
+		return g.jsonNames(element)
+	}
+	return nil
+}
+
+// fieldType classifies the type of a field declared in parent.
+func (g *generatedFile) fieldType(parent protobuf.Visitee, typeName string) int {
+	switch {
+	case typeName == "string" || typeName == "bytes":
+		return fieldTypeString
+	case types.IsBuildInProtoType(typeName):
+		return fieldTypeScalar
+	}
+	scope := scopeMessage(g.proto_file, declarationNames(parent))
+	if defs := resolveType(g.proto_file, scope, typeName); len(defs) > 0 && defs[0].Enum != nil {
+		return fieldTypeEnum
+	}
+	return fieldTypeMessage
+}
+
+// hasPresence reports whether a field has a has_ accessor.
+func (g *generatedFile) hasPresence(f *protobuf.NormalField, kind int) bool {
+	if f.Repeated {
+		return false
+	}
+	if _, ok := f.Parent.(*protobuf.Oneof); ok {
+		return true
+	}
+	return kind == fieldTypeMessage || f.Optional || f.Required || fileSyntax(g.proto_file) == "proto2"
+}
+
+// extendScope returns the names of the messages around the extend block of
+// an extension field, ok is false for a field which is no extension.
+func extendScope(parent protobuf.Visitee) (names []string, ok bool) {
+	extend, ok := parent.(*protobuf.Message)
+	if !ok || !extend.IsExtend {
+		return nil, false
+	}
+	return declarationNames(extend.Parent), true
+}
+
+// normalField returns the field of a NormalField or a OneOfField.
+func normalField(element protobuf.Visitee) (*protobuf.NormalField, bool) {
+	switch v := element.(type) {
+	case *protobuf.NormalField:
+		return v, true
+	case *protobuf.OneOfField:
+		return &protobuf.NormalField{Field: v.Field}, true
+	}
+	return nil, false
+}
+
+func (g *generatedFile) goPackage() string {
+	name := g.options["go_package"]
+	if i := strings.LastIndexByte(name, ';'); i != -1 {
+		name = name[i+1:]
+	} else if name != "" {
+		name = path.Base(name)
+	} else {
+		name = filePackage(g.proto_file)
+	}
+	var res strings.Builder
+	for _, r := range name {
+		if r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			res.WriteRune(r)
+		} else {
+			res.WriteRune('_')
+		}
+	}
+	if res.Len() == 0 {
+		return ""
+	}
+	return res.String() + "."
+}
+
+// goNames follows protoc-gen-go and protoc-gen-go-grpc.
+func (g *generatedFile) goNames(element protobuf.Visitee) []string {
+	pkg := g.goPackage()
+	switch v := element.(type) {
+	case *protobuf.Message, *protobuf.Enum:
+		return []string{pkg + goCamelCase(strings.Join(declarationNames(v), "."))}
+	case *protobuf.EnumField:
+		names := declarationNames(v.Parent)
+		if len(names) > 1 {
+			names = names[:len(names)-1]
+		}
+		return []string{pkg + goCamelCase(strings.Join(names, ".")) + "_" + v.Name}
+	case *protobuf.NormalField, *protobuf.OneOfField, *protobuf.MapField:
+		name, parent := fieldNameAndParent(v)
+		if scope, ok := extendScope(parent); ok {
+			return []string{pkg + "E_" + goCamelCase(strings.Join(append(scope, name), "."))}
+		}
+		field := goCamelCase(name)
+		res := []string{"Get" + field + "()", field}
+		if oneof, ok := parent.(*protobuf.Oneof); ok {
+			res = append(res, pkg+goCamelCase(strings.Join(declarationNames(oneof), "."))+"_"+field)
+		}
+		return res
+	case *protobuf.Oneof:
+		field := goCamelCase(v.Name)
+		return []string{"Get" + field + "()", field, "is" + goCamelCase(strings.Join(declarationNames(v), ".")) + "_" + field}
+	case *protobuf.Service:
+		service := goCamelCase(v.Name)
+		return []string{pkg + service + "Client", pkg + service + "Server", pkg + "New" + service + "Client()", pkg + "Register" + service + "Server()"}
+	case *protobuf.RPC:
+		service := goCamelCase(strings.Join(declarationNames(v.Parent), "."))
+		method := goCamelCase(v.Name)
+		return []string{service + "Client." + method + "()", service + "Server." + method + "()"}
+	}
+	return nil
+}
+
+// cppNames follows the C++ generator of protoc and grpc_cpp_plugin.
+func (g *generatedFile) cppNames(element protobuf.Visitee) []string {
+	namespace := "::"
+	if pkg := filePackage(g.proto_file); pkg != "" {
+		namespace += strings.ReplaceAll(pkg, ".", "::") + "::"
+	}
+	lite := g.options["optimize_for"] == "LITE_RUNTIME"
+	switch v := element.(type) {
+	case *protobuf.Message, *protobuf.Enum:
+		names := declarationNames(v)
+		res := []string{namespace + strings.Join(names, "_")}
+		if len(names) > 1 {
+			res = append(res, namespace+strings.Join(names, "::"))
+		}
+		if _, ok := v.(*protobuf.Message); ok && lite {
+			res = append(res, "::google::protobuf::MessageLite")
+		}
+		return res
+	case *protobuf.EnumField:
+		names := declarationNames(v.Parent)
+		if len(names) == 1 {
+			return []string{namespace + v.Name}
+		}
+		return []string{
+			namespace + strings.Join(names, "_") + "_" + v.Name,
+			namespace + strings.Join(names[:len(names)-1], "::") + "::" + v.Name,
+		}
+	case *protobuf.MapField:
+		name := strings.ToLower(v.Name)
+		return []string{name + "()", "mutable_" + name + "()", name + "_size()", "clear_" + name + "()"}
+	case *protobuf.NormalField, *protobuf.OneOfField:
+		f, _ := normalField(v)
+		if scope, ok := extendScope(f.Parent); ok {
+			return []string{namespace + strings.Join(append(scope, f.Name), "::")}
+		}
+		name := strings.ToLower(f.Name)
+		kind := g.fieldType(f.Parent, f.Type)
+		if f.Repeated {
+			return []string{name + "()", name + "(int)", name + "_size()", "add_" + name + "()", "mutable_" + name + "()", "clear_" + name + "()"}
+		}
+		res := []string{name + "()"}
+		switch kind {
+		case fieldTypeMessage:
+			res = append(res, "mutable_"+name+"()", "release_"+name+"()", "set_allocated_"+name+"()")
+		case fieldTypeString:
+			res = append(res, "set_"+name+"()", "mutable_"+name+"()")
+		default:
+			res = append(res, "set_"+name+"()")
+		}
+		if g.hasPresence(f, kind) {
+			res = append(res, "has_"+name+"()")
+		}
+		return append(res, "clear_"+name+"()")
+	case *protobuf.Oneof:
+		name := strings.ToLower(v.Name)
+		message := strings.Join(declarationNames(v), "_")
+		return []string{name + "_case()", "clear_" + name + "()", namespace + message + "::" + underscoresToCamelCase(v.Name, true) + "Case"}
+	case *protobuf.Service:
+		service := namespace + v.Name
+		return []string{service + "::Stub", service + "::Service"}
+	case *protobuf.RPC:
+		service := namespace + strings.Join(declarationNames(v.Parent), "::")
+		return []string{service + "::Stub::" + v.Name + "()", service + "::Service::" + v.Name + "()"}
+	}
+	return nil
+}
+
+// javaOuterClass returns java_outer_classname, or the class protoc names
+// after the file, suffixed when a declaration has the same name.
+func (g *generatedFile) javaOuterClass() string {
+	if name, ok := g.options["java_outer_classname"]; ok {
+		return name
+	}
+	name := underscoresToCamelCase(strings.TrimSuffix(path.Base(g.importPath), ".proto"), true)
+	conflict := false
+	for _, typ := range fileTypes(g.proto_file) {
+		if typ.fullName == name || strings.HasSuffix(typ.fullName, "."+name) {
+			conflict = true
+		}
+	}
+	for _, service := range g.proto_file.Proto().Services() {
+		if service.Protobuf().Name == name {
+			conflict = true
+		}
+	}
+	if conflict {
+		name += "OuterClass"
+	}
+	return name
+}
+
+// javaPackage returns java_package or the proto package.
+func (g *generatedFile) javaPackage() string {
+	if pkg := g.options["java_package"]; pkg != "" {
+		return pkg
+	}
+	return filePackage(g.proto_file)
+}
+
+// javaClass returns the class of the declaration with names, nested in the
+// outer class unless java_multiple_files is set.
+func (g *generatedFile) javaClass(names []string) string {
+	if g.options["java_multiple_files"] != "true" {
+		names = append([]string{g.javaOuterClass()}, names...)
+	}
+	return javaQualified(g.javaPackage(), names...)
+}
+
+func javaQualified(pkg string, names ...string) string {
+	if pkg == "" {
+		return strings.Join(names, ".")
+	}
+	return strings.Join(append([]string{pkg}, names...), ".")
+}
+
+// javaNames follows the Java generator of protoc and protoc-gen-grpc-java.
+func (g *generatedFile) javaNames(element protobuf.Visitee) []string {
+	lite := g.options["optimize_for"] == "LITE_RUNTIME"
+	switch v := element.(type) {
+	case *protobuf.Message:
+		res := []string{g.javaClass(declarationNames(v)), g.javaClass(declarationNames(v)) + ".Builder"}
+		if lite {
+			res = append(res, "com.google.protobuf.GeneratedMessageLite")
+		}
+		return res
+	case *protobuf.Enum:
+		return []string{g.javaClass(declarationNames(v))}
+	case *protobuf.EnumField:
+		return []string{g.javaClass(declarationNames(v.Parent)) + "." + v.Name}
+	case *protobuf.MapField:
+		name := underscoresToCamelCase(v.Name, true)
+		return []string{"get" + name + "Map()", "get" + name + "OrDefault()", "contains" + name + "()", "get" + name + "Count()", "put" + name + "()"}
+	case *protobuf.NormalField, *protobuf.OneOfField:
+		f, _ := normalField(v)
+		if scope, ok := extendScope(f.Parent); ok {
+			class := g.javaClass(scope)
+			if len(scope) == 0 {
+				// top-level extensions are members of the outer class
+				class = javaQualified(g.javaPackage(), g.javaOuterClass())
+			}
+			return []string{class + "." + underscoresToCamelCase(f.Name, false)}
+		}
+		name := underscoresToCamelCase(f.Name, true)
+		kind := g.fieldType(f.Parent, f.Type)
+		if f.Repeated {
+			return []string{"get" + name + "List()", "get" + name + "Count()", "get" + name + "(int)", "add" + name + "()", "addAll" + name + "()"}
+		}
+		res := []string{"get" + name + "()"}
+		if kind == fieldTypeEnum && fileSyntax(g.proto_file) == "proto3" {
+			res = append(res, "get"+name+"Value()")
+		}
+		res = append(res, "set"+name+"()")
+		if g.hasPresence(f, kind) {
+			res = append(res, "has"+name+"()")
+		}
+		return append(res, "clear"+name+"()")
+	case *protobuf.Oneof:
+		name := underscoresToCamelCase(v.Name, true)
+		return []string{"get" + name + "Case()", "clear" + name + "()", g.javaClass(declarationNames(v)) + "." + name + "Case"}
+	case *protobuf.Service:
+		grpc := javaQualified(g.javaPackage(), v.Name+"Grpc")
+		return []string{grpc, grpc + "." + v.Name + "BlockingStub", grpc + "." + v.Name + "ImplBase"}
+	case *protobuf.RPC:
+		service := strings.Join(declarationNames(v.Parent), ".")
+		method := underscoresToCamelCase(v.Name, false)
+		stub := service + "BlockingStub."
+		if v.StreamsRequest {
+			// client streaming calls are on the async stub only
+			stub = service + "Stub."
+		}
+		return []string{stub + method + "()", service + "ImplBase." + method + "()"}
+	}
+	return nil
+}
+
+// pythonModule returns the module generated for the file, suffixed with
+// _pb2 or _pb2_grpc.
+func (g *generatedFile) pythonModule(suffix string) string {
+	module := strings.TrimSuffix(g.importPath, ".proto")
+	module = strings.ReplaceAll(module, "-", "_")
+	return strings.ReplaceAll(module, "/", ".") + suffix
+}
+
+// pythonNames follows the Python generator of protoc and grpcio-tools.
+func (g *generatedFile) pythonNames(element protobuf.Visitee) []string {
+	module := g.pythonModule("_pb2")
+	switch v := element.(type) {
+	case *protobuf.Message, *protobuf.Enum:
+		return []string{module + "." + strings.Join(declarationNames(v), ".")}
+	case *protobuf.EnumField:
+		// values are members of the enclosing message, or of the module
+		names := declarationNames(v.Parent)
+		return []string{strings.Join(append(append([]string{module}, names[:len(names)-1]...), v.Name), ".")}
+	case *protobuf.MapField:
+		return []string{v.Name}
+	case *protobuf.NormalField, *protobuf.OneOfField:
+		f, _ := normalField(v)
+		if scope, ok := extendScope(f.Parent); ok {
+			return []string{strings.Join(append(append([]string{module}, scope...), f.Name), ".")}
+		}
+		res := []string{f.Name}
+		if g.hasPresence(f, g.fieldType(f.Parent, f.Type)) {
+			res = append(res, fmt.Sprintf("HasField(%q)", f.Name))
+		}
+		return append(res, fmt.Sprintf("ClearField(%q)", f.Name))
+	case *protobuf.Oneof:
+		return []string{fmt.Sprintf("WhichOneof(%q)", v.Name)}
+	case *protobuf.Service:
+		grpc := g.pythonModule("_pb2_grpc")
+		return []string{grpc + "." + v.Name + "Stub", grpc + "." + v.Name + "Servicer", grpc + ".add_" + v.Name + "Servicer_to_server"}
+	case *protobuf.RPC:
+		service := strings.Join(declarationNames(v.Parent), ".")
+		return []string{service + "Stub." + v.Name, service + "Servicer." + v.Name}
+	}
+	return nil
+}
+
+// jsonNames follows the JSON mapping of proto3.
+func (g *generatedFile) jsonNames(element protobuf.Visitee) []string {
+	switch v := element.(type) {
+	case *protobuf.EnumField:
+		return []string{fmt.Sprintf("%q", v.Name)}
+	case *protobuf.MapField:
+		return []string{jsonName(v.Name, v.Options)}
+	case *protobuf.NormalField, *protobuf.OneOfField:
+		f, _ := normalField(v)
+		if scope, ok := extendScope(f.Parent); ok {
+			if pkg := filePackage(g.proto_file); pkg != "" {
+				scope = append([]string{pkg}, scope...)
+			}
+			return []string{"[" + strings.Join(append(scope, f.Name), ".") + "]"}
+		}
+		return []string{jsonName(f.Name, f.Options)}
+	}
+	return nil
+}
+
+// fieldNameAndParent returns the name of a field and the declaration it is
+// written in.
+func fieldNameAndParent(element protobuf.Visitee) (string, protobuf.Visitee) {
+	switch v := element.(type) {
+	case *protobuf.NormalField:
+		return v.Name, v.Parent
+	case *protobuf.OneOfField:
+		return v.Name, v.Parent
+	case *protobuf.MapField:
+		return v.Name, v.Parent
+	}
+	return "", nil
+}
+
+// jsonName returns the json_name option or the lowerCamelCase name protoc
+// derives from the field name.
+func jsonName(name string, options []*protobuf.Option) string {
+	for _, o := range options {
+		if o.Name == "json_name" {
+			return o.Constant.Source
+		}
+	}
+	var res strings.Builder
+	upper := false
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c == '_':
+			upper = true
+		case upper && c >= 'a' && c <= 'z':
+			res.WriteByte(c - 'a' + 'A')
+			upper = false
+		default:
+			res.WriteByte(c)
+			upper = false
+		}
+	}
+	return res.String()
+}
+
+// goCamelCase converts a name to a Go identifier like protoc-gen-go does,
+// a dot becomes an underscore.
+func goCamelCase(s string) string {
+	isLower := func(c byte) bool { return c >= 'a' && c <= 'z' }
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '.' && i+1 < len(s) && isLower(s[i+1]):
+			// skip the dot of ".{{lowercase}}"
+		case c == '.':
+			b = append(b, '_')
+		case c == '_' && (i == 0 || s[i-1] == '.'):
+			// a leading underscore becomes X to start with a capital letter
+			b = append(b, 'X')
+		case c == '_' && i+1 < len(s) && isLower(s[i+1]):
+			// skip the underscore of "_{{lowercase}}"
+		case c >= '0' && c <= '9':
+			b = append(b, c)
+		default:
+			if isLower(c) {
+				c -= 'a' - 'A'
+			}
+			b = append(b, c)
+			// the lowercase letters following the capital one
+			for ; i+1 < len(s) && isLower(s[i+1]); i++ {
+				b = append(b, s[i+1])
+			}
+		}
+	}
+	return string(b)
+}
+
+// underscoresToCamelCase converts a name like protoc does for Java and C++,
+// every letter following an underscore, a digit or any other character is
+// capitalized.
+func underscoresToCamelCase(s string, capitalizeFirst bool) string {
+	var b []byte
+	capitalizeNext := capitalizeFirst
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'a' && c <= 'z':
+			if capitalizeNext {
+				c -= 'a' - 'A'
+			}
+			b = append(b, c)
+			capitalizeNext = false
+		case c >= 'A' && c <= 'Z':
+			if i == 0 && !capitalizeFirst {
+				c += 'a' - 'A'
+			}
+			b = append(b, c)
+			capitalizeNext = false
+		case c >= '0' && c <= '9':
+			b = append(b, c)
+			capitalizeNext = true
+		default:
+			capitalizeNext = true
+		}
+	}
+	return string(b)
+}
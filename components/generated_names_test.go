@@ -0,0 +1,71 @@
+package components
+
+import (
+	"testing"
+
+	"github.com/emicklei/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_goCamelCase(t *testing.T) {
+	require.Equal(t, "FooBar", goCamelCase("foo_bar"))
+	require.Equal(t, "XFoo", goCamelCase("_foo"))
+	require.Equal(t, "Foo_1Bar", goCamelCase("foo_1bar"))
+	require.Equal(t, "UserID", goCamelCase("userID"))
+	require.Equal(t, "fooBar", underscoresToCamelCase("foo_bar", false))
+	require.Equal(t, "Foo2Bar", underscoresToCamelCase("foo2bar", true))
+	require.Equal(t, "userId", jsonName("user_id", nil))
+}
+
+func Test_generatedFile_hover(t *testing.T) {
+	content := `syntax = "proto3";
+package foo.bar;
+option go_package = "example.com/foo/barpb";
+option java_package = "com.example.bar";
+option java_multiple_files = true;
+message User {
+  optional string user_id = 1 [json_name = "uid"];
+  oneof contact {
+    string email = 2;
+  }
+  enum State {
+    STATE_UNSPECIFIED = 0;
+  }
+}
+service UserService {
+  rpc Get(User) returns (User);
+}
+`
+	proto_file := newMockProtoFile(t, "file:///foo/bar/user.proto", content)
+	g := newGeneratedFile(proto_file, "foo/bar/user.proto")
+	user := proto_file.Proto().Messages()[0].Protobuf()
+	var user_id *proto.NormalField
+	var contact *proto.Oneof
+	for _, element := range user.Elements {
+		switch v := element.(type) {
+		case *proto.NormalField:
+			user_id = v
+		case *proto.Oneof:
+			contact = v
+		}
+	}
+	state := proto_file.Proto().Messages()[0].NestedEnums()[0].Protobuf()
+	service := proto_file.Proto().Services()[0].Protobuf()
+
+	require.Equal(t, []string{"barpb.User"}, g.goNames(user))
+	require.Equal(t, []string{"GetUserId()", "UserId"}, g.goNames(user_id))
+	require.Equal(t, []string{"barpb.User_State"}, g.goNames(state))
+	require.Equal(t, []string{"::foo::bar::User_State", "::foo::bar::User::State"}, g.cppNames(state))
+	require.Equal(t, []string{"com.example.bar.User", "com.example.bar.User.Builder"}, g.javaNames(user))
+	require.Equal(t, []string{"getContactCase()", "clearContact()", "com.example.bar.User.ContactCase"}, g.javaNames(contact))
+	require.Equal(t, []string{"foo.bar.user_pb2.User"}, g.pythonNames(user))
+	require.Equal(t, []string{"uid"}, g.jsonNames(user_id))
+	require.Equal(t, []string{
+		"barpb.UserServiceClient", "barpb.UserServiceServer",
+		"barpb.NewUserServiceClient()", "barpb.RegisterUserServiceServer()",
+	}, g.goNames(service))
+
+	require.Equal(t, "", g.hover(user, nil))
+	require.Equal(t, "\n\n---\n\n- **Go** `barpb.User`\n- **Python** `foo.bar.user_pb2.User`",
+		g.hover(user, []string{languageGo, languagePython}))
+}
@@ -3,11 +3,14 @@ package components
 import (
 	"bytes"
 	"context"
+	"fmt"
+	"sort"
 	"strings"
 	"text/template"
 
 	"github.com/lasorda/protobuf-language-server/proto/parser"
 	"github.com/lasorda/protobuf-language-server/proto/view"
+	"go.lsp.dev/uri"
 
 	"github.com/emicklei/proto"
 	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
@@ -30,6 +33,35 @@ func Hover(ctx context.Context, req *defines.HoverParams) (result *defines.Hover
 	if !view.IsProtoFile(req.TextDocument.Uri) {
 		return nil, nil
 	}
+	if proto_file, err := view.ViewManager.GetFile(req.TextDocument.Uri); err == nil && proto_file.Proto() != nil {
+		if doc, ok := optionHover(proto_file, req.Position); ok {
+			return &defines.Hover{
+				Contents: defines.MarkupContent{Kind: defines.MarkupKindMarkdown, Value: doc},
+			}, nil
+		}
+		for _, decl := range declarations(proto_file) {
+			if !rangeContains(decl.rng, req.Position) {
+				continue
+			}
+			switch {
+			case decl.message != nil:
+				return symbolHover(SymbolDefinition{Filename: string(proto_file.URI()), Type: DefinitionTypeMessage, Message: decl.message}), nil
+			case decl.enum != nil:
+				return symbolHover(SymbolDefinition{Filename: string(proto_file.URI()), Type: DefinitionTypeEnum, Enum: decl.enum}), nil
+			}
+			doc := declarationHover(proto_file, decl) + generatedCodeHover(proto_file, decl.element)
+			return &defines.Hover{
+				Contents: defines.MarkupContent{Kind: defines.MarkupKindMarkdown, Value: doc},
+			}, nil
+		}
+		if pkg, ok := packageAt(proto_file, req.Position); ok {
+			if doc := packageHover(proto_file, pkg); doc != "" {
+				return &defines.Hover{
+					Contents: defines.MarkupContent{Kind: defines.MarkupKindMarkdown, Value: doc},
+				}, nil
+			}
+		}
+	}
 	symbols, err := findSymbolDefinition(ctx, &req.TextDocumentPositionParams)
 	if err != nil {
 		return nil, err
@@ -39,14 +71,27 @@ func Hover(ctx context.Context, req *defines.HoverParams) (result *defines.Hover
 		return nil, ErrSymbolNotFound
 	}
 
-	result = &defines.Hover{
+	return symbolHover(symbols[0]), nil
+}
+
+// symbolHover documents the message or enum of symbol with the names of its
+// generated code.
+func symbolHover(symbol SymbolDefinition) *defines.Hover {
+	doc := formatHover(symbol)
+	if def_file, err := view.ViewManager.GetFile(defines.DocumentUri(symbol.Filename)); err == nil && def_file.Proto() != nil {
+		switch symbol.Type {
+		case DefinitionTypeMessage:
+			doc += generatedCodeHover(def_file, symbol.Message.Protobuf())
+		case DefinitionTypeEnum:
+			doc += generatedCodeHover(def_file, symbol.Enum.Protobuf())
+		}
+	}
+	return &defines.Hover{
 		Contents: defines.MarkupContent{
 			Kind:  defines.MarkupKindMarkdown,
-			Value: formatHover(symbols[0]),
+			Value: doc,
 		},
 	}
-
-	return result, nil
 }
 
 func formatHover(symbol SymbolDefinition) string {
@@ -313,34 +358,37 @@ func prepareOneofFields(in []parser.Oneof) []*oneOfData {
 	var out []*oneOfData
 
 	for _, oneOfItem := range in {
+		out = append(out, prepareOneofData(oneOfItem.Protobuf()))
+	}
+	return out
+}
 
-		oneOfData := oneOfData{
-			Name: oneOfItem.Protobuf().Name,
-		}
+func prepareOneofData(oneof *proto.Oneof) *oneOfData {
+	oneOfData := oneOfData{
+		Name: oneof.Name,
+	}
 
-		if oneOfItem.Protobuf().Comment != nil {
-			oneOfData.Comments = formatComments(oneOfItem.Protobuf().Comment.Lines)
-		}
+	if oneof.Comment != nil {
+		oneOfData.Comments = formatComments(oneof.Comment.Lines)
+	}
 
-		for _, item := range oneOfItem.Protobuf().Elements {
+	for _, item := range oneof.Elements {
 
-			item.Accept(&oneOfFieldVisitor{visitFunc: func(oof *proto.OneOfField) {
-				data := field{
-					Name:          oof.Name,
-					Type:          oof.Type,
-					ProtoSequence: oof.Sequence,
-				}
+		item.Accept(&oneOfFieldVisitor{visitFunc: func(oof *proto.OneOfField) {
+			data := field{
+				Name:          oof.Name,
+				Type:          oof.Type,
+				ProtoSequence: oof.Sequence,
+			}
 
-				if oof.Comment != nil {
-					data.Comments = formatComments(oof.Comment.Lines)
-				}
+			if oof.Comment != nil {
+				data.Comments = formatComments(oof.Comment.Lines)
+			}
 
-				oneOfData.Fields = append(oneOfData.Fields, data)
-			}})
-		}
-		out = append(out, &oneOfData)
+			oneOfData.Fields = append(oneOfData.Fields, data)
+		}})
 	}
-	return out
+	return &oneOfData
 }
 
 func getCustomFuncs(parent *template.Template) template.FuncMap {
@@ -372,3 +420,238 @@ func formatComments(lines []string) []string {
 	}
 	return out
 }
+
+// declarationHover describes the field, oneof, enum value, service or rpc
+// declared by decl, messages and enums are described by formatHover.
+func declarationHover(proto_file view.ProtoFile, decl declaration) string {
+	var lines []string
+	var trailer string
+	comment := func(c *proto.Comment) {
+		if c != nil {
+			lines = append(lines, formatComments(c.Lines)...)
+		}
+	}
+	inline := func(line string, c *proto.Comment) string {
+		if c != nil && len(c.Lines) > 0 {
+			line += " " + formatComments(c.Lines[0:1])[0]
+		}
+		return line
+	}
+
+	switch v := decl.element.(type) {
+	case *proto.NormalField:
+		comment(v.Comment)
+		label := ""
+		switch {
+		case v.Repeated:
+			label = "repeated "
+		case v.Optional:
+			label = "optional "
+		case v.Required:
+			label = "required "
+		}
+		lines = append(lines, inline(fmt.Sprintf("%s%s %s = %d%s;", label, v.Type, v.Name, v.Sequence, optionsText(v.Options)), v.InlineComment))
+		trailer = fieldOwner(proto_file, v.Parent)
+	case *proto.MapField:
+		comment(v.Comment)
+		lines = append(lines, inline(fmt.Sprintf("map<%s, %s> %s = %d%s;", v.KeyType, v.Type, v.Name, v.Sequence, optionsText(v.Options)), v.InlineComment))
+		trailer = fieldOwner(proto_file, v.Parent)
+	case *proto.OneOfField:
+		comment(v.Comment)
+		lines = append(lines, inline(fmt.Sprintf("%s %s = %d%s;", v.Type, v.Name, v.Sequence, optionsText(v.Options)), v.InlineComment))
+		trailer = fieldOwner(proto_file, v.Parent)
+	case *proto.Oneof:
+		buffer := bytes.NewBuffer(nil)
+		if err := hoverTmpl.ExecuteTemplate(buffer, "oneof", prepareOneofData(v)); err != nil {
+			return err.Error()
+		}
+		lines = append(lines, strings.TrimPrefix(buffer.String(), "\n"))
+		trailer = fmt.Sprintf("oneof of `%s`", declarationFullName(proto_file, v.Parent))
+	case *proto.EnumField:
+		comment(v.Comment)
+		var options []*proto.Option
+		for _, e := range v.Elements {
+			if o, ok := e.(*proto.Option); ok {
+				options = append(options, o)
+			}
+		}
+		lines = append(lines, inline(fmt.Sprintf("%s = %d%s;", v.Name, v.Integer, optionsText(options)), v.InlineComment))
+		trailer = fmt.Sprintf("value %d of enum `%s`", v.Integer, declarationFullName(proto_file, v.Parent))
+	case *proto.Service:
+		comment(v.Comment)
+		lines = append(lines, fmt.Sprintf("service %s {", v.Name))
+		for _, e := range v.Elements {
+			if rpc, ok := e.(*proto.RPC); ok {
+				lines = append(lines, "\t"+rpcSignature(rpc))
+			}
+		}
+		lines = append(lines, "}")
+	case *proto.RPC:
+		comment(v.Comment)
+		lines = append(lines, inline(rpcSignature(v), v.InlineComment))
+		// the request and response messages, expanded
+		scope := fmt.Sprintf("rpc of `%s`", declarationFullName(proto_file, v.Parent))
+		var messages []string
+		for _, typeName := range []string{v.RequestType, v.ReturnsType} {
+			if defs := resolveType(proto_file, nil, typeName); len(defs) > 0 {
+				messages = append(messages, formatHover(defs[0]))
+			}
+		}
+		trailer = strings.Join(append([]string{scope}, messages...), "\n\n")
+	default:
+		return ""
+	}
+
+	res := "```proto\n" + strings.Join(lines, "\n") + "\n```"
+	if trailer != "" {
+		res += "\n\n" + trailer
+	}
+	return res
+}
+
+// rpcSignature returns the declaration of rpc without its options.
+func rpcSignature(rpc *proto.RPC) string {
+	return fmt.Sprintf("rpc %s(%s%s) returns (%s%s);", rpc.Name,
+		streamPrefix(rpc.StreamsRequest), rpc.RequestType, streamPrefix(rpc.StreamsReturns), rpc.ReturnsType)
+}
+
+// optionsText returns the options of a field or enum value as written after
+// its number, aggregate values are abbreviated.
+func optionsText(options []*proto.Option) string {
+	if len(options) == 0 {
+		return ""
+	}
+	var parts []string
+	for _, o := range options {
+		value := o.Constant.SourceRepresentation()
+		if len(o.Constant.OrderedMap) > 0 || len(o.Constant.Array) > 0 {
+			value = "{...}"
+		}
+		parts = append(parts, o.Name+" = "+value)
+	}
+	return " [" + strings.Join(parts, ", ") + "]"
+}
+
+// fieldOwner tells which message, oneof or extend a field belongs to.
+func fieldOwner(proto_file view.ProtoFile, parent proto.Visitee) string {
+	switch p := parent.(type) {
+	case *proto.Oneof:
+		return fmt.Sprintf("field of oneof `%s` in `%s`", p.Name, declarationFullName(proto_file, p.Parent))
+	case *proto.Message:
+		if p.IsExtend {
+			return fmt.Sprintf("extension of `%s`", strings.TrimPrefix(p.Name, "."))
+		}
+	}
+	return fmt.Sprintf("field of `%s`", declarationFullName(proto_file, parent))
+}
+
+// declarationFullName returns the fully qualified name of the message, enum
+// or service v, following the parents of nested declarations.
+func declarationFullName(proto_file view.ProtoFile, v proto.Visitee) string {
+	names := declarationNames(v)
+	if pkg := filePackage(proto_file); pkg != "" {
+		names = append([]string{pkg}, names...)
+	}
+	return strings.Join(names, ".")
+}
+
+// declarationNames returns the names of the message, enum or service v and
+// of the messages it is nested in, outermost first.
+func declarationNames(v proto.Visitee) (names []string) {
+	for v != nil {
+		switch p := v.(type) {
+		case *proto.Message:
+			if !p.IsExtend {
+				names = append([]string{p.Name}, names...)
+			}
+			v = p.Parent
+		case *proto.Enum:
+			names = append([]string{p.Name}, names...)
+			v = p.Parent
+		case *proto.Service:
+			names = append([]string{p.Name}, names...)
+			v = p.Parent
+		case *proto.Oneof:
+			v = p.Parent
+		default:
+			v = nil
+		}
+	}
+	return names
+}
+
+// packageHover lists the files declaring the package pkg, those of the
+// workspace and those visible from proto_file.
+func packageHover(proto_file view.ProtoFile, pkg string) string {
+	seen := make(map[defines.DocumentUri]bool)
+	var files []string
+	add := func(file view.ProtoFile) {
+		if seen[file.URI()] || file.Proto() == nil || filePackage(file) != pkg {
+			return
+		}
+		seen[file.URI()] = true
+		name, err := view.ViewManager.ImportPath(proto_file.URI(), file.URI())
+		if err != nil {
+			name = uri.URI(file.URI()).Filename()
+		}
+		files = append(files, fmt.Sprintf("- `%s`", name))
+	}
+	visible, _ := visibleFiles(proto_file)
+	for _, file := range visible {
+		add(file)
+	}
+	for _, file := range view.ViewManager.WorkspaceFiles() {
+		add(file)
+	}
+	if len(files) == 0 {
+		return ""
+	}
+	sort.Strings(files)
+	return fmt.Sprintf("```proto\npackage %s;\n```\n\ndeclared in:\n%s", pkg, strings.Join(files, "\n"))
+}
+
+// packageAt returns the package named at position, in the package statement
+// or as the qualifier of a type reference.
+func packageAt(proto_file view.ProtoFile, position defines.Position) (string, bool) {
+	for _, p := range proto_file.Proto().Packages() {
+		pkg := p.ProtoPackage
+		if rangeContains(view.TokenRange(proto_file, pkg.Position, "package", pkg.Name), position) {
+			return pkg.Name, true
+		}
+	}
+
+	for _, ref := range typeReferences(proto_file) {
+		rng := ref.Range(proto_file)
+		if ref.name == "" || !rangeContains(rng, position) {
+			continue
+		}
+		// the qualifier up to the end of the part under the cursor
+		cursor := int(position.Character - rng.Start.Character)
+		end := strings.IndexByte(ref.name[cursor:], '.')
+		if end == -1 {
+			return "", false
+		}
+		qualifier := ref.name[:cursor+end]
+		if qualifier == "" || qualifier == "." || len(resolveType(proto_file, ref.scope, qualifier)) > 0 {
+			return "", false
+		}
+		packages := make(map[string]bool)
+		files, _ := visibleFiles(proto_file)
+		for _, file := range files {
+			packages[filePackage(file)] = true
+		}
+		for _, name := range scopedNames(proto_file, qualifier) {
+			if packages[name] {
+				return name, true
+			}
+			// a prefix of the package of a visible file
+			for pkg := range packages {
+				if strings.HasPrefix(pkg, name+".") {
+					return name, true
+				}
+			}
+		}
+		return "", false
+	}
+	return "", false
+}
@@ -0,0 +1,62 @@
+package components
+
+import (
+	"testing"
+
+	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_declarationHover(t *testing.T) {
+	content := `syntax = "proto3";
+package foo;
+message A {
+  // the id
+  repeated string id = 1 [deprecated = true]; // trailing
+  oneof kind {
+    int32 n = 2;
+  }
+  enum E {
+    E_UNSPECIFIED = 0;
+  }
+}
+service S {
+  rpc Get(stream A) returns (A);
+}
+`
+	proto_file := newMockProtoFile(t, "file:///foo.proto", content)
+	hovers := make(map[string]string)
+	for _, decl := range declarations(proto_file) {
+		hovers[decl.name] = declarationHover(proto_file, decl)
+	}
+	require.Equal(t, "```proto\n// the id\nrepeated string id = 1 [deprecated = true]; // trailing\n```\n\nfield of `foo.A`", hovers["id"])
+	require.Equal(t, "```proto\nint32 n = 2;\n```\n\nfield of oneof `kind` in `foo.A`", hovers["n"])
+	require.Equal(t, "```proto\nE_UNSPECIFIED = 0;\n```\n\nvalue 0 of enum `foo.A.E`", hovers["E_UNSPECIFIED"])
+	require.Equal(t, "```proto\nservice S {\n\trpc Get(stream A) returns (A);\n}\n```", hovers["S"])
+	require.Contains(t, hovers["Get"], "```proto\nrpc Get(stream A) returns (A);\n```\n\nrpc of `foo.S`\n\n```proto\nmessage A {")
+	// messages and enums are described by formatHover
+	require.Equal(t, "", hovers["A"])
+}
+
+func Test_packageAt(t *testing.T) {
+	content := `syntax = "proto3";
+package foo.bar;
+message A {
+  foo.bar.A a = 1;
+  .foo.bar.A b = 2;
+  bar.A c = 3;
+}
+`
+	proto_file := newMockProtoFile(t, "file:///foo.proto", content)
+	at := func(line, character uint) string {
+		pkg, _ := packageAt(proto_file, defines.Position{Line: line, Character: character})
+		return pkg
+	}
+	require.Equal(t, "foo.bar", at(1, 10))
+	require.Equal(t, "foo", at(3, 3))
+	require.Equal(t, "foo.bar", at(3, 7))
+	require.Equal(t, "", at(3, 10))
+	require.Equal(t, "foo.bar", at(4, 8))
+	require.Equal(t, "foo.bar", at(5, 3))
+	require.Equal(t, "", at(5, 7))
+}
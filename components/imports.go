@@ -0,0 +1,499 @@
+package components
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	protobuf "github.com/emicklei/proto"
+	"github.com/lasorda/protobuf-language-server/proto/parser"
+	"github.com/lasorda/protobuf-language-server/proto/types"
+	"github.com/lasorda/protobuf-language-server/proto/view"
+
+	"github.com/lasorda/protobuf-language-server/go-lsp/logs"
+	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
+)
+
+const (
+	DiagnosticCodeUnusedImport     = "unused-import"
+	DiagnosticCodeUnresolvedImport = "unresolved-import"
+)
+
+var (
+	kindFolder = defines.CompletionItemKindFolder
+	kindFile   = defines.CompletionItemKindFile
+)
+
+// import groups of organized imports, in order
+const (
+	importGroupGoogle = iota
+	importGroupThirdParty
+	importGroupLocal
+)
+
+// DiagnoseUnusedImports reports imports which no type, extend or option of
+// the file uses. Public imports are re-exported to importers and weak ones
+// may be missing on purpose, neither is reported.
+func DiagnoseUnusedImports(proto_file view.ProtoFile) (res []defines.Diagnostic) {
+	if proto_file.Proto() == nil {
+		return nil
+	}
+	// a type may come from a missing file, do not guess
+	if _, complete := visibleFiles(proto_file); !complete {
+		logs.Printf("DiagnoseUnusedImports: %v has unresolved imports, skip", proto_file.URI())
+		return nil
+	}
+
+	for _, im := range unusedImports(proto_file) {
+		severity := defines.DiagnosticSeverityWarning
+		tags := []defines.DiagnosticTag{defines.DiagnosticTagUnnecessary}
+		res = append(res, defines.Diagnostic{
+			Range:    importRange(proto_file, im),
+			Severity: &severity,
+			Code:     DiagnosticCodeUnusedImport,
+			Message:  fmt.Sprintf("import %q is unused", im.ProtoImport.Filename),
+			Tags:     &tags,
+			Data:     im.ProtoImport.Filename,
+		})
+	}
+	return res
+}
+
+// DiagnoseUnresolvedImports reports imports which are not found in any
+// import root of the file, on their path.
+func DiagnoseUnresolvedImports(proto_file view.ProtoFile) (res []defines.Diagnostic) {
+	if proto_file.Proto() == nil {
+		return nil
+	}
+	for _, im := range proto_file.Proto().Imports() {
+		if _, _, err := view.ViewManager.ResolveImport(proto_file.URI(), im.ProtoImport.Filename); err == nil {
+			continue
+		}
+		rng, ok := importPathRange(proto_file.Proto(), im)
+		if !ok {
+			rng = importRange(proto_file, im)
+		}
+		severity := defines.DiagnosticSeverityError
+		res = append(res, defines.Diagnostic{
+			Range:    rng,
+			Severity: &severity,
+			Code:     DiagnosticCodeUnresolvedImport,
+			Message:  fmt.Sprintf("import %q was not found", im.ProtoImport.Filename),
+		})
+	}
+	return res
+}
+
+// unusedImports returns the imports of proto_file, except public and weak
+// ones, none of whose exported files defines something proto_file uses.
+func unusedImports(proto_file view.ProtoFile) (res []*parser.Import) {
+	used := usedFiles(proto_file)
+	for _, im := range proto_file.Proto().Imports() {
+		if im.ProtoImport.Kind == "public" || im.ProtoImport.Kind == "weak" {
+			continue
+		}
+		import_uri, err := view.ViewManager.GetDocumentUriFromImportPath(proto_file.URI(), im.ProtoImport.Filename)
+		if err != nil {
+			continue
+		}
+		isUsed := false
+		for _, document_uri := range exportedFiles(import_uri) {
+			if used[string(document_uri)] {
+				isUsed = true
+				break
+			}
+		}
+		if !isUsed {
+			res = append(res, im)
+		}
+	}
+	return res
+}
+
+// usedFiles returns the files defining a type or an option extension used by
+// proto_file.
+func usedFiles(proto_file view.ProtoFile) map[string]bool {
+	used := make(map[string]bool)
+	for _, ref := range typeReferences(proto_file) {
+		if types.IsBuildInProtoType(ref.name) {
+			continue
+		}
+		for _, def := range resolveType(proto_file, ref.scope, ref.name) {
+			used[def.Filename] = true
+		}
+	}
+	walkOptions(proto_file.Proto().Protobuf().Elements, func(o *protobuf.Option) {
+		if name := optionExtension(o.Name); name != "" {
+			if ext := resolveExtension(proto_file, name); ext != nil {
+				used[string(ext.file.URI())] = true
+			}
+		}
+	})
+	return used
+}
+
+// exportedFiles returns document_uri and, transitively, its public imports,
+// everything importing document_uri makes visible.
+func exportedFiles(document_uri defines.DocumentUri) []defines.DocumentUri {
+	res := []defines.DocumentUri{document_uri}
+	seen := map[defines.DocumentUri]bool{document_uri: true}
+	for i := 0; i < len(res); i++ {
+		file, err := view.ViewManager.GetFile(res[i])
+		if err != nil || file.Proto() == nil {
+			continue
+		}
+		for _, im := range file.Proto().Imports() {
+			if im.ProtoImport.Kind != "public" {
+				continue
+			}
+			import_uri, err := view.ViewManager.GetDocumentUriFromImportPath(file.URI(), im.ProtoImport.Filename)
+			if err != nil || seen[import_uri] {
+				continue
+			}
+			seen[import_uri] = true
+			res = append(res, import_uri)
+		}
+	}
+	return res
+}
+
+// optionExtension returns the extension an option name like (foo.bar).baz
+// refers to, foo.bar, or "" for a builtin option.
+func optionExtension(name string) string {
+	end := strings.IndexByte(name, ')')
+	if !strings.HasPrefix(name, "(") || end == -1 {
+		return ""
+	}
+	return name[1:end]
+}
+
+// scopedNames returns the fully qualified names a relative name may refer to
+// from the package of proto_file, innermost first.
+func scopedNames(proto_file view.ProtoFile, name string) []string {
+	if strings.HasPrefix(name, ".") {
+		return []string{name[1:]}
+	}
+	var res []string
+	pkg := filePackage(proto_file)
+	for pkg != "" {
+		res = append(res, pkg+"."+name)
+		pos := strings.LastIndex(pkg, ".")
+		if pos == -1 {
+			pkg = ""
+		} else {
+			pkg = pkg[:pos]
+		}
+	}
+	return append(res, name)
+}
+
+// importRange returns the range of the import statement.
+func importRange(proto_file view.ProtoFile, im *parser.Import) defines.Range {
+	line := proto_file.ReadLine(im.ProtoImport.Position.Line - 1)
+	start := im.ProtoImport.Position.Column - 1
+	end := len(line)
+	if idx := strings.IndexByte(line[start:], ';'); idx != -1 {
+		end = start + idx + 1
+	}
+	return defines.Range{
+		Start: defines.Position{Line: uint(im.ProtoImport.Position.Line - 1), Character: uint(start)},
+		End:   defines.Position{Line: uint(im.ProtoImport.Position.Line - 1), Character: uint(end)},
+	}
+}
+
+// importLines returns the first and last line of an import with its leading
+// comment. ok is false when the import shares a line with another statement.
+func importLines(proto_file view.ProtoFile, im *parser.Import) (first, last int, ok bool) {
+	rng := importRange(proto_file, im)
+	line := proto_file.ReadLine(int(rng.Start.Line))
+	rest := strings.TrimSpace(line[rng.End.Character:])
+	if strings.TrimSpace(line[:rng.Start.Character]) != "" ||
+		rest != "" && !strings.HasPrefix(rest, "//") && !strings.HasPrefix(rest, "/*") {
+		return 0, 0, false
+	}
+	first = int(rng.Start.Line)
+	if im.ProtoImport.Comment != nil {
+		first = im.ProtoImport.Comment.Position.Line - 1
+	}
+	return first, int(rng.Start.Line), true
+}
+
+// removeImportEdit deletes the lines of an import, or only its statement when
+// it shares a line.
+func removeImportEdit(proto_file view.ProtoFile, im *parser.Import) defines.TextEdit {
+	first, last, ok := importLines(proto_file, im)
+	if !ok {
+		return defines.TextEdit{Range: importRange(proto_file, im)}
+	}
+	return defines.TextEdit{Range: defines.Range{
+		Start: defines.Position{Line: uint(first)},
+		End:   defines.Position{Line: uint(last + 1)},
+	}}
+}
+
+// removeUnusedImportActions offers to remove the import of each unused-import
+// diagnostic, and all unused imports at once when there are several.
+func removeUnusedImportActions(proto_file view.ProtoFile, diagnostics []defines.Diagnostic) (res []defines.CodeAction) {
+	unused := unusedImports(proto_file)
+	if len(unused) == 0 {
+		return nil
+	}
+	kind := defines.CodeActionKindQuickFix
+
+	var fixed []defines.Diagnostic
+	for _, diagnostic := range diagnostics {
+		for _, im := range unused {
+			if importRange(proto_file, im).Start.Line != diagnostic.Range.Start.Line {
+				continue
+			}
+			changes := map[string][]defines.TextEdit{
+				string(proto_file.URI()): {removeImportEdit(proto_file, im)},
+			}
+			res = append(res, defines.CodeAction{
+				Title:       fmt.Sprintf("Remove unused import %q", im.ProtoImport.Filename),
+				Kind:        &kind,
+				Diagnostics: &[]defines.Diagnostic{diagnostic},
+				Edit:        &defines.WorkspaceEdit{Changes: &changes},
+			})
+			fixed = append(fixed, diagnostic)
+			break
+		}
+	}
+
+	if len(fixed) > 0 && len(unused) > 1 {
+		var edits []defines.TextEdit
+		for _, im := range unused {
+			edits = append(edits, removeImportEdit(proto_file, im))
+		}
+		changes := map[string][]defines.TextEdit{string(proto_file.URI()): edits}
+		res = append(res, defines.CodeAction{
+			Title:       "Remove all unused imports",
+			Kind:        &kind,
+			Diagnostics: &fixed,
+			Edit:        &defines.WorkspaceEdit{Changes: &changes},
+		})
+	}
+	return res
+}
+
+// organizeImportsAction rewrites the import block: duplicates are dropped,
+// imports are sorted and grouped into google/, third-party and local ones.
+// Other statements found between imports move after the block. There is no
+// action when the imports are organized already.
+func organizeImportsAction(proto_file view.ProtoFile) *defines.CodeAction {
+	type importEntry struct {
+		im      *parser.Import
+		comment []string
+		line    string
+		group   int
+	}
+
+	imports := proto_file.Proto().Imports()
+	if len(imports) == 0 {
+		return nil
+	}
+	first, last := -1, -1
+	covered := make(map[int]bool)
+	entries := make(map[string]*importEntry)
+	for _, im := range imports {
+		from, to, ok := importLines(proto_file, im)
+		if !ok {
+			return nil
+		}
+		if first == -1 || from < first {
+			first = from
+		}
+		if to > last {
+			last = to
+		}
+		var comment []string
+		for i := from; i <= to; i++ {
+			covered[i] = true
+			if i < to {
+				comment = append(comment, strings.TrimSpace(proto_file.ReadLine(i)))
+			}
+		}
+		line := strings.TrimSpace(proto_file.ReadLine(to))
+
+		// of duplicates keep the strongest, public then regular then weak,
+		// and the first comment
+		filename := im.ProtoImport.Filename
+		if previous, ok := entries[filename]; ok {
+			if len(previous.comment) == 0 {
+				previous.comment = comment
+			}
+			if importStrength(im) > importStrength(previous.im) {
+				previous.im, previous.line = im, line
+			}
+			continue
+		}
+		entries[filename] = &importEntry{im: im, comment: comment, line: line, group: importGroup(proto_file, filename)}
+	}
+
+	sorted := make([]*importEntry, 0, len(entries))
+	for _, entry := range entries {
+		sorted = append(sorted, entry)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].group != sorted[j].group {
+			return sorted[i].group < sorted[j].group
+		}
+		return sorted[i].im.ProtoImport.Filename < sorted[j].im.ProtoImport.Filename
+	})
+
+	var sb strings.Builder
+	for i, entry := range sorted {
+		if i > 0 && entry.group != sorted[i-1].group {
+			sb.WriteString("\n")
+		}
+		for _, line := range entry.comment {
+			sb.WriteString(line + "\n")
+		}
+		sb.WriteString(entry.line + "\n")
+	}
+	var rest []string
+	for i := first; i <= last; i++ {
+		if line := strings.TrimRight(proto_file.ReadLine(i), " \t\r"); !covered[i] && strings.TrimSpace(line) != "" {
+			rest = append(rest, line)
+		}
+	}
+	if len(rest) > 0 {
+		sb.WriteString("\n" + strings.Join(rest, "\n") + "\n")
+	}
+
+	var old strings.Builder
+	for i := first; i <= last; i++ {
+		old.WriteString(strings.TrimRight(proto_file.ReadLine(i), "\r") + "\n")
+	}
+	if old.String() == sb.String() {
+		return nil
+	}
+
+	kind := defines.CodeActionKindSourceOrganizeImports
+	changes := map[string][]defines.TextEdit{
+		string(proto_file.URI()): {{
+			Range: defines.Range{
+				Start: defines.Position{Line: uint(first)},
+				End:   defines.Position{Line: uint(last + 1)},
+			},
+			NewText: sb.String(),
+		}},
+	}
+	return &defines.CodeAction{
+		Title: "Organize imports",
+		Kind:  &kind,
+		Edit:  &defines.WorkspaceEdit{Changes: &changes},
+	}
+}
+
+func importStrength(im *parser.Import) int {
+	switch im.ProtoImport.Kind {
+	case "public":
+		return 2
+	case "weak":
+		return 0
+	}
+	return 1
+}
+
+// importGroup tells google/ imports, imports of files outside the workspace
+// or under an additional proto dir, and imports of the project apart.
+func importGroup(proto_file view.ProtoFile, filename string) int {
+	if strings.HasPrefix(filename, "google/") {
+		return importGroupGoogle
+	}
+	import_uri, err := view.ViewManager.GetDocumentUriFromImportPath(proto_file.URI(), filename)
+	if err != nil || view.ViewManager.IsThirdParty(import_uri) {
+		return importGroupThirdParty
+	}
+	return importGroupLocal
+}
+
+var importPathRegex = regexp.MustCompile(`(^|[;}\s])import\s+((public|weak)\s+)?"([^"]*)$`)
+
+// importPathPrefix returns the path typed so far when the cursor at the end
+// of line is inside the string of an import statement.
+func importPathPrefix(line []byte) (string, bool) {
+	match := importPathRegex.FindSubmatch(line)
+	if match == nil {
+		return "", false
+	}
+	return string(match[4]), true
+}
+
+// importPathCompletionItems offers the directories and proto files which
+// continue the typed import path, in every import root of proto_file. Files
+// imported already are left out, files preview the package they declare.
+// rest is the text after the cursor.
+func importPathCompletionItems(proto_file view.ProtoFile, typed string, rest []byte, position defines.Position) (res []defines.CompletionItem) {
+	dir, segment := "", typed
+	if i := strings.LastIndex(typed, "/"); i != -1 {
+		dir, segment = typed[:i], typed[i+1:]
+	}
+	if end := bytes.IndexByte(rest, '\n'); end != -1 {
+		rest = rest[:end]
+	}
+	// the rest of the segment under the cursor is replaced too
+	after := bytes.IndexAny(rest, "\"/")
+	if after == -1 {
+		after = len(rest)
+	}
+	word_range := defines.Range{Start: position, End: position}
+	word_range.Start.Character -= uint(len(segment))
+	word_range.End.Character += uint(after)
+	closed := bytes.IndexByte(rest, '"') != -1
+	slashed := after < len(rest) && rest[after] == '/'
+
+	imported := map[defines.DocumentUri]bool{proto_file.URI(): true}
+	for _, im := range proto_file.Proto().Imports() {
+		if import_uri, err := view.ViewManager.GetDocumentUriFromImportPath(proto_file.URI(), im.ProtoImport.Filename); err == nil {
+			imported[import_uri] = true
+		}
+	}
+
+	for _, entry := range view.ViewManager.ImportPathEntries(proto_file.URI(), dir) {
+		if entry.IsDir {
+			label := entry.Name + "/"
+			newText := label
+			if slashed {
+				newText = entry.Name
+			}
+			res = append(res, defines.CompletionItem{
+				Label:      label,
+				Kind:       &kindFolder,
+				FilterText: &entry.Name,
+				TextEdit:   defines.TextEdit{Range: word_range, NewText: newText},
+			})
+			continue
+		}
+		if imported[entry.DocumentUri] {
+			continue
+		}
+		import_path := path.Join(dir, entry.Name)
+		detail := "no package"
+		if file, err := view.ViewManager.PeekFile(entry.DocumentUri); err == nil && file.Proto() != nil {
+			if pkg := filePackage(file); pkg != "" {
+				detail = "package " + pkg
+			}
+		}
+		newText := entry.Name
+		if !closed {
+			newText += "\";"
+		}
+		res = append(res, defines.CompletionItem{
+			Label:      entry.Name,
+			Kind:       &kindFile,
+			Detail:     &detail,
+			FilterText: &entry.Name,
+			TextEdit:   defines.TextEdit{Range: word_range, NewText: newText},
+			Documentation: defines.MarkupContent{
+				Kind:  defines.MarkupKindMarkdown,
+				Value: fmt.Sprintf("```proto\nimport %q;\n```\nfound in `%s`", import_path, entry.Root),
+			},
+		})
+	}
+	return res
+}
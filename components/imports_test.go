@@ -0,0 +1,98 @@
+package components
+
+import (
+	"testing"
+
+	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_removeImportEdit(t *testing.T) {
+	content := `syntax = "proto3";
+package foo;
+// a is needed for A
+import "a/a.proto";
+import "b/b.proto"; import "c/c.proto";
+`
+	proto_file := newMockProtoFile(t, "file:///foo.proto", content)
+	imports := proto_file.Proto().Imports()
+	require.Len(t, imports, 3)
+
+	lines := func(from, to uint) defines.Range {
+		return defines.Range{Start: defines.Position{Line: from}, End: defines.Position{Line: to}}
+	}
+	require.Equal(t, lines(2, 4), removeImportEdit(proto_file, imports[0]).Range)
+	// sharing a line, only the statement goes
+	require.Equal(t, defines.Range{
+		Start: defines.Position{Line: 4, Character: 0},
+		End:   defines.Position{Line: 4, Character: 19},
+	}, removeImportEdit(proto_file, imports[1]).Range)
+	require.Equal(t, defines.Range{
+		Start: defines.Position{Line: 4, Character: 20},
+		End:   defines.Position{Line: 4, Character: 39},
+	}, removeImportEdit(proto_file, imports[2]).Range)
+}
+
+func Test_unusedImports(t *testing.T) {
+	content := `syntax = "proto3";
+import public "a.proto";
+import weak "b.proto";
+`
+	proto_file := newMockProtoFile(t, "file:///test.proto", content)
+	// public and weak imports are kept even when nothing uses them
+	require.Empty(t, unusedImports(proto_file))
+}
+
+func Test_optionExtension(t *testing.T) {
+	require.Equal(t, "", optionExtension("deprecated"))
+	require.Equal(t, "foo.bar", optionExtension("(foo.bar)"))
+	require.Equal(t, ".foo.bar", optionExtension("(.foo.bar).baz"))
+}
+
+func Test_extensionFields(t *testing.T) {
+	content := `syntax = "proto3";
+package foo.options;
+import "google/protobuf/descriptor.proto";
+extend google.protobuf.FieldOptions {
+  string column = 50001;
+}
+message Scope {
+  extend google.protobuf.MessageOptions {
+    bool table = 50002;
+  }
+}
+`
+	proto_file := newMockProtoFile(t, "file:///foo.proto", content)
+	var got []string
+	for _, ext := range extensionFields(proto_file) {
+		got = append(got, ext.fullName+" "+ext.extendee)
+	}
+	require.Equal(t, []string{
+		"foo.options.column google.protobuf.FieldOptions",
+		"foo.options.Scope.table google.protobuf.MessageOptions",
+	}, got)
+	require.Equal(t, []string{"foo.options.column", "foo.column", "column"}, scopedNames(proto_file, "column"))
+	require.Equal(t, []string{"foo.options.column"}, scopedNames(proto_file, ".foo.options.column"))
+}
+
+func Test_importPathPrefix(t *testing.T) {
+	tests := []struct {
+		line   string
+		want   string
+		wantOk bool
+	}{
+		{`import "`, "", true},
+		{`import "google/proto`, "google/proto", true},
+		{`  import public "a/`, "a/", true},
+		{`import weak "a`, "a", true},
+		{`import "a.proto"; import "b/`, "b/", true},
+		{`import "a.proto";`, "", false},
+		{`option go_package = "a/`, "", false},
+		{`message importer { string a = 1 [json_name = "`, "", false},
+	}
+	for _, tt := range tests {
+		got, ok := importPathPrefix([]byte(tt.line))
+		require.Equal(t, tt.wantOk, ok, tt.line)
+		require.Equal(t, tt.want, got, tt.line)
+	}
+}
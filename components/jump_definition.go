@@ -10,7 +10,6 @@ import (
 	"github.com/lasorda/protobuf-language-server/proto/parser"
 	"github.com/lasorda/protobuf-language-server/proto/view"
 
-	"github.com/lasorda/protobuf-language-server/go-lsp/logs"
 	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
 )
 
@@ -21,12 +20,16 @@ type SymbolDefinition struct {
 	Enum      parser.Enum
 	Message   parser.Message
 	ImportUri string
+	// the range of the name of a declaration other than a message or enum
+	Range defines.Range
 }
 
 const (
 	DefinitionTypeImport  = "import"
 	DefinitionTypeMessage = "message"
 	DefinitionTypeEnum    = "enum"
+	// a field, oneof, enum value, service or rpc
+	DefinitionTypeDeclaration = "declaration"
 )
 
 var ErrSymbolNotFound = errors.New("symbol not found")
@@ -50,6 +53,12 @@ func locationFromSymbols(symbols []SymbolDefinition) (result []defines.LocationL
 			result = append(result, defines.LocationLink{
 				TargetUri: defines.DocumentUri(symbol.ImportUri),
 			})
+		case DefinitionTypeDeclaration:
+			result = append(result, defines.LocationLink{
+				TargetUri:            defines.DocumentUri(symbol.Filename),
+				TargetSelectionRange: symbol.Range,
+				TargetRange:          symbol.Range,
+			})
 		case DefinitionTypeEnum:
 			proto := symbol.Enum.Protobuf()
 			tr := defines.Range{
@@ -95,8 +104,8 @@ func findSymbolDefinition(ctx context.Context, position *defines.TextDocumentPos
 		return JumpProtoDefine(ctx, position)
 	}
 
-	if view.IsPbHeader(position.TextDocument.Uri) {
-		return JumpPbHeaderDefine(ctx, position)
+	if view.IsGeneratedFile(position.TextDocument.Uri) {
+		return JumpGeneratedDefine(ctx, position)
 	}
 	if !view.IsProtoFile(position.TextDocument.Uri) {
 		return nil, nil
@@ -104,27 +113,6 @@ func findSymbolDefinition(ctx context.Context, position *defines.TextDocumentPos
 	return nil, ErrSymbolNotFound
 }
 
-func JumpPbHeaderDefine(ctx context.Context, req *defines.TextDocumentPositionParams) (result []SymbolDefinition, err error) {
-	proto_uri := strings.ReplaceAll(string(req.TextDocument.Uri), "bazel-out/local_linux-fastbuild/genfiles/", "")
-	proto_uri = strings.ReplaceAll(proto_uri, ".pb.h", ".proto")
-	proto_file, err := view.ViewManager.GetFile(defines.DocumentUri(proto_uri))
-	if err != nil {
-		return nil, err
-	}
-	line := view.ViewManager.GetPbHeaderLine(req.TextDocument.Uri, int(req.Position.Line))
-	word := getWord(line, int(req.Position.Character), false)
-	logs.Printf("line %v, word %v", line, word)
-	res, err := searchType(proto_file, word)
-	// better than nothing
-	if (res == nil || len(res) == 0) && strings.Contains(word, "_") {
-		split_res := strings.Split(word, "_")
-		if len(split_res) > 0 {
-			res, err = searchType(proto_file, split_res[0])
-		}
-	}
-	return res, err
-}
-
 func JumpProtoDefine(ctx context.Context, position *defines.TextDocumentPositionParams) (result []SymbolDefinition, err error) {
 	proto_file, err := view.ViewManager.GetFile(position.TextDocument.Uri)
 
@@ -150,6 +138,11 @@ func JumpProtoDefine(ctx context.Context, position *defines.TextDocumentPosition
 		my_package = proto_file.Proto().Packages()[0].ProtoPackage.Name
 	}
 
+	scope, _ := proto_file.Proto().GetParentMessageByLine(int(position.Position.Line + 1))
+	if res := resolveType(proto_file, scope, package_and_word); len(res) > 0 {
+		return res, nil
+	}
+
 	var package_name, word string
 	word_only := true
 	if pos == -1 {
@@ -235,13 +228,15 @@ func qualifierReferencesPackage(query_pkg string, candidate_pkg string, current_
 	return current_pkg == prefix || strings.HasPrefix(current_pkg, prefix+".")
 }
 
+// importPathRegexp matches the quoted path of an import.
+var importPathRegexp = regexp.MustCompile(`["']([^"']+)["']`)
+
 func jumpImport(ctx context.Context, position *defines.TextDocumentPositionParams, line_str string) (result []SymbolDefinition, err error) {
-	r, _ := regexp.Compile("\"(.+)\\/([^\\/]+)\"")
-	pos := r.FindStringIndex(line_str)
-	if pos == nil {
+	matches := importPathRegexp.FindStringSubmatch(line_str)
+	if matches == nil {
 		return nil, fmt.Errorf("import match failed")
 	}
-	import_uri, err := view.ViewManager.GetDocumentUriFromImportPath(position.TextDocument.Uri, line_str[pos[0]+1:pos[1]-1])
+	import_uri, err := view.ViewManager.GetDocumentUriFromImportPath(position.TextDocument.Uri, matches[1])
 	if err != nil {
 		return nil, err
 	}
@@ -298,36 +293,33 @@ func searchType(proto_file view.ProtoFile, word string) (result []SymbolDefiniti
 }
 
 func messageSymbolDefinition(proto_file view.ProtoFile, message parser.Message) SymbolDefinition {
-	line := proto_file.ReadLine(message.Protobuf().Position.Line - 1)
-	symbolStart := strings.Index(line, message.Protobuf().Name)
 	return SymbolDefinition{
 		Filename: string(proto_file.URI()),
-		Position: defines.Position{
-			Line:      uint(message.Protobuf().Position.Line - 1),
-			Character: uint(symbolStart),
-		},
-		Type:    DefinitionTypeMessage,
-		Message: message,
+		Position: view.TokenRange(proto_file, message.Protobuf().Position, "message", message.Protobuf().Name).Start,
+		Type:     DefinitionTypeMessage,
+		Message:  message,
 	}
 }
 
 func enumSymbolDefinition(proto_file view.ProtoFile, enum parser.Enum) SymbolDefinition {
-	line := proto_file.ReadLine(enum.Protobuf().Position.Line - 1)
-	symbolStart := strings.Index(line, enum.Protobuf().Name)
 	return SymbolDefinition{
 		Filename: string(proto_file.URI()),
-		Position: defines.Position{
-			Line:      uint(enum.Protobuf().Position.Line - 1),
-			Character: uint(symbolStart),
-		},
-		Type: DefinitionTypeEnum,
-		Enum: enum,
+		Position: view.TokenRange(proto_file, enum.Protobuf().Position, "enum", enum.Protobuf().Name).Start,
+		Type:     DefinitionTypeEnum,
+		Enum:     enum,
 	}
 }
 
 func getWord(line string, idx int, includeDot bool) string {
+	l, r := getWordRange(line, idx, includeDot)
+	return line[l:r]
+}
+
+// getWordRange returns the byte range of the word around idx, it is empty when
+// there is no word at idx.
+func getWordRange(line string, idx int, includeDot bool) (int, int) {
 	if len(line) == 0 {
-		return ""
+		return 0, 0
 	}
 	if idx < 0 {
 		idx = 0
@@ -351,5 +343,5 @@ func getWord(line string, idx int, includeDot bool) string {
 	for r < len(line) && isWordChar(line[r]) {
 		r++
 	}
-	return line[l:r]
+	return l, r
 }
@@ -0,0 +1,508 @@
+package components
+
+import (
+	"fmt"
+	"strings"
+
+	protobuf "github.com/emicklei/proto"
+	"github.com/lasorda/protobuf-language-server/proto/parser"
+	"github.com/lasorda/protobuf-language-server/proto/types"
+	"github.com/lasorda/protobuf-language-server/proto/view"
+
+	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
+)
+
+// options messages of google/protobuf/descriptor.proto
+const (
+	fileOptions      = "FileOptions"
+	messageOptions   = "MessageOptions"
+	fieldOptions     = "FieldOptions"
+	oneofOptions     = "OneofOptions"
+	enumOptions      = "EnumOptions"
+	enumValueOptions = "EnumValueOptions"
+	serviceOptions   = "ServiceOptions"
+	methodOptions    = "MethodOptions"
+)
+
+// builtinOption is an option declared in google/protobuf/descriptor.proto.
+type builtinOption struct {
+	name string
+	// bool, string or the name of an enum
+	typeName string
+	// constants of an enum type
+	values []string
+	doc    string
+}
+
+var deprecatedOption = builtinOption{"deprecated", "bool", nil,
+	"Marks the declaration as deprecated, generated code may carry a deprecation annotation."}
+
+// builtinOptions are the options of every options message, as declared in
+// google/protobuf/descriptor.proto.
+var builtinOptions = map[string][]builtinOption{
+	fileOptions: {
+		{"java_package", "string", nil, "The Java package the generated classes are placed in, the proto package is used when not set."},
+		{"java_outer_classname", "string", nil, "The name of the Java class wrapping the generated code of the file, derived from the file name when not set."},
+		{"java_multiple_files", "bool", nil, "Generates a separate .java file for each top-level message, enum and service instead of nesting them in the outer class."},
+		{"java_string_check_utf8", "bool", nil, "Checks that string fields hold valid UTF-8 when parsing and serializing in Java."},
+		{"optimize_for", "OptimizeMode", []string{"SPEED", "CODE_SIZE", "LITE_RUNTIME"}, "Generates code optimized for speed, for size, or for the lite runtime."},
+		{"go_package", "string", nil, "The Go import path of the generated package, optionally followed by `;` and the package name."},
+		{"cc_generic_services", "bool", nil, "Generates abstract C++ service classes, deprecated in favour of plugins."},
+		{"java_generic_services", "bool", nil, "Generates abstract Java service classes, deprecated in favour of plugins."},
+		{"py_generic_services", "bool", nil, "Generates abstract Python service classes, deprecated in favour of plugins."},
+		{deprecatedOption.name, deprecatedOption.typeName, nil, "Marks every declaration of the file as deprecated."},
+		{"cc_enable_arenas", "bool", nil, "Enables arena allocation for the generated C++ classes."},
+		{"objc_class_prefix", "string", nil, "The prefix of the generated Objective-C classes."},
+		{"csharp_namespace", "string", nil, "The namespace of the generated C# classes."},
+		{"swift_prefix", "string", nil, "The prefix of the generated Swift types."},
+		{"php_class_prefix", "string", nil, "The prefix of the generated PHP classes."},
+		{"php_namespace", "string", nil, "The namespace of the generated PHP classes."},
+		{"php_metadata_namespace", "string", nil, "The namespace of the generated PHP metadata classes."},
+		{"ruby_package", "string", nil, "The Ruby package of the generated classes."},
+	},
+	messageOptions: {
+		{"message_set_wire_format", "bool", nil, "Uses the old MessageSet wire format, for compatibility with legacy code only."},
+		{"no_standard_descriptor_accessor", "bool", nil, "Disables the standard `descriptor()` accessor, to avoid name clashes with a field named descriptor."},
+		deprecatedOption,
+		{"map_entry", "bool", nil, "Marks a message generated for a map field, set by the compiler and not to be used in .proto files."},
+	},
+	fieldOptions: {
+		{"ctype", "CType", []string{"STRING", "CORD", "STRING_PIECE"}, "The C++ representation of a string or bytes field."},
+		{"packed", "bool", nil, "Encodes a repeated scalar field as a single length-delimited record, the default in proto3."},
+		{"jstype", "JSType", []string{"JS_NORMAL", "JS_STRING", "JS_NUMBER"}, "The JavaScript type of a 64-bit integer field."},
+		{"lazy", "bool", nil, "Parses a message field lazily, on first access."},
+		{"unverified_lazy", "bool", nil, "Parses a message field lazily without checking it on the eager parse."},
+		deprecatedOption,
+		{"weak", "bool", nil, "Marks the field for weak imports, for Google-internal migration only."},
+		{"debug_redact", "bool", nil, "Redacts the field in the debug output of the message."},
+		{"retention", "OptionRetention", []string{"RETENTION_UNKNOWN", "RETENTION_RUNTIME", "RETENTION_SOURCE"}, "Whether an option field is kept in the descriptors available at runtime."},
+		{"default", "", nil, "The default value of an optional proto2 field."},
+		{"json_name", "string", nil, "The name of the field in the JSON mapping, the lowerCamelCase field name when not set."},
+	},
+	oneofOptions: {},
+	enumOptions: {
+		{"allow_alias", "bool", nil, "Allows several values of the enum to share a number."},
+		deprecatedOption,
+	},
+	enumValueOptions: {
+		deprecatedOption,
+		{"debug_redact", "bool", nil, "Redacts the value in the debug output of messages."},
+	},
+	serviceOptions: {
+		deprecatedOption,
+	},
+	methodOptions: {
+		deprecatedOption,
+		{"idempotency_level", "IdempotencyLevel", []string{"IDEMPOTENCY_UNKNOWN", "NO_SIDE_EFFECTS", "IDEMPOTENT"}, "Whether the method has side effects, allowing clients to retry or cache calls."},
+	},
+}
+
+// lookupBuiltinOption returns the builtin option of the options message, or
+// of any options message when options is "".
+func lookupBuiltinOption(options, name string) (builtinOption, bool) {
+	for level, list := range builtinOptions {
+		if options != "" && level != options {
+			continue
+		}
+		for _, o := range list {
+			if o.name == name {
+				return o, true
+			}
+		}
+	}
+	return builtinOption{}, false
+}
+
+// extensionField is a field declared in an extend block.
+type extensionField struct {
+	fullName string
+	// the extended message as written, without a leading dot
+	extendee string
+	field    *protobuf.NormalField
+	// the message around the extend, nil at top level
+	scope parser.Message
+	file  view.ProtoFile
+}
+
+// extensionFields lists the extension fields declared in proto_file.
+func extensionFields(proto_file view.ProtoFile) (res []extensionField) {
+	var walk func(prefix string, scope parser.Message, messages []parser.Message)
+	walk = func(prefix string, scope parser.Message, messages []parser.Message) {
+		for _, message := range messages {
+			if !message.Protobuf().IsExtend {
+				walk(prefix+message.Protobuf().Name+".", message, message.NestedMessages())
+				continue
+			}
+			for _, f := range message.Fields() {
+				res = append(res, extensionField{
+					fullName: prefix + f.ProtoField.Name,
+					extendee: strings.TrimPrefix(message.Protobuf().Name, "."),
+					field:    f.ProtoField,
+					scope:    scope,
+					file:     proto_file,
+				})
+			}
+		}
+	}
+	prefix := ""
+	if pkg := filePackage(proto_file); pkg != "" {
+		prefix = pkg + "."
+	}
+	walk(prefix, nil, proto_file.Proto().Messages())
+	return res
+}
+
+// extends tells whether the extension extends the options message of
+// descriptor.proto.
+func (ext extensionField) extends(options string) bool {
+	return ext.extendee == "google.protobuf."+options ||
+		ext.extendee == options && filePackage(ext.file) == "google.protobuf"
+}
+
+// resolveExtension returns the extension field visible from proto_file which
+// name refers to, or nil.
+func resolveExtension(proto_file view.ProtoFile, name string) *extensionField {
+	files, _ := visibleFiles(proto_file)
+	for _, fullName := range scopedNames(proto_file, name) {
+		for _, file := range files {
+			for _, ext := range extensionFields(file) {
+				if ext.fullName == fullName {
+					return &ext
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// customOptions lists the extensions of the options message visible from
+// proto_file.
+func customOptions(proto_file view.ProtoFile, options string) (res []extensionField) {
+	files, _ := visibleFiles(proto_file)
+	for _, file := range files {
+		for _, ext := range extensionFields(file) {
+			if ext.extends(options) {
+				res = append(res, ext)
+			}
+		}
+	}
+	return res
+}
+
+// optionType is the type of an option or of a field of an aggregate option
+// value, resolved from scope in file.
+type optionType struct {
+	typeName string
+	file     view.ProtoFile
+	scope    parser.Message
+	builtin  *builtinOption
+}
+
+// resolve returns the message or enum declaring the type, with its file.
+func (t optionType) resolve() (parser.Message, parser.Enum, view.ProtoFile) {
+	if t.builtin != nil || types.IsBuildInProtoType(t.typeName) {
+		return nil, nil, nil
+	}
+	defs := resolveType(t.file, t.scope, t.typeName)
+	if len(defs) == 0 {
+		return nil, nil, nil
+	}
+	file := t.file
+	if defs[0].Filename != string(t.file.URI()) {
+		other, err := view.ViewManager.GetFile(defines.DocumentUri(defs[0].Filename))
+		if err != nil {
+			return nil, nil, nil
+		}
+		file = other
+	}
+	return defs[0].Message, defs[0].Enum, file
+}
+
+// lookupOptionType returns the type of the option name, like deprecated or
+// (foo.bar).baz, followed by the fields of path into its aggregate value.
+func lookupOptionType(proto_file view.ProtoFile, options, name string, path []string) (optionType, bool) {
+	ext := optionExtension(name)
+	if ext == "" {
+		o, ok := lookupBuiltinOption(options, name)
+		if !ok || len(path) > 0 {
+			return optionType{}, false
+		}
+		return optionType{typeName: o.typeName, builtin: &o}, true
+	}
+	field := resolveExtension(proto_file, ext)
+	if field == nil {
+		return optionType{}, false
+	}
+	t := optionType{typeName: field.field.Type, file: field.file, scope: field.scope}
+
+	// fields after the parenthesis, (foo.bar).baz
+	if rest := strings.TrimPrefix(name[strings.IndexByte(name, ')')+1:], "."); rest != "" {
+		path = append(strings.Split(rest, "."), path...)
+	}
+	for _, key := range path {
+		message, _, file := t.resolve()
+		if message == nil {
+			return optionType{}, false
+		}
+		found := false
+		for _, f := range messageFields(message) {
+			if f.name == key {
+				t, found = optionType{typeName: f.typeName, file: file, scope: message}, true
+				break
+			}
+		}
+		if !found {
+			return optionType{}, false
+		}
+	}
+	return t, true
+}
+
+type messageFieldInfo struct {
+	name     string
+	typeName string
+	field    protobuf.Visitee
+	comment  *protobuf.Comment
+}
+
+// messageFields lists the normal, map and oneof fields of message.
+func messageFields(message parser.Message) (res []messageFieldInfo) {
+	for _, e := range message.Protobuf().Elements {
+		switch v := e.(type) {
+		case *protobuf.NormalField:
+			res = append(res, messageFieldInfo{v.Name, v.Type, v, v.Comment})
+		case *protobuf.MapField:
+			res = append(res, messageFieldInfo{v.Name, fmt.Sprintf("map<%s, %s>", v.KeyType, v.Type), v, v.Comment})
+		case *protobuf.Oneof:
+			for _, oe := range v.Elements {
+				if f, ok := oe.(*protobuf.OneOfField); ok {
+					res = append(res, messageFieldInfo{f.Name, f.Type, f, f.Comment})
+				}
+			}
+		}
+	}
+	return res
+}
+
+// optionNameCompletionItems offers the builtin options of the options message
+// and the custom ones visible from proto_file. paren is set when ( is typed
+// already, then only custom options are offered.
+func optionNameCompletionItems(proto_file view.ProtoFile, options string, paren bool, word_range defines.Range) (res []defines.CompletionItem) {
+	kind := defines.CompletionItemKindProperty
+	if !paren {
+		for _, o := range builtinOptions[options] {
+			o := o
+			res = append(res, defines.CompletionItem{
+				Label:      o.name,
+				Kind:       &kind,
+				Detail:     &o.typeName,
+				InsertText: &o.name,
+				Documentation: defines.MarkupContent{
+					Kind:  defines.MarkupKindMarkdown,
+					Value: builtinOptionDoc(options, o),
+				},
+			})
+		}
+	}
+	for _, ext := range customOptions(proto_file, options) {
+		label := "(" + ext.fullName + ")"
+		detail := ext.field.Type
+		res = append(res, defines.CompletionItem{
+			Label:      label,
+			Kind:       &kind,
+			Detail:     &detail,
+			FilterText: &label,
+			TextEdit:   defines.TextEdit{Range: word_range, NewText: label},
+			Documentation: defines.MarkupContent{
+				Kind:  defines.MarkupKindMarkdown,
+				Value: extensionDoc(ext),
+			},
+		})
+	}
+	return res
+}
+
+// optionValueCompletionItems offers the values of the option name or of the
+// field of its aggregate value at path: bool literals or enum constants.
+func optionValueCompletionItems(proto_file view.ProtoFile, options, name string, path []string) (res []defines.CompletionItem) {
+	t, ok := lookupOptionType(proto_file, options, name, path)
+	if !ok {
+		return nil
+	}
+	if t.typeName == "bool" {
+		return keywordCompletionItems("true", "false")
+	}
+	kind := defines.CompletionItemKindEnumMember
+	if t.builtin != nil {
+		for _, value := range t.builtin.values {
+			value := value
+			res = append(res, defines.CompletionItem{Label: value, Kind: &kind, InsertText: &value})
+		}
+		return res
+	}
+	_, enum, _ := t.resolve()
+	if enum == nil {
+		return nil
+	}
+	for _, e := range enum.Protobuf().Elements {
+		if value, ok := e.(*protobuf.EnumField); ok {
+			res = append(res, defines.CompletionItem{Label: value.Name, Kind: &kind, InsertText: &value.Name})
+		}
+	}
+	return res
+}
+
+// aggregateFieldCompletionItems offers the field names of the message typed
+// aggregate value of the option name at path.
+func aggregateFieldCompletionItems(proto_file view.ProtoFile, options, name string, path []string) (res []defines.CompletionItem) {
+	t, ok := lookupOptionType(proto_file, options, name, path)
+	if !ok {
+		return nil
+	}
+	message, _, _ := t.resolve()
+	if message == nil {
+		return nil
+	}
+	kind := defines.CompletionItemKindField
+	for _, f := range messageFields(message) {
+		f := f
+		res = append(res, defines.CompletionItem{
+			Label:      f.name,
+			Kind:       &kind,
+			Detail:     &f.typeName,
+			InsertText: &f.name,
+			Documentation: defines.MarkupContent{
+				Kind:  defines.MarkupKindMarkdown,
+				Value: commentDoc(f.comment),
+			},
+		})
+	}
+	return res
+}
+
+func builtinOptionDoc(options string, o builtinOption) string {
+	doc := fmt.Sprintf("```proto\n%s // google.protobuf.%s\n```\n%s", strings.TrimSpace(o.typeName+" "+o.name), options, o.doc)
+	if len(o.values) > 0 {
+		doc += "\n\nValues: `" + strings.Join(o.values, "`, `") + "`"
+	}
+	return doc
+}
+
+func extensionDoc(ext extensionField) string {
+	doc := fmt.Sprintf("```proto\nextend %s {\n  %s %s = %d;\n}\n```", ext.extendee, ext.field.Type, ext.field.Name, ext.field.Sequence)
+	if comment := commentDoc(ext.field.Comment); comment != "" {
+		doc += "\n" + comment
+	}
+	return doc
+}
+
+func commentDoc(comment *protobuf.Comment) string {
+	if comment == nil {
+		return ""
+	}
+	var lines []string
+	for _, line := range comment.Lines {
+		lines = append(lines, strings.TrimSpace(line))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// optionHover describes the option name at position of proto_file.
+func optionHover(proto_file view.ProtoFile, position defines.Position) (string, bool) {
+	doc, found := "", false
+	walkOptions(proto_file.Proto().Protobuf().Elements, func(o *protobuf.Option) {
+		after := ""
+		if !o.IsEmbedded {
+			after = "option"
+		}
+		if found {
+			return
+		}
+		if value_doc, ok := optionValueHover(proto_file, optionsOf(o), o.Name, nil, o.Constant, position); ok {
+			doc, found = value_doc, true
+			return
+		}
+		r := view.TokenRange(proto_file, o.Position, after, o.Name)
+		if r.Start.Line != position.Line || position.Character < r.Start.Character || position.Character > r.End.Character {
+			return
+		}
+		if ext := optionExtension(o.Name); ext != "" {
+			if field := resolveExtension(proto_file, ext); field != nil {
+				doc, found = extensionDoc(*field), true
+			}
+			return
+		}
+		if builtin, ok := lookupBuiltinOption(optionsOf(o), o.Name); ok {
+			doc, found = builtinOptionDoc(optionsOf(o), builtin), true
+		}
+	})
+	return doc, found
+}
+
+// optionValueHover describes the enum value an option value, or a value
+// nested in its aggregate at path, names when position is on it.
+func optionValueHover(proto_file view.ProtoFile, options, name string, path []string, value protobuf.Literal, position defines.Position) (string, bool) {
+	for _, entry := range value.OrderedMap {
+		if doc, ok := optionValueHover(proto_file, options, name, append(path[:len(path):len(path)], entry.Name), *entry.Literal, position); ok {
+			return doc, true
+		}
+	}
+	for _, element := range value.Array {
+		if doc, ok := optionValueHover(proto_file, options, name, path, *element, position); ok {
+			return doc, true
+		}
+	}
+	if value.IsString || value.Position.Line == 0 || !isIdentifier(value.Source) ||
+		!rangeContains(view.TokenRange(proto_file, value.Position, "", value.Source), position) {
+		return "", false
+	}
+	t, ok := lookupOptionType(proto_file, options, name, path)
+	if !ok {
+		return "", false
+	}
+	_, enum, file := t.resolve()
+	if enum == nil {
+		return "", false
+	}
+	for _, e := range enum.Protobuf().Elements {
+		if v, ok := e.(*protobuf.EnumField); ok && v.Name == value.Source {
+			return declarationHover(file, declaration{name: v.Name, element: v}), true
+		}
+	}
+	return "", false
+}
+
+func isIdentifier(s string) bool {
+	if s == "" || s == "true" || s == "false" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isWordByte(s[i]) || i == 0 && s[i] >= '0' && s[i] <= '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// optionsOf returns the options message of the declaration o belongs to.
+func optionsOf(o *protobuf.Option) string {
+	switch o.Parent.(type) {
+	case *protobuf.Proto:
+		return fileOptions
+	case *protobuf.Message:
+		return messageOptions
+	case *protobuf.NormalField, *protobuf.MapField, *protobuf.OneOfField:
+		return fieldOptions
+	case *protobuf.Oneof:
+		return oneofOptions
+	case *protobuf.Enum:
+		return enumOptions
+	case *protobuf.EnumField:
+		return enumValueOptions
+	case *protobuf.Service:
+		return serviceOptions
+	case *protobuf.RPC:
+		return methodOptions
+	}
+	return ""
+}
@@ -0,0 +1,89 @@
+package components
+
+import (
+	"testing"
+
+	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_getCompletionContextOptions(t *testing.T) {
+	tests := []struct {
+		name          string
+		text          string
+		wantKind      int
+		wantOptions   string
+		wantOption    string
+		wantAggregate []string
+		wantParen     bool
+	}{
+		{"file option", "option ", completionContextOptionName, fileOptions, "", nil, false},
+		{"file option value", "option optimize_for = ", completionContextOptionValue, fileOptions, "optimize_for", nil, false},
+		{"message option", "message A {\n  option (", completionContextOptionName, messageOptions, "", nil, true},
+		{"field option", "message A {\n  int32 a = 1 [", completionContextOptionName, fieldOptions, "", nil, false},
+		{"second field option", "message A {\n  int32 a = 1 [deprecated = true, (", completionContextOptionName, fieldOptions, "", nil, true},
+		{"field option value", "message A {\n  map<string, B> a = 1 [(foo.bar).baz = ", completionContextOptionValue, fieldOptions, "(foo.bar).baz", nil, false},
+		{"enum value option", "enum E {\n  E_A = 0 [", completionContextOptionName, enumValueOptions, "", nil, false},
+		{"enum option", "enum E {\n  option ", completionContextOptionName, enumOptions, "", nil, false},
+		{"oneof option", "message A {\n  oneof o {\n    option ", completionContextOptionName, oneofOptions, "", nil, false},
+		{"service option", "service S {\n  option ", completionContextOptionName, serviceOptions, "", nil, false},
+		{"method option", "service S {\n  rpc A(B) returns (C) {\n    option ", completionContextOptionName, methodOptions, "", nil, false},
+		{"aggregate field", "message A {\n  option (x) = {\n    a: 1\n    ", completionContextAggregateField, messageOptions, "(x)", nil, false},
+		{"aggregate value", "message A {\n  int32 a = 1 [(x) = { a: 1, b: ", completionContextAggregateValue, fieldOptions, "(x)", []string{"b"}, false},
+		{"nested aggregate", "option (x) = { a { b: [{ c: 1 }, { d: { ", completionContextAggregateField, fileOptions, "(x)", []string{"a", "b", "d"}, false},
+		{"after nested aggregate", "option (x) = { a { b: 1 } ", completionContextAggregateField, fileOptions, "(x)", nil, false},
+		{"aggregate field name", "option (x) = { a ", completionContextUnknown, fileOptions, "(x)", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := getCompletionContext([]byte("syntax = \"proto3\";\n" + tt.text))
+			require.Equal(t, tt.wantKind, c.kind)
+			require.Equal(t, tt.wantOptions, c.options)
+			require.Equal(t, tt.wantOption, c.option)
+			require.Equal(t, tt.wantAggregate, c.aggregate)
+			require.Equal(t, tt.wantParen, c.paren)
+		})
+	}
+}
+
+func Test_optionValueCompletionItems(t *testing.T) {
+	content := `syntax = "proto3";
+package acme;
+enum Level {
+  LEVEL_UNSPECIFIED = 0;
+  LEVEL_HIGH = 1;
+}
+message Rule {
+  Level level = 1;
+  Rule child = 2;
+  oneof kind {
+    bool strict = 3;
+  }
+}
+extend google.protobuf.FieldOptions {
+  Rule rule = 50001;
+}
+extend google.protobuf.MessageOptions {
+  string table = 50002;
+}
+`
+	proto_file := newMockProtoFile(t, "file:///acme.proto", content)
+	labels := func(items []defines.CompletionItem) (res []string) {
+		for _, item := range items {
+			res = append(res, item.Label)
+		}
+		return res
+	}
+
+	require.Equal(t, []string{"true", "false"}, labels(optionValueCompletionItems(proto_file, fieldOptions, "deprecated", nil)))
+	require.Equal(t, []string{"JS_NORMAL", "JS_STRING", "JS_NUMBER"}, labels(optionValueCompletionItems(proto_file, fieldOptions, "jstype", nil)))
+	require.Empty(t, optionValueCompletionItems(proto_file, fieldOptions, "optimize_for", nil))
+	require.Equal(t, []string{"LEVEL_UNSPECIFIED", "LEVEL_HIGH"}, labels(optionValueCompletionItems(proto_file, fieldOptions, "(rule)", []string{"child", "level"})))
+	require.Equal(t, []string{"LEVEL_UNSPECIFIED", "LEVEL_HIGH"}, labels(optionValueCompletionItems(proto_file, fieldOptions, "(acme.rule).level", nil)))
+	require.Equal(t, []string{"true", "false"}, labels(optionValueCompletionItems(proto_file, fieldOptions, "(rule)", []string{"strict"})))
+	require.Equal(t, []string{"level", "child", "strict"}, labels(aggregateFieldCompletionItems(proto_file, fieldOptions, "(rule)", []string{"child"})))
+	require.Empty(t, aggregateFieldCompletionItems(proto_file, fieldOptions, "(rule)", []string{"missing"}))
+
+	require.Equal(t, []string{"(acme.table)"}, labels(optionNameCompletionItems(proto_file, messageOptions, true, defines.Range{})))
+	require.Equal(t, []string{"deprecated", "idempotency_level"}, labels(optionNameCompletionItems(proto_file, methodOptions, false, defines.Range{})))
+}
@@ -0,0 +1,118 @@
+package components
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
+	"github.com/lasorda/protobuf-language-server/proto/view"
+)
+
+// definitionKey identifies a message or enum the way sameDefinition compares them.
+type definitionKey struct {
+	uri      string
+	position defines.Position
+	kind     string
+}
+
+func definitionKeyOf(def SymbolDefinition) definitionKey {
+	return definitionKey{uri: def.Filename, position: def.Position, kind: def.Type}
+}
+
+// indexedReference is a type reference resolved to the message or enum it names.
+type indexedReference struct {
+	location defines.Location
+	// the name of the rpc taking or returning the type, nil unless the
+	// reference is the whole request or response type of an rpc
+	rpc *defines.Location
+}
+
+// referenceIndex keeps the resolved type references of every file, they are
+// resolved again when the file or one of the files visible from it changes.
+var referenceIndex = struct {
+	mu    sync.Mutex
+	files map[defines.DocumentUri]fileReferences
+}{files: make(map[defines.DocumentUri]fileReferences)}
+
+type fileReferences struct {
+	hash       string
+	references map[definitionKey][]indexedReference
+}
+
+// ForgetReferences drops the references of a closed or deleted file from the
+// index.
+func ForgetReferences(document_uri defines.DocumentUri) {
+	referenceIndex.mu.Lock()
+	delete(referenceIndex.files, document_uri)
+	referenceIndex.mu.Unlock()
+}
+
+// workspaceReferences returns the references to the message or enum def in
+// the file declaring it and every file importing that one, directly or not.
+func workspaceReferences(def definitionKey) (res []indexedReference) {
+	document_uri := defines.DocumentUri(def.uri)
+	for _, file_uri := range append([]defines.DocumentUri{document_uri}, view.ViewManager.TransitiveImporters(document_uri)...) {
+		proto_file, err := view.ViewManager.GetFile(file_uri)
+		if err != nil || proto_file.Proto() == nil {
+			continue
+		}
+		res = append(res, indexedReferences(proto_file)[def]...)
+	}
+	return res
+}
+
+// indexedReferences returns the type references of proto_file by the
+// definition they resolve to, from the index when it is current.
+func indexedReferences(proto_file view.ProtoFile) map[definitionKey][]indexedReference {
+	var hashes []string
+	files, _ := visibleFiles(proto_file)
+	for _, file := range files {
+		_, hash, _ := file.Read(context.Background())
+		hashes = append(hashes, string(file.URI())+"@"+hash)
+	}
+	hash := strings.Join(hashes, ",")
+
+	referenceIndex.mu.Lock()
+	cached, ok := referenceIndex.files[proto_file.URI()]
+	referenceIndex.mu.Unlock()
+	if ok && cached.hash == hash {
+		return cached.references
+	}
+
+	references := resolveReferences(proto_file)
+	referenceIndex.mu.Lock()
+	referenceIndex.files[proto_file.URI()] = fileReferences{hash: hash, references: references}
+	referenceIndex.mu.Unlock()
+	return references
+}
+
+// resolveReferences resolves every type reference of proto_file, each part of
+// a qualified name refers to the message or enum its prefix resolves to.
+func resolveReferences(proto_file view.ProtoFile) map[definitionKey][]indexedReference {
+	res := make(map[definitionKey][]indexedReference)
+	for _, ref := range typeReferences(proto_file) {
+		name := strings.TrimPrefix(ref.name, ".")
+		offset := len(ref.name) - len(name)
+		parts := strings.Split(name, ".")
+		for i, part := range parts {
+			resolved := resolveType(proto_file, ref.scope, ref.name[:offset+len(part)])
+			if len(resolved) > 0 {
+				rng := ref.Range(proto_file)
+				rng.Start.Character += uint(offset)
+				rng.End.Character = rng.Start.Character + uint(len(part))
+				reference := indexedReference{location: defines.Location{Uri: proto_file.URI(), Range: rng}}
+				if ref.rpc != nil && i == len(parts)-1 {
+					reference.rpc = &defines.Location{
+						Uri:   proto_file.URI(),
+						Range: view.TokenRange(proto_file, ref.rpc.Position, "rpc", ref.rpc.Name),
+					}
+				}
+				key := definitionKeyOf(resolved[0])
+				res[key] = append(res[key], reference)
+			}
+			offset += len(part) + 1
+		}
+	}
+	return res
+}
@@ -1,6 +1,7 @@
 package components
 
 import (
+	"bytes"
 	"context"
 	"strings"
 
@@ -38,54 +39,78 @@ func FindReferences(ctx context.Context, req *defines.ReferenceParams) (result *
 	}
 	logs.Printf("FindReferences: looking for symbol '%s'", symbolName)
 
-	// Find the definition to understand what type of symbol we're looking for
-	symbols, _ := findSymbolDefinition(ctx, &req.TextDocumentPositionParams)
-
-	var results []defines.Location
-
-	// Track definition location to avoid duplicates
-	var defUri defines.DocumentUri
-	var defLine uint
-
-	// If we found a definition and includeDeclaration is true, add it
-	if req.Context.IncludeDeclaration && len(symbols) > 0 {
-		for _, sym := range symbols {
-			if sym.Type == DefinitionTypeMessage || sym.Type == DefinitionTypeEnum {
-				var name string
-				if sym.Type == DefinitionTypeMessage {
-					name = sym.Message.Protobuf().Name
-				} else {
-					name = sym.Enum.Protobuf().Name
-				}
-				defUri = defines.DocumentUri(sym.Filename)
-				defLine = sym.Position.Line
-				results = append(results, defines.Location{
-					Uri: defUri,
-					Range: defines.Range{
-						Start: sym.Position,
-						End: defines.Position{
-							Line:      sym.Position.Line,
-							Character: sym.Position.Character + uint(len(name)),
-						},
-					},
-				})
-			}
+	// Messages and enums are found where type references resolve to them
+	if target, err := symbolTargetAt(protoFile, req.Position); err == nil && target.definition != nil {
+		results := []defines.Location{}
+		if req.Context.IncludeDeclaration {
+			def := target.definition
+			results = append(results, defines.Location{
+				Uri: defines.DocumentUri(def.Filename),
+				Range: defines.Range{
+					Start: def.Position,
+					End:   defines.Position{Line: def.Position.Line, Character: def.Position.Character + uint(len(target.name))},
+				},
+			})
 		}
+		results = append(results, definitionReferences(*target.definition, target.name)...)
+		logs.Printf("FindReferences: found %d references", len(results))
+		return &results, nil
 	}
 
-	// Search for references in the current file (skip definition line to avoid duplicates)
-	refs := searchFileForReferences(protoFile, symbolName, defUri, defLine)
-	results = append(results, refs...)
-
-	// Search in imported files (recursively)
+	// Other symbols are matched by name in the file and its imports
+	var results []defines.Location
+	results = append(results, searchFileForReferences(protoFile, symbolName, "", 0)...)
 	searchedFiles := make(map[defines.DocumentUri]bool)
 	searchedFiles[protoFile.URI()] = true
-	searchImportedFilesForReferences(protoFile, symbolName, searchedFiles, &results, defUri, defLine)
+	searchImportedFilesForReferences(protoFile, symbolName, searchedFiles, &results, "", 0)
 
 	logs.Printf("FindReferences: found %d references", len(results))
 	return &results, nil
 }
 
+// definitionReferences returns the type references resolving to def, a message
+// or enum named name, in the file declaring it and in every file importing
+// that one, directly or not, found with the reverse import index of the view.
+func definitionReferences(def SymbolDefinition, name string) (res []defines.Location) {
+	def_uri := defines.DocumentUri(def.Filename)
+	for _, document_uri := range append([]defines.DocumentUri{def_uri}, view.ViewManager.TransitiveImporters(def_uri)...) {
+		proto_file, err := view.ViewManager.GetFile(document_uri)
+		if err != nil || proto_file.Proto() == nil {
+			continue
+		}
+		res = append(res, typeReferenceLocations(proto_file, def, name)...)
+	}
+	return res
+}
+
+// typeReferenceLocations returns the range of name in every type reference of
+// proto_file which resolves to def, also inside paths like Outer.Inner.
+func typeReferenceLocations(proto_file view.ProtoFile, def SymbolDefinition, name string) (res []defines.Location) {
+	data, _, err := proto_file.Read(context.Background())
+	if err != nil || !bytes.Contains(data, []byte(name)) {
+		return nil
+	}
+	for _, ref := range typeReferences(proto_file) {
+		parts := strings.Split(strings.TrimPrefix(ref.name, "."), ".")
+		offset := len(ref.name) - len(strings.TrimPrefix(ref.name, "."))
+		for i, part := range parts {
+			if part == name {
+				prefix := ref.name[:offset+len(part)]
+				if resolved := resolveType(proto_file, ref.scope, prefix); len(resolved) > 0 && sameDefinition(resolved[0], def) {
+					rng := ref.Range(proto_file)
+					rng.Start.Character += uint(offset)
+					rng.End.Character = rng.Start.Character + uint(len(part))
+					res = append(res, defines.Location{Uri: proto_file.URI(), Range: rng})
+				}
+			}
+			if i < len(parts)-1 {
+				offset += len(part) + 1
+			}
+		}
+	}
+	return res
+}
+
 // searchImportedFilesForReferences recursively searches imported files for references
 func searchImportedFilesForReferences(protoFile view.ProtoFile, symbolName string, searched map[defines.DocumentUri]bool, results *[]defines.Location, defUri defines.DocumentUri, defLine uint) {
 	if protoFile.Proto() == nil {
@@ -0,0 +1,172 @@
+package components
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/lasorda/protobuf-language-server/proto/types"
+	"github.com/lasorda/protobuf-language-server/proto/view"
+
+	"github.com/lasorda/protobuf-language-server/go-lsp/jsonrpc"
+	"github.com/lasorda/protobuf-language-server/go-lsp/logs"
+	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
+)
+
+var identifierRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// symbolTarget is the symbol under the cursor. Messages and enums have a
+// definition and are renamed everywhere they are referenced, fields, enum
+// values, services and rpcs are only renamed where they are declared.
+type symbolTarget struct {
+	name       string
+	rng        defines.Range
+	definition *SymbolDefinition
+}
+
+// PrepareRename returns the range of the name to rename, it refuses built-in
+// scalar types and types defined outside the workspace.
+func PrepareRename(ctx context.Context, req *defines.PrepareRenameParams) (*defines.Range, error) {
+	target, err := findRenameTarget(ctx, &req.TextDocumentPositionParams)
+	if err != nil {
+		return nil, renameError(err)
+	}
+	return &target.rng, nil
+}
+
+// Rename renames a message or enum at its definition and at every qualified
+// or unqualified use in the workspace, any other symbol at its declaration.
+func Rename(ctx context.Context, req *defines.RenameParams) (*defines.WorkspaceEdit, error) {
+	if !identifierRegexp.MatchString(req.NewName) {
+		return nil, renameError(fmt.Errorf("%q is not a valid identifier", req.NewName))
+	}
+	target, err := findRenameTarget(ctx, &defines.TextDocumentPositionParams{
+		TextDocument: req.TextDocument,
+		Position:     req.Position,
+	})
+	if err != nil {
+		return nil, renameError(err)
+	}
+
+	changes := make(map[string][]defines.TextEdit)
+	seen := make(map[defines.Location]bool)
+	addEdit := func(document_uri defines.DocumentUri, rng defines.Range) {
+		location := defines.Location{Uri: document_uri, Range: rng}
+		if seen[location] {
+			return
+		}
+		seen[location] = true
+		changes[string(document_uri)] = append(changes[string(document_uri)], defines.TextEdit{Range: rng, NewText: req.NewName})
+	}
+
+	if target.definition == nil {
+		addEdit(req.TextDocument.Uri, target.rng)
+		return &defines.WorkspaceEdit{Changes: &changes}, nil
+	}
+
+	def_file, err := view.ViewManager.GetFile(defines.DocumentUri(target.definition.Filename))
+	if err != nil {
+		return nil, renameError(err)
+	}
+	for _, decl := range declarations(def_file) {
+		if (decl.message != nil || decl.enum != nil) && decl.name == target.name &&
+			decl.rng.Start == target.definition.Position {
+			addEdit(def_file.URI(), decl.rng)
+		}
+	}
+
+	for _, location := range definitionReferences(*target.definition, target.name) {
+		addEdit(location.Uri, location.Range)
+	}
+	logs.Printf("Rename %v to %v: %d files", target.name, req.NewName, len(changes))
+	return &defines.WorkspaceEdit{Changes: &changes}, nil
+}
+
+// renameError turns err into a response error, so that the client can show
+// why the symbol cannot be renamed.
+func renameError(err error) error {
+	return jsonrpc.ResponseError{Code: jsonrpc.InvalidParamsCode, Message: err.Error()}
+}
+
+func findRenameTarget(ctx context.Context, position *defines.TextDocumentPositionParams) (*symbolTarget, error) {
+	if !view.IsProtoFile(position.TextDocument.Uri) {
+		return nil, fmt.Errorf("%v is not a proto file", position.TextDocument.Uri)
+	}
+	proto_file, err := view.ViewManager.GetFile(position.TextDocument.Uri)
+	if err != nil {
+		return nil, err
+	}
+	target, err := symbolTargetAt(proto_file, position.Position)
+	if err != nil {
+		return nil, err
+	}
+	return checkRenameTarget(target, proto_file)
+}
+
+// symbolTargetAt returns the declaration or the part of a type reference at
+// position, resolved to the message or enum it names.
+func symbolTargetAt(proto_file view.ProtoFile, position defines.Position) (*symbolTarget, error) {
+	for _, decl := range declarations(proto_file) {
+		if !rangeContains(decl.rng, position) {
+			continue
+		}
+		target := &symbolTarget{name: decl.name, rng: decl.rng}
+		if decl.message != nil {
+			def := messageSymbolDefinition(proto_file, decl.message)
+			target.definition = &def
+		}
+		if decl.enum != nil {
+			def := enumSymbolDefinition(proto_file, decl.enum)
+			target.definition = &def
+		}
+		return target, nil
+	}
+
+	for _, ref := range typeReferences(proto_file) {
+		rng := ref.Range(proto_file)
+		if ref.name == "" || !rangeContains(rng, position) {
+			continue
+		}
+		if types.IsBuildInProtoType(ref.name) {
+			return nil, fmt.Errorf("cannot rename built-in type %q", ref.name)
+		}
+
+		// the part of a qualified name under the cursor, or right before it
+		cursor := int(position.Character - rng.Start.Character)
+		if cursor > 0 && (cursor == len(ref.name) || ref.name[cursor] == '.') {
+			cursor--
+		}
+		if ref.name[cursor] == '.' {
+			cursor++
+		}
+		start := strings.LastIndexByte(ref.name[:cursor], '.') + 1
+		end := len(ref.name)
+		if idx := strings.IndexByte(ref.name[cursor:], '.'); idx != -1 {
+			end = cursor + idx
+		}
+
+		resolved := resolveType(proto_file, ref.scope, ref.name[:end])
+		if len(resolved) == 0 {
+			return nil, fmt.Errorf("%q is not a message or enum", ref.name[:end])
+		}
+		rng.Start.Character += uint(start)
+		rng.End.Character = rng.Start.Character + uint(end-start)
+		return &symbolTarget{name: ref.name[start:end], rng: rng, definition: &resolved[0]}, nil
+	}
+
+	return nil, fmt.Errorf("no symbol at %v:%v", position.Line+1, position.Character+1)
+}
+
+// checkRenameTarget refuses symbols declared outside the workspace, like the
+// well-known types.
+func checkRenameTarget(target *symbolTarget, proto_file view.ProtoFile) (*symbolTarget, error) {
+	document_uri := proto_file.URI()
+	if target.definition != nil {
+		document_uri = defines.DocumentUri(target.definition.Filename)
+	}
+	if !view.ViewManager.InWorkspace(document_uri) {
+		return nil, fmt.Errorf("cannot rename %q, it is declared outside the workspace in %v", target.name, document_uri)
+	}
+	return target, nil
+}
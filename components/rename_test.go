@@ -0,0 +1,130 @@
+package components
+
+import (
+	"testing"
+
+	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_typeReferenceLocations(t *testing.T) {
+	content := `syntax = "proto3";
+package foo;
+message Outer {
+  message Inner {}
+  Inner a = 1;
+  map<string, Outer.Inner> b = 2;
+}
+message Other {
+  Outer.Inner c = 1;
+  .foo.Outer.Inner d = 2;
+  foo.Outer e = 3;
+  oneof choice {
+    Outer f = 4;
+  }
+  message Inner {}
+  Inner g = 5;
+}
+service S {
+  rpc Get(Outer) returns (Outer.Inner);
+}`
+	proto_file := newMockProtoFile(t, "file:///test.proto", content)
+	outer, ok := proto_file.Proto().GetMessageByName("Outer")
+	require.True(t, ok)
+	inner, ok := outer.GetNestedMessageByName("Inner")
+	require.True(t, ok)
+
+	type want struct{ line, start, end uint }
+	locations := func(def SymbolDefinition, name string) (res []want) {
+		for _, l := range typeReferenceLocations(proto_file, def, name) {
+			res = append(res, want{l.Range.Start.Line, l.Range.Start.Character, l.Range.End.Character})
+		}
+		return res
+	}
+
+	require.Equal(t, []want{
+		{5, 14, 19},
+		{8, 2, 7},
+		{9, 7, 12},
+		{10, 6, 11},
+		{12, 4, 9},
+		{18, 10, 15},
+		{18, 26, 31},
+	}, locations(messageSymbolDefinition(proto_file, outer), "Outer"))
+
+	require.Equal(t, []want{
+		{4, 2, 7},
+		{5, 20, 25},
+		{8, 8, 13},
+		{9, 13, 18},
+		{18, 32, 37},
+	}, locations(messageSymbolDefinition(proto_file, inner), "Inner"))
+}
+
+func Test_declarations(t *testing.T) {
+	content := `syntax = "proto3";
+message Foo {
+  optional string name = 1;
+  map<string, int32> counts = 2;
+  oneof choice {
+    int32 number = 3;
+  }
+  enum Kind {
+    KIND_UNSPECIFIED = 0;
+  }
+}
+service Greeter {
+  rpc SayHello(Foo) returns (Foo);
+}`
+	proto_file := newMockProtoFile(t, "file:///test.proto", content)
+
+	type want struct {
+		name   string
+		kind   defines.SymbolKind
+		rng    defines.Range
+		isType bool
+	}
+	rng := func(line, start, end uint) defines.Range {
+		return defines.Range{
+			Start: defines.Position{Line: line, Character: start},
+			End:   defines.Position{Line: line, Character: end},
+		}
+	}
+	var got []want
+	for _, decl := range declarations(proto_file) {
+		got = append(got, want{decl.name, decl.kind, decl.rng, decl.message != nil || decl.enum != nil})
+	}
+	require.Equal(t, []want{
+		{"Foo", defines.SymbolKindClass, rng(1, 8, 11), true},
+		{"name", defines.SymbolKindField, rng(2, 18, 22), false},
+		{"counts", defines.SymbolKindField, rng(3, 21, 27), false},
+		{"choice", defines.SymbolKindField, rng(4, 8, 14), false},
+		{"number", defines.SymbolKindField, rng(5, 10, 16), false},
+		{"Kind", defines.SymbolKindEnum, rng(7, 7, 11), true},
+		{"KIND_UNSPECIFIED", defines.SymbolKindEnumMember, rng(8, 4, 20), false},
+		{"Greeter", defines.SymbolKindInterface, rng(11, 8, 15), false},
+		{"SayHello", defines.SymbolKindMethod, rng(12, 6, 14), false},
+	}, got)
+}
+
+func Test_typeReferenceLocations_sameLine(t *testing.T) {
+	content := `syntax = "proto3";
+message A { message X {} } message B { message X {} }
+message C {
+  A.X a = 1;
+  B.X b = 2;
+}`
+	proto_file := newMockProtoFile(t, "file:///test.proto", content)
+	var got []string
+	for _, name := range []string{"A", "B"} {
+		message, ok := proto_file.Proto().GetMessageByName(name)
+		require.True(t, ok)
+		x, ok := message.GetNestedMessageByName("X")
+		require.True(t, ok)
+		for _, l := range typeReferenceLocations(proto_file, messageSymbolDefinition(proto_file, x), "X") {
+			got = append(got, formatRange(l.Range))
+		}
+	}
+	// types declared on the same line are told apart
+	require.Equal(t, []string{"3:4-3:5", "4:4-4:5"}, got)
+}
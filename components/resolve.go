@@ -0,0 +1,295 @@
+package components
+
+import (
+	"strings"
+	"text/scanner"
+
+	protobuf "github.com/emicklei/proto"
+	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
+	"github.com/lasorda/protobuf-language-server/proto/parser"
+	"github.com/lasorda/protobuf-language-server/proto/view"
+)
+
+// typeReference is a type name written in a field, map value, oneof field,
+// extend or rpc, with the scope it is resolved from.
+type typeReference struct {
+	scope parser.Message
+	name  string
+	pos   scanner.Position
+	// the name is written after this token on the line of pos
+	after string
+	// the rpc taking or returning the type, nil for other references
+	rpc *protobuf.RPC
+}
+
+func (r typeReference) Range(proto_file view.ProtoFile) defines.Range {
+	return view.TokenRange(proto_file, r.pos, r.after, r.name)
+}
+
+// typeReferences lists every type reference of proto_file in declaration order.
+func typeReferences(proto_file view.ProtoFile) (res []typeReference) {
+	add := func(scope parser.Message, name string, pos scanner.Position, after string) {
+		res = append(res, typeReference{scope: scope, name: name, pos: pos, after: after})
+	}
+
+	var walk func(scope parser.Message, messages []parser.Message)
+	walk = func(scope parser.Message, messages []parser.Message) {
+		for _, message := range messages {
+			fieldScope := message
+			// fields of an extend belong to the enclosing scope
+			if message.Protobuf().IsExtend {
+				fieldScope = scope
+				add(scope, message.Protobuf().Name, message.Protobuf().Position, "extend")
+			}
+			for _, f := range message.Fields() {
+				add(fieldScope, f.ProtoField.Type, f.ProtoField.Position, "")
+			}
+			for _, f := range message.MapFields() {
+				add(fieldScope, f.ProtoMapField.Type, f.ProtoMapField.Position, ",")
+			}
+			for _, oneof := range message.Oneofs() {
+				for _, e := range oneof.Protobuf().Elements {
+					if f, ok := e.(*protobuf.OneOfField); ok {
+						add(fieldScope, f.Type, f.Position, "")
+					}
+				}
+			}
+			walk(message, message.NestedMessages())
+		}
+	}
+	walk(nil, proto_file.Proto().Messages())
+
+	for _, service := range proto_file.Proto().Services() {
+		for _, rpc := range service.RPCs() {
+			add(nil, rpc.ProtoRPC.RequestType, rpc.ProtoRPC.Position, "(")
+			res[len(res)-1].rpc = rpc.ProtoRPC
+			add(nil, rpc.ProtoRPC.ReturnsType, rpc.ProtoRPC.Position, "returns")
+			res[len(res)-1].rpc = rpc.ProtoRPC
+		}
+	}
+	return res
+}
+
+// resolveType resolves a type reference the way protoc does.
+// A relative name is looked up from the innermost enclosing message outwards,
+// then under every prefix of the current package, in the file itself and in
+// every file visible through its imports.
+func resolveType(proto_file view.ProtoFile, scope parser.Message, typeName string) []SymbolDefinition {
+	return resolveTypeIn(proto_file, nil, scope, typeName)
+}
+
+// resolveTypeIn is resolveType with files, the visible files of proto_file,
+// looked up by a caller resolving many references. When files is nil they
+// are looked up once they are needed.
+func resolveTypeIn(proto_file view.ProtoFile, files []view.ProtoFile, scope parser.Message, typeName string) []SymbolDefinition {
+	if typeName == "" || proto_file.Proto() == nil {
+		return nil
+	}
+	visible := func() []view.ProtoFile {
+		if files == nil {
+			files, _ = visibleFiles(proto_file)
+		}
+		return files
+	}
+
+	if strings.HasPrefix(typeName, ".") {
+		return resolveFullyQualifiedType(visible(), typeName[1:])
+	}
+
+	parts := strings.Split(typeName, ".")
+	for m := scope; m != nil; m = m.GetParentMessage() {
+		res := lookupTypePath(proto_file, m.NestedMessages(), m.NestedEnums(), parts)
+		if len(res) > 0 {
+			return res
+		}
+	}
+
+	pkg := filePackage(proto_file)
+	for {
+		name := typeName
+		if pkg != "" {
+			name = pkg + "." + typeName
+		}
+		res := resolveFullyQualifiedType(visible(), name)
+		if len(res) > 0 {
+			return res
+		}
+		if pkg == "" {
+			return nil
+		}
+		pos := strings.LastIndex(pkg, ".")
+		if pos == -1 {
+			pkg = ""
+		} else {
+			pkg = pkg[:pos]
+		}
+	}
+}
+
+// resolveFullyQualifiedType looks name (without the leading dot) up in files,
+// a file and every file visible through its imports.
+func resolveFullyQualifiedType(files []view.ProtoFile, name string) []SymbolDefinition {
+	for _, file := range files {
+		rest := name
+		if pkg := filePackage(file); pkg != "" {
+			if !strings.HasPrefix(name, pkg+".") {
+				continue
+			}
+			rest = name[len(pkg)+1:]
+		}
+		res := lookupTypePath(file, file.Proto().Messages(), file.Proto().Enums(), strings.Split(rest, "."))
+		if len(res) > 0 {
+			return res
+		}
+	}
+	return nil
+}
+
+// lookupTypePath descends through nested messages following parts,
+// the last part may name a message or an enum.
+func lookupTypePath(proto_file view.ProtoFile, messages []parser.Message, enums []parser.Enum, parts []string) []SymbolDefinition {
+	if len(parts) == 0 {
+		return nil
+	}
+	for _, message := range messages {
+		if message.Protobuf().IsExtend || message.Protobuf().Name != parts[0] {
+			continue
+		}
+		if len(parts) == 1 {
+			message.Protobuf().Position.Filename = string(proto_file.URI())
+			return []SymbolDefinition{messageSymbolDefinition(proto_file, message)}
+		}
+		return lookupTypePath(proto_file, message.NestedMessages(), message.NestedEnums(), parts[1:])
+	}
+	if len(parts) != 1 {
+		return nil
+	}
+	for _, enum := range enums {
+		if enum.Protobuf().Name == parts[0] {
+			enum.Protobuf().Position.Filename = string(proto_file.URI())
+			return []SymbolDefinition{enumSymbolDefinition(proto_file, enum)}
+		}
+	}
+	return nil
+}
+
+// visibleFiles returns the file, its imports and, transitively, the public
+// imports of those. complete is false when some import could not be loaded.
+func visibleFiles(proto_file view.ProtoFile) (files []view.ProtoFile, complete bool) {
+	complete = true
+	searched := map[string]bool{string(proto_file.URI()): true}
+	files = append(files, proto_file)
+
+	var walk func(file view.ProtoFile, kind string)
+	walk = func(file view.ProtoFile, kind string) {
+		for _, im := range file.Proto().Imports() {
+			if kind != "" && im.ProtoImport.Kind != kind {
+				continue
+			}
+			import_uri, err := view.ViewManager.GetDocumentUriFromImportPath(file.URI(), im.ProtoImport.Filename)
+			if err != nil {
+				complete = false
+				continue
+			}
+			if searched[string(import_uri)] {
+				continue
+			}
+			searched[string(import_uri)] = true
+
+			import_file, err := view.ViewManager.GetFile(import_uri)
+			if err != nil || import_file.Proto() == nil {
+				complete = false
+				continue
+			}
+			files = append(files, import_file)
+			walk(import_file, "public")
+		}
+	}
+	walk(proto_file, "")
+	return files, complete
+}
+
+func filePackage(proto_file view.ProtoFile) string {
+	packages := proto_file.Proto().Packages()
+	if len(packages) == 0 {
+		return ""
+	}
+	return packages[0].ProtoPackage.Name
+}
+
+// declaration is a message, enum, enum value, field, oneof, service or rpc
+// declared in a file. message and enum are set when it declares a type.
+type declaration struct {
+	name    string
+	kind    defines.SymbolKind
+	rng     defines.Range
+	message parser.Message
+	enum    parser.Enum
+	// the declaring element, like *protobuf.NormalField or *protobuf.RPC
+	element protobuf.Visitee
+}
+
+// declarations lists every message, enum, enum value, field, oneof, service
+// and rpc declared in proto_file with the range of its name.
+func declarations(proto_file view.ProtoFile) (res []declaration) {
+	add := func(name string, kind defines.SymbolKind, pos scanner.Position, after string, element protobuf.Visitee) *declaration {
+		res = append(res, declaration{name: name, kind: kind, rng: view.TokenRange(proto_file, pos, after, name), element: element})
+		return &res[len(res)-1]
+	}
+	addEnum := func(enum parser.Enum) {
+		add(enum.Protobuf().Name, defines.SymbolKindEnum, enum.Protobuf().Position, "enum", enum.Protobuf()).enum = enum
+		for _, e := range enum.Protobuf().Elements {
+			if value, ok := e.(*protobuf.EnumField); ok {
+				add(value.Name, defines.SymbolKindEnumMember, value.Position, "", value)
+			}
+		}
+	}
+
+	var walk func(messages []parser.Message)
+	walk = func(messages []parser.Message) {
+		for _, message := range messages {
+			if !message.Protobuf().IsExtend {
+				add(message.Protobuf().Name, defines.SymbolKindClass, message.Protobuf().Position, "message", message.Protobuf()).message = message
+			}
+			for _, f := range message.Fields() {
+				add(f.ProtoField.Name, defines.SymbolKindField, f.ProtoField.Position, f.ProtoField.Type, f.ProtoField)
+			}
+			for _, f := range message.MapFields() {
+				add(f.ProtoMapField.Name, defines.SymbolKindField, f.ProtoMapField.Position, ">", f.ProtoMapField)
+			}
+			for _, oneof := range message.Oneofs() {
+				add(oneof.Protobuf().Name, defines.SymbolKindField, oneof.Protobuf().Position, "oneof", oneof.Protobuf())
+				for _, e := range oneof.Protobuf().Elements {
+					if f, ok := e.(*protobuf.OneOfField); ok {
+						add(f.Name, defines.SymbolKindField, f.Position, f.Type, f)
+					}
+				}
+			}
+			for _, enum := range message.NestedEnums() {
+				addEnum(enum)
+			}
+			walk(message.NestedMessages())
+		}
+	}
+	walk(proto_file.Proto().Messages())
+
+	for _, enum := range proto_file.Proto().Enums() {
+		addEnum(enum)
+	}
+	for _, service := range proto_file.Proto().Services() {
+		add(service.Protobuf().Name, defines.SymbolKindInterface, service.Protobuf().Position, "service", service.Protobuf())
+		for _, rpc := range service.RPCs() {
+			add(rpc.ProtoRPC.Name, defines.SymbolKindMethod, rpc.ProtoRPC.Position, "rpc", rpc.ProtoRPC)
+		}
+	}
+	return res
+}
+
+// sameDefinition reports whether a and b point at the same message or enum.
+func sameDefinition(a, b SymbolDefinition) bool {
+	return a.Type == b.Type && a.Filename == b.Filename && a.Position == b.Position
+}
+
+func rangeContains(r defines.Range, pos defines.Position) bool {
+	return r.Start.Line == pos.Line && r.Start.Character <= pos.Character && pos.Character <= r.End.Character
+}
@@ -0,0 +1,247 @@
+package components
+
+import (
+	"context"
+	"strings"
+	"text/scanner"
+
+	protobuf "github.com/emicklei/proto"
+	"github.com/lasorda/protobuf-language-server/proto/parser"
+	"github.com/lasorda/protobuf-language-server/proto/view"
+
+	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
+)
+
+// SelectionRanges expands the selection from the identifier under the
+// cursor to its qualified name, the brackets around it, like the options of
+// a field or the request of an rpc, every declaration enclosing it and the
+// whole file.
+func SelectionRanges(ctx context.Context, req *defines.SelectionRangeParams) (result *[]defines.SelectionRange, err error) {
+	if !view.IsProtoFile(req.TextDocument.Uri) {
+		return nil, nil
+	}
+	proto_file, err := view.ViewManager.GetFile(req.TextDocument.Uri)
+	if err != nil || proto_file.Proto() == nil {
+		return nil, err
+	}
+	res := []defines.SelectionRange{}
+	for _, position := range req.Positions {
+		res = append(res, selectionRange(proto_file.Proto(), position))
+	}
+	return &res, nil
+}
+
+func selectionRange(proto parser.Proto, position defines.Position) defines.SelectionRange {
+	tokens := proto.Tokens()
+	pos := scanner.Position{Line: int(position.Line) + 1, Column: int(position.Character) + 1}
+
+	// innermost first, each range contains the previous one
+	var ranges []defines.Range
+	add := func(rng defines.Range) {
+		if n := len(ranges); n > 0 && (ranges[n-1] == rng || !containsRange(rng, ranges[n-1])) {
+			return
+		}
+		ranges = append(ranges, rng)
+	}
+	tokensRange := func(start, end int) defines.Range {
+		return spanRange(tokens, parser.Span{Start: start, End: end})
+	}
+
+	// the token under the cursor, an identifier rather than the punctuation
+	// right before or after it
+	next := parser.TokenAt(tokens, pos)
+	current := -1
+	startsAt := next < len(tokens) && !positionBefore(pos, tokens[next].Start)
+	if startsAt && isIdentifierToken(tokens[next]) {
+		current = next
+	} else if next > 0 && !positionBefore(tokens[next-1].End, pos) {
+		current = next - 1
+	} else if startsAt {
+		current = next
+	}
+	if current != -1 {
+		token := tokens[current]
+		if isIdentifierToken(token) && strings.Contains(token.Text, ".") {
+			// the part of a qualified name
+			column := token.Start.Column
+			for _, part := range strings.Split(token.Text, ".") {
+				if part != "" && column <= pos.Column && pos.Column <= column+len(part) {
+					start := scanner.Position{Line: token.Start.Line, Column: column}
+					add(defines.Range{Start: tokenPosition(start), End: tokenPosition(scanner.Position{Line: start.Line, Column: column + len(part)})})
+				}
+				column += len(part) + 1
+			}
+		}
+		add(tokensRange(current, current))
+		next = current
+	}
+
+	elements := enclosingElements(proto, pos)
+	start := 0
+	block := false
+	if len(elements) > 0 {
+		innermost := elements[len(elements)-1]
+		span, _ := proto.Span(innermost)
+		start = span.Start
+		switch innermost.(type) {
+		case *protobuf.Message, *protobuf.Enum, *protobuf.Service, *protobuf.Oneof, *protobuf.RPC, *protobuf.Group:
+			block = true
+		}
+	}
+	for _, open := range enclosingBrackets(tokens, start, next, block) {
+		close := matchingBracket(tokens, open)
+		if item := bracketItem(tokens, open, close, pos); item != nil {
+			add(tokensRange(item[0], item[1]))
+		}
+		if open+1 <= close-1 {
+			add(tokensRange(open+1, close-1))
+		}
+		add(tokensRange(open, close))
+	}
+	for i := len(elements) - 1; i >= 0; i-- {
+		span, _ := proto.Span(elements[i])
+		add(spanRange(tokens, span))
+	}
+	if len(tokens) > 0 {
+		add(tokensRange(0, len(tokens)-1))
+	}
+
+	if len(ranges) == 0 {
+		return defines.SelectionRange{Range: defines.Range{Start: position, End: position}}
+	}
+	var res *defines.SelectionRange
+	for i := len(ranges) - 1; i >= 0; i-- {
+		res = &defines.SelectionRange{Range: ranges[i], Parent: res}
+	}
+	return *res
+}
+
+// enclosingElements returns the declarations containing pos, outermost first.
+func enclosingElements(proto parser.Proto, pos scanner.Position) (res []protobuf.Visitee) {
+	tokens := proto.Tokens()
+	elements := proto.Protobuf().Elements
+	for len(elements) > 0 {
+		var found protobuf.Visitee
+		for _, element := range elements {
+			span, ok := proto.Span(element)
+			if ok && !positionBefore(pos, tokens[span.Start].Start) && !positionBefore(tokens[span.End].End, pos) {
+				found = element
+				break
+			}
+		}
+		if found == nil {
+			break
+		}
+		res = append(res, found)
+		elements = nil
+		switch v := found.(type) {
+		case *protobuf.Message:
+			elements = v.Elements
+		case *protobuf.Enum:
+			elements = v.Elements
+		case *protobuf.Service:
+			elements = v.Elements
+		case *protobuf.Oneof:
+			elements = v.Elements
+		case *protobuf.RPC:
+			elements = v.Elements
+		case *protobuf.Group:
+			elements = v.Elements
+		}
+	}
+	return res
+}
+
+// enclosingBrackets returns the '(', '[', '{' and '<' opened from start and
+// not closed before end, innermost first. The body of a block declaration is
+// not one of them.
+func enclosingBrackets(tokens []parser.Token, start, end int, block bool) (res []int) {
+	var stack []int
+	for i := start; i < end && i < len(tokens); i++ {
+		switch tokens[i].Text {
+		case "{":
+			if block && len(stack) == 0 {
+				block = false
+				// the body, everything after it belongs to nested elements
+				stack = append(stack, -1)
+				continue
+			}
+			stack = append(stack, i)
+		case "(", "[", "<":
+			stack = append(stack, i)
+		case "}", ")", "]", ">":
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+	for i := len(stack) - 1; i >= 0; i-- {
+		if stack[i] != -1 {
+			res = append(res, stack[i])
+		}
+	}
+	return res
+}
+
+// matchingBracket returns the index of the bracket closing the one at open,
+// the last token when it is not closed.
+func matchingBracket(tokens []parser.Token, open int) int {
+	depth := 0
+	for i := open; i < len(tokens); i++ {
+		switch tokens[i].Text {
+		case "{", "(", "[", "<":
+			depth++
+		case "}", ")", "]", ">":
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(tokens) - 1
+}
+
+// bracketItem returns the first and last token of the comma separated item
+// between open and close containing pos, nil when there is a single item.
+func bracketItem(tokens []parser.Token, open, close int, pos scanner.Position) []int {
+	var commas []int
+	depth := 0
+	for i := open + 1; i < close; i++ {
+		switch tokens[i].Text {
+		case "{", "(", "[", "<":
+			depth++
+		case "}", ")", "]", ">":
+			depth--
+		case ",":
+			if depth == 0 {
+				commas = append(commas, i)
+			}
+		}
+	}
+	if len(commas) == 0 {
+		return nil
+	}
+	first := open + 1
+	for _, comma := range append(commas, close) {
+		if positionBefore(pos, tokens[comma].End) {
+			if first > comma-1 {
+				return nil
+			}
+			return []int{first, comma - 1}
+		}
+		first = comma + 1
+	}
+	return nil
+}
+
+// positionBefore reports whether a comes before b.
+func positionBefore(a, b scanner.Position) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+}
+
+// isIdentifierToken reports whether token is a name or a number, qualified
+// names are one token.
+func isIdentifierToken(token parser.Token) bool {
+	c := token.Text[0]
+	return !token.Comment && (c == '_' || c == '.' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,43 @@
+package components
+
+import (
+	"testing"
+
+	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_selectionRange(t *testing.T) {
+	content := `syntax = "proto3";
+package foo;
+message Outer {
+  message Inner {
+    oneof value {
+      foo.bar.Baz baz = 1 [deprecated = true, (my.opt) = { a: 1 }];
+    }
+  }
+}
+service Svc {
+  rpc Call(stream Outer) returns (Outer);
+}
+`
+	proto_file := newMockProtoFile(t, "file:///test.proto", content)
+	ranges := func(line, character uint) (res []string) {
+		for r := selectionRange(proto_file.Proto(), defines.Position{Line: line, Character: character}); ; r = *r.Parent {
+			res = append(res, formatRange(r.Range))
+			if r.Parent == nil {
+				return res
+			}
+		}
+	}
+
+	// the part of a qualified type, the type, the field, the oneof, the
+	// messages and the file
+	require.Equal(t, []string{"5:10-5:13", "5:6-5:17", "5:6-5:67", "4:4-6:5", "3:2-7:3", "2:0-8:1", "0:0-11:1"}, ranges(5, 11))
+	// inside an aggregate option value of a field
+	require.Equal(t, []string{"5:59-5:60", "5:59-5:63", "5:57-5:65", "5:46-5:65", "5:27-5:65", "5:26-5:66", "5:6-5:67"}, ranges(5, 59)[:7])
+	// the option name in parentheses
+	require.Equal(t, []string{"5:50-5:53", "5:47-5:53", "5:46-5:54", "5:46-5:65", "5:27-5:65"}, ranges(5, 50)[:5])
+	// the request of an rpc
+	require.Equal(t, []string{"10:18-10:23", "10:11-10:23", "10:10-10:24", "10:2-10:41", "9:0-11:1", "0:0-11:1"}, ranges(10, 20))
+}
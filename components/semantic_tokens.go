@@ -0,0 +1,355 @@
+package components
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	protobuf "github.com/emicklei/proto"
+	"github.com/lasorda/protobuf-language-server/proto/parser"
+	"github.com/lasorda/protobuf-language-server/proto/types"
+	"github.com/lasorda/protobuf-language-server/proto/view"
+
+	"github.com/lasorda/protobuf-language-server/go-lsp/logs"
+	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
+)
+
+// token types, in the order of SemanticTokensLegend.TokenTypes
+const (
+	tokenTypeNamespace = iota
+	tokenTypeType
+	tokenTypeStruct
+	tokenTypeEnum
+	tokenTypeEnumMember
+	tokenTypeProperty
+	tokenTypeInterface
+	tokenTypeMethod
+	tokenTypeDecorator
+	tokenTypeModifier
+)
+
+// token modifiers, bit i is SemanticTokensLegend.TokenModifiers[i]
+const (
+	tokenModifierDeclaration = 1 << iota
+	tokenModifierDefaultLibrary
+)
+
+// SemanticTokensLegend describes the token types and modifiers sent by the server.
+var SemanticTokensLegend = defines.SemanticTokensLegend{
+	TokenTypes: []string{
+		string(defines.SemanticTokenTypesNamespace),
+		string(defines.SemanticTokenTypesType),
+		string(defines.SemanticTokenTypesStruct),
+		string(defines.SemanticTokenTypesEnum),
+		string(defines.SemanticTokenTypesEnumMember),
+		string(defines.SemanticTokenTypesProperty),
+		string(defines.SemanticTokenTypesInterface),
+		string(defines.SemanticTokenTypesMethod),
+		string(defines.SemanticTokenTypesDecorator),
+		string(defines.SemanticTokenTypesModifier),
+	},
+	TokenModifiers: []string{
+		string(defines.SemanticTokenModifiersDeclaration),
+		string(defines.SemanticTokenModifiersDefaultLibrary),
+	},
+}
+
+type semanticToken struct {
+	line, start, length uint
+	tokenType           uint
+	modifiers           uint
+}
+
+// semanticTokensCache keeps the last result of every document, so that the
+// next full/delta request can be answered with edits.
+var semanticTokensCache = struct {
+	mu      sync.Mutex
+	results map[defines.DocumentUri]semanticTokensResult
+	nextId  int
+}{results: make(map[defines.DocumentUri]semanticTokensResult)}
+
+type semanticTokensResult struct {
+	id   string
+	data []uint
+}
+
+// ForgetSemanticTokens drops the last result of a closed or deleted document.
+func ForgetSemanticTokens(document_uri defines.DocumentUri) {
+	semanticTokensCache.mu.Lock()
+	delete(semanticTokensCache.results, document_uri)
+	semanticTokensCache.mu.Unlock()
+}
+
+func SemanticTokensFull(ctx context.Context, req *defines.SemanticTokensParams) (*defines.SemanticTokens, error) {
+	data, err := semanticTokensData(req.TextDocument.Uri, nil)
+	if err != nil {
+		return nil, nil
+	}
+	id := storeSemanticTokens(req.TextDocument.Uri, data)
+	return &defines.SemanticTokens{ResultId: &id, Data: data}, nil
+}
+
+func SemanticTokensFullDelta(ctx context.Context, req *defines.SemanticTokensDeltaParams) (*defines.SemanticTokensFullDeltaResult, error) {
+	data, err := semanticTokensData(req.TextDocument.Uri, nil)
+	if err != nil {
+		return nil, nil
+	}
+
+	semanticTokensCache.mu.Lock()
+	previous, ok := semanticTokensCache.results[req.TextDocument.Uri]
+	semanticTokensCache.mu.Unlock()
+
+	id := storeSemanticTokens(req.TextDocument.Uri, data)
+	if !ok || previous.id != req.PreviousResultId {
+		return &defines.SemanticTokensFullDeltaResult{ResultId: &id, Data: &data}, nil
+	}
+	edits := semanticTokensEdits(previous.data, data)
+	return &defines.SemanticTokensFullDeltaResult{ResultId: &id, Edits: &edits}, nil
+}
+
+func SemanticTokensRange(ctx context.Context, req *defines.SemanticTokensRangeParams) (*defines.SemanticTokens, error) {
+	data, err := semanticTokensData(req.TextDocument.Uri, &req.Range)
+	if err != nil {
+		return nil, nil
+	}
+	return &defines.SemanticTokens{Data: data}, nil
+}
+
+func storeSemanticTokens(document_uri defines.DocumentUri, data []uint) string {
+	semanticTokensCache.mu.Lock()
+	defer semanticTokensCache.mu.Unlock()
+
+	semanticTokensCache.nextId++
+	id := strconv.Itoa(semanticTokensCache.nextId)
+	semanticTokensCache.results[document_uri] = semanticTokensResult{id: id, data: data}
+	return id
+}
+
+// semanticTokensEdits returns a single edit replacing what differs between
+// the common prefix and suffix of previous and current.
+func semanticTokensEdits(previous, current []uint) []defines.SemanticTokensEdit {
+	prefix := 0
+	for prefix < len(previous) && prefix < len(current) && previous[prefix] == current[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(previous)-prefix && suffix < len(current)-prefix &&
+		previous[len(previous)-1-suffix] == current[len(current)-1-suffix] {
+		suffix++
+	}
+	if prefix == len(previous) && prefix == len(current) {
+		return []defines.SemanticTokensEdit{}
+	}
+	inserted := current[prefix : len(current)-suffix]
+	return []defines.SemanticTokensEdit{{
+		Start:       uint(prefix),
+		DeleteCount: uint(len(previous) - prefix - suffix),
+		Data:        &inserted,
+	}}
+}
+
+func semanticTokensData(document_uri defines.DocumentUri, rng *defines.Range) ([]uint, error) {
+	if !view.IsProtoFile(document_uri) {
+		return nil, ErrSymbolNotFound
+	}
+	proto_file, err := view.ViewManager.GetFile(document_uri)
+	if err != nil {
+		logs.Printf("semanticTokens GetFile err: %v", err)
+		return nil, err
+	}
+	tokens := semanticTokens(proto_file)
+	if rng != nil {
+		var filtered []semanticToken
+		for _, token := range tokens {
+			if token.line >= rng.Start.Line && token.line <= rng.End.Line {
+				filtered = append(filtered, token)
+			}
+		}
+		tokens = filtered
+	}
+	return encodeSemanticTokens(tokens), nil
+}
+
+// encodeSemanticTokens encodes sorted tokens relative to each other, as
+// the protocol expects.
+func encodeSemanticTokens(tokens []semanticToken) []uint {
+	data := make([]uint, 0, len(tokens)*5)
+	var line, start uint
+	for _, token := range tokens {
+		deltaStart := token.start
+		if token.line == line {
+			deltaStart -= start
+		}
+		data = append(data, token.line-line, deltaStart, token.length, token.tokenType, token.modifiers)
+		line, start = token.line, token.start
+	}
+	return data
+}
+
+// semanticTokens classifies the names of proto_file, sorted by position and
+// without overlaps.
+func semanticTokens(proto_file view.ProtoFile) []semanticToken {
+	if proto_file.Proto() == nil {
+		return nil
+	}
+	var tokens []semanticToken
+	// add checks that the range really holds text, TokenRange falls back to
+	// the element position when the token is on another line
+	add := func(r defines.Range, text string, tokenType, modifiers uint) {
+		line := proto_file.ReadLine(int(r.Start.Line))
+		if text == "" || int(r.End.Character) > len(line) || line[r.Start.Character:r.End.Character] != text {
+			return
+		}
+		tokens = append(tokens, semanticToken{
+			line:      r.Start.Line,
+			start:     r.Start.Character,
+			length:    r.End.Character - r.Start.Character,
+			tokenType: tokenType,
+			modifiers: modifiers,
+		})
+	}
+
+	for _, pkg := range proto_file.Proto().Packages() {
+		p := pkg.ProtoPackage
+		add(view.TokenRange(proto_file, p.Position, "package", p.Name), p.Name, tokenTypeNamespace, tokenModifierDeclaration)
+	}
+
+	for _, decl := range declarations(proto_file) {
+		var tokenType uint
+		switch decl.kind {
+		case defines.SymbolKindClass:
+			tokenType = tokenTypeStruct
+		case defines.SymbolKindEnum:
+			tokenType = tokenTypeEnum
+		case defines.SymbolKindEnumMember:
+			tokenType = tokenTypeEnumMember
+		case defines.SymbolKindInterface:
+			tokenType = tokenTypeInterface
+		case defines.SymbolKindMethod:
+			tokenType = tokenTypeMethod
+		default:
+			tokenType = tokenTypeProperty
+		}
+		add(decl.rng, decl.name, tokenType, tokenModifierDeclaration)
+	}
+
+	// every part of every reference is resolved, look the imports up once
+	files, _ := visibleFiles(proto_file)
+	for _, ref := range typeReferences(proto_file) {
+		r := ref.Range(proto_file)
+		if types.IsBuildInProtoType(ref.name) {
+			add(r, ref.name, tokenTypeType, tokenModifierDefaultLibrary)
+			continue
+		}
+		// classify every part of a qualified name, the leading ones may
+		// be package names
+		offset := len(ref.name) - len(strings.TrimPrefix(ref.name, "."))
+		for _, part := range strings.Split(ref.name[offset:], ".") {
+			end := offset + len(part)
+			partRange := r
+			partRange.Start.Character += uint(offset)
+			partRange.End.Character = partRange.Start.Character + uint(len(part))
+
+			resolved := resolveTypeIn(proto_file, files, ref.scope, ref.name[:end])
+			switch {
+			case len(resolved) > 0 && resolved[0].Type == DefinitionTypeMessage:
+				add(partRange, part, tokenTypeStruct, 0)
+			case len(resolved) > 0 && resolved[0].Type == DefinitionTypeEnum:
+				add(partRange, part, tokenTypeEnum, 0)
+			case end < len(ref.name):
+				add(partRange, part, tokenTypeNamespace, 0)
+			}
+			offset = end + 1
+		}
+	}
+
+	for _, message := range allMessages(proto_file.Proto().Messages()) {
+		for _, f := range message.MapFields() {
+			keyType := f.ProtoMapField.KeyType
+			add(view.TokenRange(proto_file, f.ProtoMapField.Position, "<", keyType), keyType, tokenTypeType, tokenModifierDefaultLibrary)
+		}
+	}
+
+	for _, service := range proto_file.Proto().Services() {
+		for _, rpc := range service.RPCs() {
+			r := rpc.ProtoRPC
+			if r.StreamsRequest {
+				add(view.TokenRange(proto_file, r.Position, "(", "stream"), "stream", tokenTypeModifier, 0)
+			}
+			if r.StreamsReturns {
+				add(view.TokenRange(proto_file, r.Position, "returns", "stream"), "stream", tokenTypeModifier, 0)
+			}
+		}
+	}
+
+	walkOptions(proto_file.Proto().Protobuf().Elements, func(o *protobuf.Option) {
+		after := ""
+		if !o.IsEmbedded {
+			after = "option"
+		}
+		add(view.TokenRange(proto_file, o.Position, after, o.Name), o.Name, tokenTypeDecorator, 0)
+	})
+
+	sort.SliceStable(tokens, func(i, j int) bool {
+		if tokens[i].line != tokens[j].line {
+			return tokens[i].line < tokens[j].line
+		}
+		return tokens[i].start < tokens[j].start
+	})
+	res := tokens[:0]
+	for _, token := range tokens {
+		if len(res) > 0 {
+			last := res[len(res)-1]
+			if last.line == token.line && token.start < last.start+last.length {
+				continue
+			}
+		}
+		res = append(res, token)
+	}
+	return res
+}
+
+// allMessages returns messages and every message nested in them.
+func allMessages(messages []parser.Message) (res []parser.Message) {
+	for _, message := range messages {
+		res = append(res, message)
+		res = append(res, allMessages(message.NestedMessages())...)
+	}
+	return res
+}
+
+// walkOptions calls fn for every option of elements, including the options
+// of fields and enum values which protobuf.Walk does not visit.
+func walkOptions(elements []protobuf.Visitee, fn func(*protobuf.Option)) {
+	fieldOptions := func(options []*protobuf.Option) {
+		for _, o := range options {
+			fn(o)
+		}
+	}
+	for _, element := range elements {
+		switch v := element.(type) {
+		case *protobuf.Option:
+			fn(v)
+		case *protobuf.Message:
+			walkOptions(v.Elements, fn)
+		case *protobuf.Enum:
+			walkOptions(v.Elements, fn)
+		case *protobuf.EnumField:
+			walkOptions(v.Elements, fn)
+		case *protobuf.Service:
+			walkOptions(v.Elements, fn)
+		case *protobuf.RPC:
+			walkOptions(v.Elements, fn)
+		case *protobuf.Oneof:
+			walkOptions(v.Elements, fn)
+		case *protobuf.NormalField:
+			fieldOptions(v.Options)
+		case *protobuf.MapField:
+			fieldOptions(v.Options)
+		case *protobuf.OneOfField:
+			fieldOptions(v.Options)
+		}
+	}
+}
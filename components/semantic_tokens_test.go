@@ -0,0 +1,90 @@
+package components
+
+import (
+	"testing"
+
+	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_semanticTokens(t *testing.T) {
+	content := `syntax = "proto3";
+package demo.api;
+option go_package = "demo/api";
+message Outer {
+  message Inner {}
+  enum Kind {
+    KIND_UNSPECIFIED = 0 [deprecated = true];
+  }
+  .demo.api.Outer.Inner inner = 1;
+  map<string, Kind> kinds = 2;
+}
+service Greeter {
+  rpc Watch(stream Outer) returns (stream Outer.Inner);
+}`
+	proto_file := newMockProtoFile(t, "file:///test.proto", content)
+
+	type want struct {
+		line, start uint
+		text        string
+		tokenType   uint
+		modifiers   uint
+	}
+	wants := []want{
+		{1, 8, "demo.api", tokenTypeNamespace, tokenModifierDeclaration},
+		{2, 7, "go_package", tokenTypeDecorator, 0},
+		{3, 8, "Outer", tokenTypeStruct, tokenModifierDeclaration},
+		{4, 10, "Inner", tokenTypeStruct, tokenModifierDeclaration},
+		{5, 7, "Kind", tokenTypeEnum, tokenModifierDeclaration},
+		{6, 4, "KIND_UNSPECIFIED", tokenTypeEnumMember, tokenModifierDeclaration},
+		{6, 26, "deprecated", tokenTypeDecorator, 0},
+		{8, 3, "demo", tokenTypeNamespace, 0},
+		{8, 8, "api", tokenTypeNamespace, 0},
+		{8, 12, "Outer", tokenTypeStruct, 0},
+		{8, 18, "Inner", tokenTypeStruct, 0},
+		{8, 24, "inner", tokenTypeProperty, tokenModifierDeclaration},
+		{9, 6, "string", tokenTypeType, tokenModifierDefaultLibrary},
+		{9, 14, "Kind", tokenTypeEnum, 0},
+		{9, 20, "kinds", tokenTypeProperty, tokenModifierDeclaration},
+		{11, 8, "Greeter", tokenTypeInterface, tokenModifierDeclaration},
+		{12, 6, "Watch", tokenTypeMethod, tokenModifierDeclaration},
+		{12, 12, "stream", tokenTypeModifier, 0},
+		{12, 19, "Outer", tokenTypeStruct, 0},
+		{12, 35, "stream", tokenTypeModifier, 0},
+		{12, 42, "Outer", tokenTypeStruct, 0},
+		{12, 48, "Inner", tokenTypeStruct, 0},
+	}
+	var gots []want
+	for _, token := range semanticTokens(proto_file) {
+		line := proto_file.ReadLine(int(token.line))
+		gots = append(gots, want{token.line, token.start, line[token.start : token.start+token.length], token.tokenType, token.modifiers})
+	}
+	require.Equal(t, wants, gots)
+
+	data := encodeSemanticTokens(semanticTokens(proto_file))
+	require.Equal(t, []uint{1, 8, 8, tokenTypeNamespace, tokenModifierDeclaration}, data[:5])
+	require.Equal(t, []uint{1, 7, 10, tokenTypeDecorator, 0}, data[5:10])
+}
+
+func Test_semanticTokensEdits(t *testing.T) {
+	previous := []uint{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	current := []uint{0, 1, 2, 3, 4, 10, 11, 7, 8, 9}
+	edits := semanticTokensEdits(previous, current)
+	require.Len(t, edits, 1)
+	require.Equal(t, uint(5), edits[0].Start)
+	require.Equal(t, uint(2), edits[0].DeleteCount)
+	require.Equal(t, []uint{10, 11}, *edits[0].Data)
+
+	require.Empty(t, semanticTokensEdits(previous, previous))
+
+	edits = semanticTokensEdits(previous, previous[:5])
+	require.Equal(t, uint(5), edits[0].Start)
+	require.Equal(t, uint(5), edits[0].DeleteCount)
+	require.Empty(t, *edits[0].Data)
+
+	// the results of closed documents are dropped
+	storeSemanticTokens("file:///test.proto", previous)
+	require.Contains(t, semanticTokensCache.results, defines.DocumentUri("file:///test.proto"))
+	ForgetSemanticTokens("file:///test.proto")
+	require.NotContains(t, semanticTokensCache.results, defines.DocumentUri("file:///test.proto"))
+}
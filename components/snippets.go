@@ -0,0 +1,153 @@
+package components
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+	"unicode"
+
+	"github.com/lasorda/protobuf-language-server/proto/view"
+	"go.lsp.dev/uri"
+
+	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
+)
+
+var (
+	kindSnippet   = defines.CompletionItemKindSnippet
+	formatSnippet = defines.InsertTextFormatSnippet
+)
+
+// upperSnakeTransform turns the CamelCase name of placeholder 1 into
+// UPPER_SNAKE_CASE, the prefix of enum values.
+const upperSnakeTransform = `${1/(^[A-Z])|([A-Z])|([a-z0-9]+)/$1${2:+_}$2${3:/upcase}/g}`
+
+// snippetCompletionItem is a snippet declaring label, with stubs appended to
+// the file by the additional edits.
+func snippetCompletionItem(label, detail, snippet string, stubs ...defines.TextEdit) defines.CompletionItem {
+	item := defines.CompletionItem{
+		Label:            label,
+		Kind:             &kindSnippet,
+		Detail:           &detail,
+		InsertText:       &snippet,
+		InsertTextFormat: &formatSnippet,
+	}
+	if len(stubs) > 0 {
+		item.AdditionalTextEdits = &stubs
+	}
+	return item
+}
+
+func messageSnippet() defines.CompletionItem {
+	return snippetCompletionItem("message", "message with a first field",
+		"message ${1:Name} {\n  ${2:string} ${3:name} = 1;$0\n}")
+}
+
+func enumSnippet() defines.CompletionItem {
+	return snippetCompletionItem("enum", "enum with a zero value",
+		"enum ${1:Name} {\n  "+upperSnakeTransform+"_UNSPECIFIED = 0;$0\n}")
+}
+
+// oneofSnippet numbers the first field of the oneof with the next free
+// number of the message of path.
+func oneofSnippet(proto_file view.ProtoFile, path []string) defines.CompletionItem {
+	number := 1
+	if scope := scopeMessage(proto_file, path); scope != nil && scope.Protobuf().Name == path[len(path)-1] {
+		if next := view.NextFieldNumber(scope); next != 0 {
+			number = next
+		}
+	}
+	return snippetCompletionItem("oneof", "oneof with a first field",
+		fmt.Sprintf("oneof ${1:name} {\n  ${2:string} ${3:field} = %d;$0\n}", number))
+}
+
+// serviceSnippet declares a service named after the file with a first rpc,
+// its request and response messages are appended to the file, or to the
+// snippet when the cursor is at the end of the file.
+func serviceSnippet(proto_file view.ProtoFile, data []byte, offset int) defines.CompletionItem {
+	name := fileBaseName(proto_file)
+	method := uniqueMethodName(proto_file, "Get"+name)
+	rpc := fmt.Sprintf("rpc ${2:%s}(%sRequest) returns (%sResponse);$0", method, method, method)
+	snippet := fmt.Sprintf("service ${1:%sService} {\n  %s\n}", name, rpc)
+	return rpcStubsCompletionItem("service", "service with a first rpc", snippet, method, data, offset)
+}
+
+// rpcSnippet declares an rpc named after service, see serviceSnippet.
+func rpcSnippet(proto_file view.ProtoFile, service string, data []byte, offset int) defines.CompletionItem {
+	name := strings.TrimSuffix(service, "Service")
+	if name == "" {
+		name = fileBaseName(proto_file)
+	}
+	method := uniqueMethodName(proto_file, "Get"+name)
+	snippet := fmt.Sprintf("rpc ${1:%s}(%sRequest) returns (%sResponse);$0", method, method, method)
+	return rpcStubsCompletionItem("rpc", "rpc with request and response messages", snippet, method, data, offset)
+}
+
+func rpcStubsCompletionItem(label, detail, snippet, method string, data []byte, offset int) defines.CompletionItem {
+	stubs := fmt.Sprintf("message %sRequest {\n}\n\nmessage %sResponse {\n}\n", method, method)
+	if len(bytes.TrimSpace(data[offset:])) == 0 {
+		// an edit at the end of the file would touch the snippet
+		return snippetCompletionItem(label, detail, snippet+"\n\n"+stubs)
+	}
+	return snippetCompletionItem(label, detail, snippet, appendToFileEdit(data, stubs))
+}
+
+// appendToFileEdit appends text to the file, a blank line apart from the
+// last declaration.
+func appendToFileEdit(data []byte, text string) defines.TextEdit {
+	end := offsetPosition(data, len(data))
+	trimmed := bytes.TrimRight(data, " \t\r\n")
+	switch {
+	case len(trimmed) == 0:
+	case bytes.HasSuffix(data, []byte("\n\n")):
+	case bytes.HasSuffix(data, []byte("\n")):
+		text = "\n" + text
+	default:
+		text = "\n\n" + text
+	}
+	return defines.TextEdit{Range: defines.Range{Start: end, End: end}, NewText: text}
+}
+
+// offsetPosition returns the position of the byte offset in data.
+func offsetPosition(data []byte, offset int) defines.Position {
+	line := bytes.Count(data[:offset], []byte("\n"))
+	start := bytes.LastIndexByte(data[:offset], '\n') + 1
+	return defines.Position{Line: uint(line), Character: uint(offset - start)}
+}
+
+// fileBaseName returns the CamelCase name of the file, user_api.proto
+// gives UserApi.
+func fileBaseName(proto_file view.ProtoFile) string {
+	base := strings.TrimSuffix(path.Base(uri.URI(proto_file.URI()).Filename()), ".proto")
+	var name strings.Builder
+	upper := true
+	for _, r := range base {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		name.WriteRune(r)
+	}
+	if name.Len() == 0 || unicode.IsDigit(rune(name.String()[0])) {
+		return "Api" + name.String()
+	}
+	return name.String()
+}
+
+// uniqueMethodName returns method, numbered when the file declares its
+// request or response message already.
+func uniqueMethodName(proto_file view.ProtoFile, method string) string {
+	declared := make(map[string]bool)
+	for _, message := range proto_file.Proto().Messages() {
+		declared[message.Protobuf().Name] = true
+	}
+	name := method
+	for i := 2; declared[name+"Request"] || declared[name+"Response"]; i++ {
+		name = fmt.Sprintf("%s%d", method, i)
+	}
+	return name
+}
@@ -0,0 +1,47 @@
+package components
+
+import (
+	"testing"
+
+	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_appendToFileEdit(t *testing.T) {
+	at := func(line, character uint) defines.Range {
+		pos := defines.Position{Line: line, Character: character}
+		return defines.Range{Start: pos, End: pos}
+	}
+	require.Equal(t, defines.TextEdit{Range: at(2, 0), NewText: "\nmessage A {\n}\n"},
+		appendToFileEdit([]byte("syntax = \"proto3\";\nmessage B {}\n"), "message A {\n}\n"))
+	require.Equal(t, defines.TextEdit{Range: at(1, 12), NewText: "\n\nmessage A {\n}\n"},
+		appendToFileEdit([]byte("syntax = \"proto3\";\nmessage B {}"), "message A {\n}\n"))
+	require.Equal(t, defines.TextEdit{Range: at(2, 0), NewText: "message A {\n}\n"},
+		appendToFileEdit([]byte("syntax = \"proto3\";\n\n"), "message A {\n}\n"))
+}
+
+func Test_snippetNames(t *testing.T) {
+	content := `syntax = "proto3";
+message GetUserApiRequest {}
+message A {
+  int32 a = 1;
+  reserved 2;
+}
+`
+	proto_file := newMockProtoFile(t, "file:///user_api.proto", content)
+	require.Equal(t, "UserApi", fileBaseName(proto_file))
+	require.Equal(t, "GetUserApi2", uniqueMethodName(proto_file, "GetUserApi"))
+	require.Equal(t, "GetA", uniqueMethodName(proto_file, "GetA"))
+	require.Equal(t, "Api2", fileBaseName(newMockProtoFile(t, "file:///2.proto", content)))
+
+	require.Equal(t, "oneof ${1:name} {\n  ${2:string} ${3:field} = 3;$0\n}", *oneofSnippet(proto_file, []string{"A"}).InsertText)
+	require.Equal(t, "oneof ${1:name} {\n  ${2:string} ${3:field} = 1;$0\n}", *oneofSnippet(proto_file, []string{"Missing"}).InsertText)
+
+	item := rpcSnippet(proto_file, "UserApiService", []byte(content), len(content))
+	require.Equal(t, "rpc ${1:GetUserApi2}(GetUserApi2Request) returns (GetUserApi2Response);$0\n\n"+
+		"message GetUserApi2Request {\n}\n\nmessage GetUserApi2Response {\n}\n", *item.InsertText)
+	require.Nil(t, item.AdditionalTextEdits)
+	item = rpcSnippet(proto_file, "Users", []byte(content), 0)
+	require.Equal(t, "rpc ${1:GetUsers}(GetUsersRequest) returns (GetUsersResponse);$0", *item.InsertText)
+	require.Len(t, *item.AdditionalTextEdits, 1)
+}
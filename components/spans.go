@@ -0,0 +1,116 @@
+package components
+
+import (
+	"text/scanner"
+	"unicode/utf8"
+
+	protobuf "github.com/emicklei/proto"
+	"github.com/lasorda/protobuf-language-server/proto/parser"
+
+	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
+)
+
+// spanIndex finds the tokens of the declarations of a file in its source.
+// Positions are mapped by line and column, like parser.TokenAt does.
+type spanIndex struct {
+	data       []byte
+	p          *protoPrinter
+	lineStarts []int
+}
+
+func newSpanIndex(data []byte) *spanIndex {
+	s := &spanIndex{data: data, p: &protoPrinter{data: data, tokens: lexProto(data)}, lineStarts: []int{0}}
+	for i, c := range data {
+		if c == '\n' {
+			s.lineStarts = append(s.lineStarts, i+1)
+		}
+	}
+	return s
+}
+
+func (s *spanIndex) tokens() []formatToken {
+	return s.p.tokens
+}
+
+// offset returns the byte offset of pos, its column counts characters.
+func (s *spanIndex) offset(pos scanner.Position) int {
+	if pos.Line < 1 || pos.Line > len(s.lineStarts) {
+		return len(s.data)
+	}
+	offset := s.lineStarts[pos.Line-1]
+	for column := 1; column < pos.Column && offset < len(s.data) && s.data[offset] != '\n'; column++ {
+		_, size := utf8.DecodeRune(s.data[offset:])
+		offset += size
+	}
+	return offset
+}
+
+// elementSpan is the source of a declaration: its first token, the '{'
+// opening its block, -1 for statements, and the ';' or '}' ending it.
+type elementSpan struct {
+	start, open, end int
+}
+
+// span returns the tokens of e, a message, enum, service, oneof, rpc, field,
+// enum value or option.
+func (s *spanIndex) span(e protobuf.Visitee) (elementSpan, bool) {
+	pos, ok := parser.ElementPosition(e)
+	if !ok {
+		return elementSpan{}, false
+	}
+	start := s.p.tokenAt(s.offset(pos))
+	if start >= len(s.p.tokens) {
+		return elementSpan{}, false
+	}
+	start = s.p.labelStart(start, e)
+	res := elementSpan{start: start, open: -1}
+	switch e.(type) {
+	case *protobuf.Message, *protobuf.Enum, *protobuf.Service, *protobuf.Oneof, *protobuf.RPC, *protobuf.Group:
+		res.end = s.p.statementEnd(start, true)
+		if s.p.tokens[res.end].text == "}" {
+			res.open = s.openBrace(start, res.end)
+		}
+	default:
+		res.end = s.p.statementEnd(start, false)
+	}
+	return res, true
+}
+
+// openBrace returns the index of the first '{' from i up to end, -1 when
+// there is none.
+func (s *spanIndex) openBrace(i, end int) int {
+	for ; i <= end && i < len(s.p.tokens); i = s.p.nextToken(i) {
+		if s.p.tokens[i].text == "{" {
+			return i
+		}
+	}
+	return -1
+}
+
+// closeBrace returns the index of the '}' matching the '{' at open.
+func (s *spanIndex) closeBrace(open int) int {
+	depth := 0
+	i := open
+	for ; i < len(s.p.tokens); i = s.p.nextToken(i) {
+		switch s.p.tokens[i].text {
+		case "{":
+			depth++
+		case "}":
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(s.p.tokens) - 1
+}
+
+// spanRange returns the range of span, from the start of its first token to
+// the end of its last one.
+func spanRange(tokens []parser.Token, span parser.Span) defines.Range {
+	return defines.Range{Start: tokenPosition(tokens[span.Start].Start), End: tokenPosition(tokens[span.End].End)}
+}
+
+func tokenPosition(pos scanner.Position) defines.Position {
+	return defines.Position{Line: uint(pos.Line - 1), Character: uint(pos.Column - 1)}
+}
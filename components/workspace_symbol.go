@@ -0,0 +1,200 @@
+package components
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	protobuf "github.com/emicklei/proto"
+	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
+	"github.com/lasorda/protobuf-language-server/proto/parser"
+	"github.com/lasorda/protobuf-language-server/proto/view"
+)
+
+// maxWorkspaceSymbols bounds the result, clients only show the best matches anyway.
+const maxWorkspaceSymbols = 256
+
+type workspaceSymbol struct {
+	name      string
+	fullName  string
+	container string
+	kind      defines.SymbolKind
+	file      view.ProtoFile
+	position  protobuf.Visitee
+	score     int
+}
+
+// WorkspaceSymbol searches the messages, enums, enum values, services and rpcs
+// of every proto file in the workspace. Symbols are named by their fully
+// qualified name and matched fuzzily against the query.
+func WorkspaceSymbol(ctx context.Context, req *defines.WorkspaceSymbolParams) (*[]defines.SymbolInformation, error) {
+	var symbols []*workspaceSymbol
+	for _, proto_file := range view.ViewManager.WorkspaceFiles() {
+		if proto_file.Proto() == nil {
+			continue
+		}
+		for _, symbol := range fileSymbols(proto_file) {
+			score, ok := fuzzyScore(req.Query, symbol.name)
+			if fullScore, fullOk := fuzzyScore(req.Query, symbol.fullName); fullOk && (!ok || fullScore > score) {
+				score, ok = fullScore, true
+			}
+			if !ok {
+				continue
+			}
+			symbol.score = score
+			symbols = append(symbols, symbol)
+		}
+	}
+
+	sort.SliceStable(symbols, func(i, j int) bool {
+		if symbols[i].score != symbols[j].score {
+			return symbols[i].score > symbols[j].score
+		}
+		return symbols[i].fullName < symbols[j].fullName
+	})
+	if len(symbols) > maxWorkspaceSymbols {
+		symbols = symbols[:maxWorkspaceSymbols]
+	}
+
+	res := []defines.SymbolInformation{}
+	for _, symbol := range symbols {
+		info := defines.SymbolInformation{
+			Name: symbol.fullName,
+			Kind: symbol.kind,
+			Location: defines.Location{
+				Uri:   symbol.file.URI(),
+				Range: symbolRange(symbol),
+			},
+		}
+		if symbol.container != "" {
+			container := symbol.container
+			info.ContainerName = &container
+		}
+		res = append(res, info)
+	}
+	return &res, nil
+}
+
+// fileSymbols lists every symbol declared in proto_file.
+func fileSymbols(proto_file view.ProtoFile) (res []*workspaceSymbol) {
+	pkg := filePackage(proto_file)
+	add := func(name, container string, kind defines.SymbolKind, position protobuf.Visitee) string {
+		fullName := name
+		if container != "" {
+			fullName = container + "." + name
+		}
+		res = append(res, &workspaceSymbol{
+			name:      name,
+			fullName:  fullName,
+			container: container,
+			kind:      kind,
+			file:      proto_file,
+			position:  position,
+		})
+		return fullName
+	}
+
+	addEnum := func(enum parser.Enum, container string) {
+		fullName := add(enum.Protobuf().Name, container, defines.SymbolKindEnum, enum.Protobuf())
+		for _, element := range enum.Protobuf().Elements {
+			if value, ok := element.(*protobuf.EnumField); ok {
+				add(value.Name, fullName, defines.SymbolKindEnumMember, value)
+			}
+		}
+	}
+	var addMessage func(message parser.Message, container string)
+	addMessage = func(message parser.Message, container string) {
+		if message.Protobuf().IsExtend {
+			return
+		}
+		fullName := add(message.Protobuf().Name, container, defines.SymbolKindClass, message.Protobuf())
+		for _, nested := range message.NestedMessages() {
+			addMessage(nested, fullName)
+		}
+		for _, enum := range message.NestedEnums() {
+			addEnum(enum, fullName)
+		}
+	}
+
+	for _, message := range proto_file.Proto().Messages() {
+		addMessage(message, pkg)
+	}
+	for _, enum := range proto_file.Proto().Enums() {
+		addEnum(enum, pkg)
+	}
+	for _, service := range proto_file.Proto().Services() {
+		fullName := add(service.Protobuf().Name, pkg, defines.SymbolKindNamespace, service.Protobuf())
+		for _, rpc := range service.RPCs() {
+			add(rpc.ProtoRPC.Name, fullName, defines.SymbolKindMethod, rpc.ProtoRPC)
+		}
+	}
+	return res
+}
+
+func symbolRange(symbol *workspaceSymbol) defines.Range {
+	switch v := symbol.position.(type) {
+	case *protobuf.Message:
+		return view.TokenRange(symbol.file, v.Position, "", symbol.name)
+	case *protobuf.Enum:
+		return view.TokenRange(symbol.file, v.Position, "", symbol.name)
+	case *protobuf.EnumField:
+		return view.TokenRange(symbol.file, v.Position, "", symbol.name)
+	case *protobuf.Service:
+		return view.TokenRange(symbol.file, v.Position, "", symbol.name)
+	case *protobuf.RPC:
+		return view.TokenRange(symbol.file, v.Position, "", symbol.name)
+	}
+	return defines.Range{}
+}
+
+// fuzzyScore reports whether every character of query appears in candidate in
+// order, ignoring case. Matches at the start of words and runs of consecutive
+// characters score higher, so "GetUsr" ranks GetUserRequest above
+// GenerateTokenUsage.
+func fuzzyScore(query, candidate string) (int, bool) {
+	if query == "" {
+		return 0, true
+	}
+	q := strings.ToLower(query)
+	c := strings.ToLower(candidate)
+
+	score, qi, last := 0, 0, -1
+	for ci := 0; ci < len(c) && qi < len(q); ci++ {
+		if c[ci] != q[qi] {
+			continue
+		}
+		switch {
+		case ci == 0:
+			score += 8
+		case isWordStart(candidate, ci):
+			score += 6
+		case last == ci-1:
+			score += 5
+		default:
+			score += 1
+		}
+		last = ci
+		qi++
+	}
+	if qi < len(q) {
+		return 0, false
+	}
+	if strings.HasPrefix(c, q) {
+		score += 10
+	} else if strings.Contains(c, q) {
+		score += 5
+	}
+	if len(c) == len(q) {
+		score += 10
+	}
+	// prefer shorter names among equal matches
+	return score*100 - len(c), true
+}
+
+func isWordStart(s string, i int) bool {
+	prev, cur := s[i-1], s[i]
+	if prev == '.' || prev == '_' {
+		return true
+	}
+	return prev >= 'a' && prev <= 'z' && cur >= 'A' && cur <= 'Z'
+}
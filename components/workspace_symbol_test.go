@@ -0,0 +1,83 @@
+package components
+
+import (
+	"testing"
+
+	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_fuzzyScore(t *testing.T) {
+	tests := []struct {
+		query     string
+		candidate string
+		want      bool
+	}{
+		{"", "Foo", true},
+		{"foo", "Foo", true},
+		{"gur", "GetUserRequest", true},
+		{"usrreq", "GetUserRequest", true},
+		{"pkg.user", "pkg.User", true},
+		{"resu", "GetUserRequest", false},
+		{"fooo", "Foo", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query+"_"+tt.candidate, func(t *testing.T) {
+			_, ok := fuzzyScore(tt.query, tt.candidate)
+			require.Equal(t, tt.want, ok)
+		})
+	}
+
+	exact, _ := fuzzyScore("user", "User")
+	prefix, _ := fuzzyScore("user", "UserRequest")
+	boundary, _ := fuzzyScore("user", "GetUserRequest")
+	scattered, _ := fuzzyScore("user", "UnusedResolver")
+	require.Greater(t, exact, prefix)
+	require.Greater(t, prefix, boundary)
+	require.Greater(t, boundary, scattered)
+}
+
+func Test_fileSymbols(t *testing.T) {
+	content := `syntax = "proto3";
+package foo.bar;
+message Outer {
+  message Inner {}
+  enum Kind {
+    KIND_UNSPECIFIED = 0;
+  }
+}
+enum Status {
+  STATUS_OK = 0;
+}
+extend Outer {
+  string ext = 100;
+}
+service Greeter {
+  rpc SayHello(Outer) returns (Outer.Inner);
+}`
+	proto_file := newMockProtoFile(t, "file:///test.proto", content)
+
+	type want struct {
+		fullName  string
+		container string
+		kind      defines.SymbolKind
+		line      uint
+		character uint
+	}
+	wants := []want{
+		{"foo.bar.Outer", "foo.bar", defines.SymbolKindClass, 2, 8},
+		{"foo.bar.Outer.Inner", "foo.bar.Outer", defines.SymbolKindClass, 3, 10},
+		{"foo.bar.Outer.Kind", "foo.bar.Outer", defines.SymbolKindEnum, 4, 7},
+		{"foo.bar.Outer.Kind.KIND_UNSPECIFIED", "foo.bar.Outer.Kind", defines.SymbolKindEnumMember, 5, 4},
+		{"foo.bar.Status", "foo.bar", defines.SymbolKindEnum, 8, 5},
+		{"foo.bar.Status.STATUS_OK", "foo.bar.Status", defines.SymbolKindEnumMember, 9, 2},
+		{"foo.bar.Greeter", "foo.bar", defines.SymbolKindNamespace, 14, 8},
+		{"foo.bar.Greeter.SayHello", "foo.bar.Greeter", defines.SymbolKindMethod, 15, 6},
+	}
+	var gots []want
+	for _, symbol := range fileSymbols(proto_file) {
+		r := symbolRange(symbol)
+		gots = append(gots, want{symbol.fullName, symbol.container, symbol.kind, r.Start.Line, r.Start.Character})
+	}
+	require.Equal(t, wants, gots)
+}
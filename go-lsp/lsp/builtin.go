@@ -162,3 +162,10 @@ func (m *Methods) builtinInitialize(ctx context.Context, req *defines.Initialize
 
 	return resp, nil
 }
+
+// DefaultInitialize returns the result sent when no OnInitialize handler is
+// registered, so that a handler which only inspects the params can reuse it.
+func (m *Methods) DefaultInitialize(ctx context.Context, req *defines.InitializeParams) (*defines.InitializeResult, error) {
+	res, err := m.builtinInitialize(ctx, req)
+	return &res, err
+}
@@ -72,7 +72,7 @@ type SemanticTokensOptions struct {
 	Range *bool `json:"range,omitempty"`
 
 	// Server supports providing semantic tokens for a full document.
-	Full *bool `json:"full,omitempty"`
+	Full interface{} `json:"full,omitempty"` // bool, SemanticTokensFullOptions,
 }
 
 /**
@@ -153,3 +153,28 @@ const (
 
 	SemanticTokensRegistrationTypeType SemanticTokensRegistrationType = "new RegistrationType<SemanticTokensRegistrationOptions>(method)"
 )
+
+/**
+ * @since 3.16.0
+ */
+type SemanticTokensFullOptions struct {
+
+	// The server supports deltas for full documents.
+	Delta *bool `json:"delta,omitempty"`
+}
+
+/**
+ * The result of a `textDocument/semanticTokens/full/delta` request, either
+ * the full tokens or edits to the previous result.
+ *
+ * @since 3.16.0
+ */
+type SemanticTokensFullDeltaResult struct {
+	ResultId *string `json:"resultId,omitempty"`
+
+	// The actual tokens, set when no edits could be computed.
+	Data *[]uint `json:"data,omitempty"`
+
+	// The semantic token edits to transform a previous result into a new result.
+	Edits *[]SemanticTokensEdit `json:"edits,omitempty"`
+}
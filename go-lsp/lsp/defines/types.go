@@ -181,14 +181,14 @@ type FoldingRange struct {
 
 	// The zero-based start line of the range to fold. The folded area starts after the line's last character.
 	// To be valid, the end must be zero or larger and smaller than the number of lines in the document.
-	StartLine uint `json:"startLine,omitempty"`
+	StartLine uint `json:"startLine"`
 
 	// The zero-based character offset from where the folded range starts. If not defined, defaults to the length of the start line.
 	StartCharacter *uint `json:"startCharacter,omitempty"`
 
 	// The zero-based end line of the range to fold. The folded area ends with the line's last character.
 	// To be valid, the end must be zero or larger and smaller than the number of lines in the document.
-	EndLine uint `json:"endLine,omitempty"`
+	EndLine uint `json:"endLine"`
 
 	// The zero-based character offset before the folded range ends. If not defined, defaults to the length of the end line.
 	EndCharacter *uint `json:"endCharacter,omitempty"`
@@ -1133,7 +1133,7 @@ type SemanticTokens struct {
 	ResultId *string `json:"resultId,omitempty"`
 
 	// The actual tokens.
-	Data []uint `json:"data,omitempty"`
+	Data []uint `json:"data"`
 }
 
 /**
@@ -1142,10 +1142,10 @@ type SemanticTokens struct {
 type SemanticTokensEdit struct {
 
 	// The start offset of the edit.
-	Start uint `json:"start,omitempty"`
+	Start uint `json:"start"`
 
 	// The count of elements to remove.
-	DeleteCount uint `json:"deleteCount,omitempty"`
+	DeleteCount uint `json:"deleteCount"`
 
 	// The elements to insert.
 	Data *[]uint `json:"data,omitempty"`
@@ -1158,7 +1158,7 @@ type SemanticTokensDelta struct {
 	ResultId *string `json:"resultId,omitempty"`
 
 	// The semantic token edits to transform a previous result into a new result.
-	Edits []SemanticTokensEdit `json:"edits,omitempty"`
+	Edits []SemanticTokensEdit `json:"edits"`
 }
 
 /**
@@ -1,6 +1,6 @@
 package lsp
 
-const structItemTemp = `	on%s func(ctx context.Context, req *%s) (*%s, %s)`
+const structItemTemp = `	on%s func(ctx context.Context, req *%s) (%s, %s)`
 
 const noRespStructItemTemp = `	on%s func(ctx context.Context, req *%s) %s`
 
@@ -13,7 +13,7 @@ type Methods struct {
 `
 
 const methodsTemp = `
-func (m *Methods) On%s(f func(ctx context.Context, req *%s) (result *%s, err %s)) {
+func (m *Methods) On%s(f func(ctx context.Context, req *%s) (result %s, err %s)) {
 	m.on%s = f
 }
 `
@@ -39,14 +39,12 @@ func (m *Methods) %s(ctx context.Context, req interface{}) (interface{}, error)
 const builtinTemp = `
 	res, err := m.builtin%s(ctx, params)
 	e := wrapErrorToRespError(err, %s)
-	return res, e
-`
+	return res, e`
 
 const noRespBuiltinTemp = `
 	err := m.builtin%s(ctx, params)
 	e := wrapErrorToRespError(err, %s)
-	return nil, e
-`
+	return nil, e`
 const noBuiltinTemp = `    return nil, nil`
 
 
@@ -63,13 +61,12 @@ func (m *Methods) %s(ctx context.Context, req interface{}) (interface{}, error)
 `
 
 const methodInfoDefaultTemp = `
-    if m.on%s == nil{
+	if m.on%s == nil {
 		return nil
 	}`
 
 const methodsInfoTemp = `
-func (m *Methods) %sMethodInfo() *jsonrpc.MethodInfo {
-%s	
+func (m *Methods) %sMethodInfo() *jsonrpc.MethodInfo {%s
     return &jsonrpc.MethodInfo{
 		Name: "%s",
 		NewRequest: func() interface{} {
@@ -15,6 +15,9 @@ type method struct {
 
 type or []interface{}
 
+// anyResult is the Result of a method answering with a value of any type.
+type anyResult struct{}
+
 var methods = []method{
 	{
 		Name:        "Initialize",
@@ -35,37 +38,45 @@ var methods = []method{
 		Name: "Exit",
 	},
 	{
-		Name: "DidChangeConfiguration",
-		Args: defines.DidChangeConfigurationParams{},
+		Name:         "DidChangeConfiguration",
+		RegisterName: "workspace/didChangeConfiguration",
+		Args:         defines.DidChangeConfigurationParams{},
 	},
 	{
-		Name: "DidChangeWatchedFiles",
-		Args: defines.DidChangeWatchedFilesParams{},
+		Name:         "DidChangeWatchedFiles",
+		RegisterName: "workspace/didChangeWatchedFiles",
+		Args:         defines.DidChangeWatchedFilesParams{},
 	},
 	{
-		Name: "DidOpenTextDocument",
-		Args: defines.DidOpenTextDocumentParams{},
+		Name:         "DidOpenTextDocument",
+		RegisterName: "textDocument/didOpen",
+		Args:         defines.DidOpenTextDocumentParams{},
 	},
 	{
-		Name: "DidChangeTextDocument",
-		Args: defines.DidChangeTextDocumentParams{},
+		Name:         "DidChangeTextDocument",
+		RegisterName: "textDocument/didChange",
+		Args:         defines.DidChangeTextDocumentParams{},
 	},
 	{
-		Name: "DidCloseTextDocument",
-		Args: defines.DidCloseTextDocumentParams{},
+		Name:         "DidCloseTextDocument",
+		RegisterName: "textDocument/didClose",
+		Args:         defines.DidCloseTextDocumentParams{},
 	},
 	{
-		Name: "WillSaveTextDocument",
-		Args: defines.WillSaveTextDocumentParams{},
+		Name:         "WillSaveTextDocument",
+		RegisterName: "textDocument/willSave",
+		Args:         defines.WillSaveTextDocumentParams{},
 	},
 	{
-		Name: "DidSaveTextDocument",
-		Args: defines.DidSaveTextDocumentParams{},
+		Name:         "DidSaveTextDocument",
+		RegisterName: "textDocument/didSave",
+		Args:         defines.DidSaveTextDocumentParams{},
 	},
 	{
 		Name:          "ExecuteCommand",
+		RegisterName:  "workspace/executeCommand",
 		Args:          defines.ExecuteCommandParams{},
-		Result:        interface{}(nil),
+		Result:        anyResult{},
 		Error:         nil,
 		ProgressToken: nil,
 	},
@@ -202,7 +213,7 @@ var methods = []method{
 	},
 	{
 		Name: "PrepareRename",
-		RegisterName: "textDocument/rename",
+		RegisterName: "textDocument/prepareRename",
 		Args: defines.PrepareRenameParams{},
 		Result: defines.Range{},
 	},
@@ -247,4 +258,25 @@ var methods = []method{
 		Result: []defines.SelectionRange{},
 		ProgressToken: []defines.SelectionRange{},
 	},
+	{
+		Name: "SemanticTokensFull",
+		RegisterName: "textDocument/semanticTokens/full",
+		Args: defines.SemanticTokensParams{},
+		Result: defines.SemanticTokens{},
+		ProgressToken: defines.SemanticTokensPartialResult{},
+	},
+	{
+		Name: "SemanticTokensFullDelta",
+		RegisterName: "textDocument/semanticTokens/full/delta",
+		Args: defines.SemanticTokensDeltaParams{},
+		Result: defines.SemanticTokensFullDeltaResult{},
+		ProgressToken: defines.SemanticTokensDeltaPartialResult{},
+	},
+	{
+		Name: "SemanticTokensRange",
+		RegisterName: "textDocument/semanticTokens/range",
+		Args: defines.SemanticTokensRangeParams{},
+		Result: defines.SemanticTokens{},
+		ProgressToken: defines.SemanticTokensPartialResult{},
+	},
 }
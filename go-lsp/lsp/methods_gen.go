@@ -21,7 +21,7 @@ type Methods struct {
 	onDidCloseTextDocument                     func(ctx context.Context, req *defines.DidCloseTextDocumentParams) error
 	onWillSaveTextDocument                     func(ctx context.Context, req *defines.WillSaveTextDocumentParams) error
 	onDidSaveTextDocument                      func(ctx context.Context, req *defines.DidSaveTextDocumentParams) error
-	onExecuteCommand                           func(ctx context.Context, req *defines.ExecuteCommandParams) error
+	onExecuteCommand                           func(ctx context.Context, req *defines.ExecuteCommandParams) (interface{}, error)
 	onHover                                    func(ctx context.Context, req *defines.HoverParams) (*defines.Hover, error)
 	onCompletion                               func(ctx context.Context, req *defines.CompletionParams) (*[]defines.CompletionItem, error)
 	onCompletionResolve                        func(ctx context.Context, req *defines.CompletionItem) (*defines.CompletionItem, error)
@@ -51,6 +51,9 @@ type Methods struct {
 	onColorPresentation                        func(ctx context.Context, req *defines.ColorPresentationParams) (*[]defines.ColorPresentation, error)
 	onFoldingRanges                            func(ctx context.Context, req *defines.FoldingRangeParams) (*[]defines.FoldingRange, error)
 	onSelectionRanges                          func(ctx context.Context, req *defines.SelectionRangeParams) (*[]defines.SelectionRange, error)
+	onSemanticTokensFull                       func(ctx context.Context, req *defines.SemanticTokensParams) (*defines.SemanticTokens, error)
+	onSemanticTokensFullDelta                  func(ctx context.Context, req *defines.SemanticTokensDeltaParams) (*defines.SemanticTokensFullDeltaResult, error)
+	onSemanticTokensRange                      func(ctx context.Context, req *defines.SemanticTokensRangeParams) (*defines.SemanticTokens, error)
 }
 
 func (m *Methods) OnInitialize(f func(ctx context.Context, req *defines.InitializeParams) (result *defines.InitializeResult, err *defines.InitializeError)) {
@@ -350,16 +353,16 @@ func (m *Methods) didSaveTextDocumentMethodInfo() *jsonrpc.MethodInfo {
 	}
 }
 
-func (m *Methods) OnExecuteCommand(f func(ctx context.Context, req *defines.ExecuteCommandParams) (err error)) {
+func (m *Methods) OnExecuteCommand(f func(ctx context.Context, req *defines.ExecuteCommandParams) (result interface{}, err error)) {
 	m.onExecuteCommand = f
 }
 
 func (m *Methods) executeCommand(ctx context.Context, req interface{}) (interface{}, error) {
 	params := req.(*defines.ExecuteCommandParams)
 	if m.onExecuteCommand != nil {
-		err := m.onExecuteCommand(ctx, params)
+		res, err := m.onExecuteCommand(ctx, params)
 		e := wrapErrorToRespError(err, 0)
-		return nil, e
+		return res, e
 	}
 	return nil, nil
 }
@@ -990,7 +993,7 @@ func (m *Methods) prepareRenameMethodInfo() *jsonrpc.MethodInfo {
 		return nil
 	}
 	return &jsonrpc.MethodInfo{
-		Name: "textDocument/rename",
+		Name: "textDocument/prepareRename",
 		NewRequest: func() interface{} {
 			return &defines.PrepareRenameParams{}
 		},
@@ -1160,6 +1163,87 @@ func (m *Methods) selectionRangesMethodInfo() *jsonrpc.MethodInfo {
 	}
 }
 
+func (m *Methods) OnSemanticTokensFull(f func(ctx context.Context, req *defines.SemanticTokensParams) (result *defines.SemanticTokens, err error)) {
+	m.onSemanticTokensFull = f
+}
+
+func (m *Methods) semanticTokensFull(ctx context.Context, req interface{}) (interface{}, error) {
+	params := req.(*defines.SemanticTokensParams)
+	if m.onSemanticTokensFull != nil {
+		res, err := m.onSemanticTokensFull(ctx, params)
+		e := wrapErrorToRespError(err, 0)
+		return res, e
+	}
+	return nil, nil
+}
+
+func (m *Methods) semanticTokensFullMethodInfo() *jsonrpc.MethodInfo {
+	if m.onSemanticTokensFull == nil {
+		return nil
+	}
+	return &jsonrpc.MethodInfo{
+		Name: "textDocument/semanticTokens/full",
+		NewRequest: func() interface{} {
+			return &defines.SemanticTokensParams{}
+		},
+		Handler: m.semanticTokensFull,
+	}
+}
+
+func (m *Methods) OnSemanticTokensFullDelta(f func(ctx context.Context, req *defines.SemanticTokensDeltaParams) (result *defines.SemanticTokensFullDeltaResult, err error)) {
+	m.onSemanticTokensFullDelta = f
+}
+
+func (m *Methods) semanticTokensFullDelta(ctx context.Context, req interface{}) (interface{}, error) {
+	params := req.(*defines.SemanticTokensDeltaParams)
+	if m.onSemanticTokensFullDelta != nil {
+		res, err := m.onSemanticTokensFullDelta(ctx, params)
+		e := wrapErrorToRespError(err, 0)
+		return res, e
+	}
+	return nil, nil
+}
+
+func (m *Methods) semanticTokensFullDeltaMethodInfo() *jsonrpc.MethodInfo {
+	if m.onSemanticTokensFullDelta == nil {
+		return nil
+	}
+	return &jsonrpc.MethodInfo{
+		Name: "textDocument/semanticTokens/full/delta",
+		NewRequest: func() interface{} {
+			return &defines.SemanticTokensDeltaParams{}
+		},
+		Handler: m.semanticTokensFullDelta,
+	}
+}
+
+func (m *Methods) OnSemanticTokensRange(f func(ctx context.Context, req *defines.SemanticTokensRangeParams) (result *defines.SemanticTokens, err error)) {
+	m.onSemanticTokensRange = f
+}
+
+func (m *Methods) semanticTokensRange(ctx context.Context, req interface{}) (interface{}, error) {
+	params := req.(*defines.SemanticTokensRangeParams)
+	if m.onSemanticTokensRange != nil {
+		res, err := m.onSemanticTokensRange(ctx, params)
+		e := wrapErrorToRespError(err, 0)
+		return res, e
+	}
+	return nil, nil
+}
+
+func (m *Methods) semanticTokensRangeMethodInfo() *jsonrpc.MethodInfo {
+	if m.onSemanticTokensRange == nil {
+		return nil
+	}
+	return &jsonrpc.MethodInfo{
+		Name: "textDocument/semanticTokens/range",
+		NewRequest: func() interface{} {
+			return &defines.SemanticTokensRangeParams{}
+		},
+		Handler: m.semanticTokensRange,
+	}
+}
+
 func (m *Methods) GetMethods() []*jsonrpc.MethodInfo {
 	return []*jsonrpc.MethodInfo{
 		m.initializeMethodInfo(),
@@ -1203,5 +1287,8 @@ func (m *Methods) GetMethods() []*jsonrpc.MethodInfo {
 		m.colorPresentationMethodInfo(),
 		m.foldingRangesMethodInfo(),
 		m.selectionRangesMethodInfo(),
+		m.semanticTokensFullMethodInfo(),
+		m.semanticTokensFullDeltaMethodInfo(),
+		m.semanticTokensRangeMethodInfo(),
 	}
 }
@@ -2,6 +2,7 @@ package lsp
 
 import (
 	"fmt"
+	"go/format"
 	"io/ioutil"
 	"reflect"
 	"strings"
@@ -23,6 +24,9 @@ func removeNamePrefix(s string) string {
 
 func getTypeOne(i interface{}) typ_ {
 	t := reflect.TypeOf(i)
+	if t == reflect.TypeOf(anyResult{}) {
+		return typ_{typ: "interface{}", typName: "Any"}
+	}
 	strT := t.String()
 	name := removeNamePrefix(strT)
 	if t.Kind() == reflect.Slice {
@@ -74,6 +78,10 @@ func firstUp(s string) string {
 func generateOne(name, regName, args, result, error, code string, withBuiltin bool) (string, string, string) {
 	name = firstUp(name)
 	nameFirstLow := firstLow(name)
+	// results are pointers, except a result of any type
+	if result != "interface{}" {
+		result = "*" + result
+	}
 	structField := fmt.Sprintf(structItemTemp, name, args, result, error)
 	method := fmt.Sprintf(methodsTemp, name, args, result, error, name)
 	defaultOpt := noBuiltinTemp
@@ -118,8 +126,11 @@ func generateOneNoResp(name, regName, args, error, code string, withBuiltin bool
 }
 
 func TestMethodsGen(t *testing.T) {
-	res := generate(methods)
-	err := ioutil.WriteFile("methods_gen.go", []byte(res), 0777)
+	res, err := format.Source([]byte(generate(methods)))
+	if err != nil {
+		panic(err)
+	}
+	err = ioutil.WriteFile("methods_gen.go", res, 0777)
 	if err != nil {
 		panic(err)
 	}
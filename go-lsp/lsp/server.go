@@ -1,17 +1,22 @@
 package lsp
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"reflect"
 
 	"github.com/lasorda/protobuf-language-server/go-lsp/jsonrpc"
 	"github.com/lasorda/protobuf-language-server/go-lsp/logs"
+	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
 )
 
 type Server struct {
 	Methods
 	rpcServer *jsonrpc.Server
+
+	// the client accepts DocumentSymbol trees, set on initialize
+	hierarchicalDocumentSymbolSupport bool
 }
 
 func NewServer(opt *Options) *Server {
@@ -22,7 +27,7 @@ func NewServer(opt *Options) *Server {
 }
 
 func (s *Server) Run() {
-	mtds := s.GetMethods()
+	mtds := s.dispatchDocumentSymbol(s.GetMethods())
 	for _, m := range mtds {
 		if m != nil {
 			s.rpcServer.RegisterMethod(*m)
@@ -31,6 +36,49 @@ func (s *Server) Run() {
 
 	s.run()
 }
+
+// dispatchDocumentSymbol answers textDocument/documentSymbol with the
+// DocumentSymbol handler, or with the SymbolInformation one for clients
+// without hierarchical document symbol support when both are set.
+func (s *Server) dispatchDocumentSymbol(mtds []*jsonrpc.MethodInfo) []*jsonrpc.MethodInfo {
+	if s.onDocumentSymbolWithSliceDocumentSymbol == nil || s.onDocumentSymbolWithSliceSymbolInformation == nil {
+		return mtds
+	}
+	res := []*jsonrpc.MethodInfo{}
+	dispatched := false
+	for _, m := range mtds {
+		if m == nil {
+			continue
+		}
+		switch m.Name {
+		case "initialize":
+			handler := m.Handler
+			m.Handler = func(ctx context.Context, req interface{}) (interface{}, error) {
+				if textDocument := req.(*defines.InitializeParams).Capabilities.TextDocument; textDocument != nil && textDocument.DocumentSymbol != nil {
+					if support := textDocument.DocumentSymbol.HierarchicalDocumentSymbolSupport; support != nil {
+						s.hierarchicalDocumentSymbolSupport = *support
+					}
+				}
+				return handler(ctx, req)
+			}
+		case "textDocument/documentSymbol":
+			// registered once for both handlers
+			if dispatched {
+				continue
+			}
+			dispatched = true
+			m.Handler = func(ctx context.Context, req interface{}) (interface{}, error) {
+				if s.hierarchicalDocumentSymbolSupport {
+					return s.documentSymbolWithSliceDocumentSymbol(ctx, req)
+				}
+				return s.documentSymbolWithSliceSymbolInformation(ctx, req)
+			}
+		}
+		res = append(res, m)
+	}
+	return res
+}
+
 func (s *Server) run() {
 	addr := s.Opt.Address
 	netType := s.Opt.Network
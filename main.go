@@ -27,9 +27,33 @@ func main() {
 	flag.Parse()
 	logs.Init(logPath)
 
+	prepareRename := true
+	semanticTokensRange := true
+	semanticTokensDelta := true
+	codeLensResolve := true
 	config := &lsp.Options{
 		CompletionProvider: &defines.CompletionOptions{
-			TriggerCharacters: &[]string{"."},
+			TriggerCharacters: &[]string{".", "(", "<", "\"", "/"},
+		},
+		RenameProvider: &defines.RenameOptions{
+			PrepareProvider: &prepareRename,
+		},
+		CodeActionProvider: &defines.CodeActionOptions{
+			CodeActionKinds: &[]defines.CodeActionKind{defines.CodeActionKindQuickFix, defines.CodeActionKindSourceOrganizeImports},
+		},
+		DocumentOnTypeFormattingProvider: &defines.DocumentOnTypeFormattingOptions{
+			FirstTriggerCharacter: "=",
+		},
+		SemanticTokensProvider: &defines.SemanticTokensOptions{
+			Legend: components.SemanticTokensLegend,
+			Range:  &semanticTokensRange,
+			Full:   defines.SemanticTokensFullOptions{Delta: &semanticTokensDelta},
+		},
+		CodeLensProvider: &defines.CodeLensOptions{
+			ResolveProvider: &codeLensResolve,
+		},
+		ExecuteCommandProvider: &defines.ExecuteCommandOptions{
+			Commands: []string{components.ShowReferencesCommand},
 		},
 	}
 	if *address != "" {
@@ -40,12 +64,33 @@ func main() {
 	server := lsp.NewServer(config)
 
 	view.Init(server)
+	view.RegisterDiagnoser(components.DiagnoseUnresolvedTypes)
+	view.RegisterDiagnoser(components.DiagnoseUnusedImports)
+	view.RegisterDiagnoser(components.DiagnoseUnresolvedImports)
+	view.RegisterForgetter(components.ForgetReferences)
+	view.RegisterForgetter(components.ForgetSemanticTokens)
 	server.OnDocumentSymbolWithSliceDocumentSymbol(components.ProvideDocumentSymbol)
+	server.OnDocumentSymbolWithSliceSymbolInformation(components.ProvideSymbolInformation)
 	server.OnDefinition(components.JumpDefine)
 	server.OnReferences(components.FindReferences)
+	server.OnDocumentHighlight(components.DocumentHighlight)
+	server.OnFoldingRanges(components.FoldingRanges)
+	server.OnSelectionRanges(components.SelectionRanges)
+	server.OnDocumentLinks(components.DocumentLinks)
 	server.OnDocumentFormatting(components.Format)
 	server.OnCompletion(components.Completion)
 	server.OnHover(components.Hover)
 	server.OnDocumentRangeFormatting(components.FormatRange)
+	server.OnDocumentOnTypeFormatting(components.FormatOnType)
+	server.OnWorkspaceSymbol(components.WorkspaceSymbol)
+	server.OnPrepareRename(components.PrepareRename)
+	server.OnRenameRequest(components.Rename)
+	server.OnSemanticTokensFull(components.SemanticTokensFull)
+	server.OnSemanticTokensFullDelta(components.SemanticTokensFullDelta)
+	server.OnSemanticTokensRange(components.SemanticTokensRange)
+	server.OnCodeActionWithSliceCodeAction(components.CodeAction)
+	server.OnCodeLens(components.CodeLens)
+	server.OnCodeLensResolve(components.CodeLensResolve)
+	server.OnExecuteCommand(components.ExecuteCommand)
 	server.Run()
 }
@@ -39,6 +39,8 @@ func NewEnum(protoEnum *protobuf.Enum) Enum {
 		fieldNameToValue: make(map[string]*EnumField),
 
 		lineToEnumField: make(map[int]*EnumField),
+
+		mu: &sync.RWMutex{},
 	}
 
 	for _, e := range protoEnum.Elements {
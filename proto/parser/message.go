@@ -15,6 +15,7 @@ type Message interface {
 	Fields() []*MessageField
 	Oneofs() []Oneof
 	MapFields() []*MapField
+	Reserveds() []*Reserved
 
 	GetNestedMessageByName(name string) (Message, bool)
 	GetNestedEnumByName(name string) (Enum, bool)
@@ -41,6 +42,7 @@ type message struct {
 	fields         []*MessageField
 	oneofs         []Oneof
 	mapFields      []*MapField
+	reserveds      []*Reserved
 
 	nestedEnumNameToEnum       map[string]Enum
 	nestedMessageNameToMessage map[string]Message
@@ -95,6 +97,9 @@ func NewMessage(protoMessage *protobuf.Message) Message {
 		case *protobuf.MapField:
 			f := NewMapField(v)
 			m.mapFields = append(m.mapFields, f)
+		case *protobuf.Reserved:
+			r := NewReserved(v)
+			m.reserveds = append(m.reserveds, r)
 		case *protobuf.Enum:
 			f := NewEnum(v)
 			m.nestedEnums = append(m.nestedEnums, f)
@@ -125,7 +130,7 @@ func NewMessage(protoMessage *protobuf.Message) Message {
 		m.nestedEnumNameToEnum[f.Protobuf().Name] = f
 	}
 
-	for _, f := range m.nestedMessageNameToMessage {
+	for _, f := range m.nestedMessages {
 		m.nestedMessageNameToMessage[f.Protobuf().Name] = f
 	}
 	return m
@@ -176,6 +181,14 @@ func (m *message) MapFields() (fs []*MapField) {
 	return
 }
 
+// Reserveds returns slice of Reserved.
+func (m *message) Reserveds() (rs []*Reserved) {
+	m.mu.RLock()
+	rs = m.reserveds
+	m.mu.RUnlock()
+	return
+}
+
 // GetNestedMessageByName gets Message by provided name.
 // This ensures thread safety.
 func (m *message) GetNestedMessageByName(name string) (msg Message, ok bool) {
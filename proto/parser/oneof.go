@@ -35,6 +35,8 @@ func NewOneof(protoOneofField *protobuf.Oneof) Oneof {
 		fieldNameToField: make(map[string]*OneofField),
 
 		lineToField: make(map[int]*OneofField),
+
+		mu: &sync.RWMutex{},
 	}
 
 	for _, e := range protoOneofField.Elements {
@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"bytes"
 	"io"
 
 	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
@@ -9,10 +10,21 @@ import (
 
 // ParseProtos parses protobuf files from filenames and return parser.ProtoSet.
 func ParseProto(document_uri defines.DocumentUri, r io.Reader) (Proto, error) {
-	parser := protobuf.NewParser(r)
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	parser := protobuf.NewParser(bytes.NewReader(data))
 	p, err := parser.Parse()
 	if err != nil {
 		return nil, err
 	}
-	return NewProto(document_uri, p), nil
+	return newProtoFromSource(document_uri, p, data), nil
+}
+
+// newProtoFromSource returns the Proto of data, its tokens included.
+func newProtoFromSource(document_uri defines.DocumentUri, protoProto *protobuf.Proto, data []byte) Proto {
+	proto := NewProto(document_uri, protoProto).(*proto)
+	proto.tokens = Lex(data)
+	return proto
 }
@@ -31,8 +31,12 @@ type Proto interface {
 	GetMessageFieldByLine(line int) (*MessageField, bool)
 	GetEnumFieldByLine(line int) (*EnumField, bool)
 
+	GetParentMessageByLine(line int) (Message, bool)
 	GetAllParentMessage(line int) []Message
 	GetAllParentEnum(line int) []Enum
+
+	Tokens() []Token
+	Span(element protobuf.Visitee) (Span, bool)
 }
 
 type proto struct {
@@ -44,6 +48,8 @@ type proto struct {
 	services []Service
 	imports  []*Import
 
+	tokens []Token
+
 	packageNameToPackage map[string]*Package
 	messageNameToMessage map[string]Message
 	enumNameToEnum       map[string]Enum
@@ -111,6 +117,18 @@ func NewProto(document_uri defines.DocumentUri, protoProto *protobuf.Proto) Prot
 			proto.lineToParentMessage[f.ProtoField.Position.Line] = m
 		}
 
+		for _, f := range m.MapFields() {
+			proto.lineToParentMessage[f.ProtoMapField.Position.Line] = m
+		}
+
+		for _, o := range m.Oneofs() {
+			for _, e := range o.Protobuf().Elements {
+				if f, ok := e.(*protobuf.OneOfField); ok {
+					proto.lineToParentMessage[f.Position.Line] = m
+				}
+			}
+		}
+
 		for _, m := range m.NestedMessages() {
 			mapFiledToMessage(m)
 		}
@@ -281,6 +299,15 @@ func (p *proto) GetEnumFieldByLine(line int) (f *EnumField, ok bool) {
 	return
 }
 
+// GetParentMessageByLine gets the innermost message declaring a field on provided line.
+// This ensures thread safety.
+func (p *proto) GetParentMessageByLine(line int) (m Message, ok bool) {
+	p.mu.RLock()
+	m, ok = p.lineToParentMessage[line]
+	p.mu.RUnlock()
+	return
+}
+
 func (p *proto) GetAllParentMessage(line int) (res []Message) {
 	m, ok := p.lineToParentMessage[line]
 	if !ok {
@@ -0,0 +1,303 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"unicode/utf8"
+
+	protobuf "github.com/emicklei/proto"
+	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
+)
+
+// maxChunkRecoveries bounds how many broken lines are blanked out of a single
+// top-level declaration before it is given up.
+const maxChunkRecoveries = 10
+
+var errorPositionRegexp = regexp.MustCompile(`<input>:(\d+):(\d+)`)
+
+// ParseError is a syntax error reported by the protobuf parser.
+// Line and Column start at 1, they are 0 when the error has no position.
+type ParseError struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *ParseError) Error() string {
+	return e.Message
+}
+
+func newParseError(err error) *ParseError {
+	pe := &ParseError{Message: err.Error()}
+	matches := errorPositionRegexp.FindStringSubmatch(pe.Message)
+	if len(matches) == 3 {
+		pe.Line, _ = strconv.Atoi(matches[1])
+		pe.Column, _ = strconv.Atoi(matches[2])
+	}
+	return pe
+}
+
+// ParseProtoWithRecovery parses data like ParseProto, but does not give up at
+// the first syntax error. Every top-level declaration which fails to parse is
+// parsed again on its own, dropping its broken lines when possible, so that
+// all syntax errors are reported in one pass. The returned Proto holds every
+// well-formed top-level element and is never nil.
+func ParseProtoWithRecovery(document_uri defines.DocumentUri, data []byte) (Proto, []*ParseError) {
+	p, err := protobuf.NewParser(bytes.NewReader(data)).Parse()
+	if err == nil {
+		return newProtoFromSource(document_uri, p, data), nil
+	}
+
+	chunks := splitTopLevel(data)
+	merged := &protobuf.Proto{}
+	var errs []*ParseError
+	seen := make(map[string]bool)
+	addErr := func(e *ParseError) {
+		key := fmt.Sprintf("%d:%d", e.Line, e.Column)
+		if !seen[key] {
+			seen[key] = true
+			errs = append(errs, e)
+		}
+	}
+
+	for i := 0; i < len(chunks); {
+		elements, err := parseRange(data, chunks[i].start, len(data), merged)
+		merged.Elements = append(merged.Elements, elements...)
+		if err == nil {
+			break
+		}
+
+		covered := make(map[int]bool)
+		for _, e := range elements {
+			if pos, ok := ElementPosition(e); ok {
+				for j := i; j < len(chunks); j++ {
+					if chunks[j].contains(pos.Line, pos.Column) {
+						covered[j] = true
+						break
+					}
+				}
+			}
+		}
+
+		failed := -1
+		for j := i; j < len(chunks); j++ {
+			if covered[j] {
+				continue
+			}
+			elements, chunkErrs := parseChunk(data, chunks[j], merged)
+			merged.Elements = append(merged.Elements, elements...)
+			if len(chunkErrs) == 0 {
+				continue
+			}
+			for _, e := range chunkErrs {
+				addErr(e)
+			}
+			failed = j
+			break
+		}
+		if failed == -1 {
+			// every declaration parses on its own, report what the whole file says
+			addErr(newParseError(err))
+			break
+		}
+		i = failed + 1
+	}
+
+	return newProtoFromSource(document_uri, merged, data), errs
+}
+
+// parseChunk parses a single top-level declaration. On error the offending
+// line is blanked and the declaration parsed again, unless the line opens or
+// closes a block.
+func parseChunk(data []byte, c chunk, parent *protobuf.Proto) ([]protobuf.Visitee, []*ParseError) {
+	buf := make([]byte, c.end)
+	copy(buf, data[:c.end])
+
+	var errs []*ParseError
+	blanked := make(map[int]bool)
+	for attempt := 0; attempt <= maxChunkRecoveries; attempt++ {
+		elements, err := parseRange(buf, c.start, c.end, parent)
+		if err == nil {
+			return elements, errs
+		}
+		pe := newParseError(err)
+		errs = append(errs, pe)
+
+		from, to, ok := lineBounds(buf, pe.Line)
+		if !ok || blanked[pe.Line] || from < c.start || to > c.end {
+			break
+		}
+		line := buf[from:to]
+		if bytes.ContainsAny(line, "{}") {
+			break
+		}
+		for k := range line {
+			line[k] = ' '
+		}
+		blanked[pe.Line] = true
+	}
+	return nil, errs
+}
+
+// parseRange parses data[start:end] keeping line and column numbers of the
+// whole buffer.
+func parseRange(data []byte, start, end int, parent *protobuf.Proto) ([]protobuf.Visitee, error) {
+	lines := bytes.Count(data[:start], []byte("\n"))
+	lineStart := bytes.LastIndexByte(data[:start], '\n') + 1
+	columns := utf8.RuneCount(data[lineStart:start])
+
+	buf := bytes.NewBuffer(make([]byte, 0, lines+columns+end-start))
+	buf.Write(bytes.Repeat([]byte("\n"), lines))
+	buf.Write(bytes.Repeat([]byte(" "), columns))
+	buf.Write(data[start:end])
+
+	p, err := protobuf.NewParser(buf).Parse()
+	if p == nil {
+		return nil, err
+	}
+	for _, e := range p.Elements {
+		setParent(e, parent)
+	}
+	return p.Elements, err
+}
+
+func setParent(e protobuf.Visitee, parent *protobuf.Proto) {
+	switch v := e.(type) {
+	case *protobuf.Message:
+		v.Parent = parent
+	case *protobuf.Enum:
+		v.Parent = parent
+	case *protobuf.Service:
+		v.Parent = parent
+	case *protobuf.Import:
+		v.Parent = parent
+	case *protobuf.Package:
+		v.Parent = parent
+	case *protobuf.Option:
+		v.Parent = parent
+	case *protobuf.Syntax:
+		v.Parent = parent
+	}
+}
+
+// lineBounds returns the byte range of line (starting at 1) without its newline.
+func lineBounds(data []byte, line int) (from, to int, ok bool) {
+	if line < 1 {
+		return 0, 0, false
+	}
+	for n := 1; n < line; n++ {
+		idx := bytes.IndexByte(data[from:], '\n')
+		if idx == -1 {
+			return 0, 0, false
+		}
+		from += idx + 1
+	}
+	to = len(data)
+	if idx := bytes.IndexByte(data[from:], '\n'); idx != -1 {
+		to = from + idx
+	}
+	return from, to, true
+}
+
+// chunk is the byte range of one top-level declaration, including the
+// comments in front of it. Lines and columns start at 1, like scanner.Position.
+type chunk struct {
+	start, end             int
+	startLine, startColumn int
+	endLine, endColumn     int
+}
+
+func (c chunk) contains(line, column int) bool {
+	if line < c.startLine || (line == c.startLine && column < c.startColumn) {
+		return false
+	}
+	return line < c.endLine || (line == c.endLine && column < c.endColumn)
+}
+
+var topLevelKeywords = [][]byte{[]byte("message"), []byte("enum"), []byte("service"), []byte("extend")}
+
+// splitTopLevel splits data at the end of every top-level statement or block.
+// A declaration keyword at the very start of a line also starts a new chunk,
+// so a block which is not closed yet does not swallow the rest of the file.
+func splitTopLevel(data []byte) (chunks []chunk) {
+	depth, start := 0, 0
+	hasCode := false
+	flush := func(end int) {
+		if hasCode {
+			chunks = append(chunks, newChunk(data, start, end))
+		}
+		start, hasCode, depth = end, false, 0
+	}
+
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			end := bytes.Index(data[i+2:], []byte("*/"))
+			if end == -1 {
+				i = len(data)
+			} else {
+				i += end + 3
+			}
+		case c == '"' || c == '\'':
+			hasCode = true
+			for i++; i < len(data) && data[i] != c && data[i] != '\n'; i++ {
+				if data[i] == '\\' {
+					i++
+				}
+			}
+		case c == '{':
+			hasCode = true
+			depth++
+		case c == '}':
+			hasCode = true
+			depth--
+			if depth <= 0 {
+				flush(i + 1)
+			}
+		case c == ';':
+			hasCode = true
+			if depth == 0 {
+				flush(i + 1)
+			}
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+		default:
+			if depth > 0 && (i == 0 || data[i-1] == '\n') && startsWithKeyword(data[i:]) {
+				flush(i)
+			}
+			hasCode = true
+		}
+	}
+	flush(len(data))
+	return chunks
+}
+
+func startsWithKeyword(data []byte) bool {
+	for _, keyword := range topLevelKeywords {
+		if bytes.HasPrefix(data, keyword) && len(data) > len(keyword) &&
+			(data[len(keyword)] == ' ' || data[len(keyword)] == '\t') {
+			return true
+		}
+	}
+	return false
+}
+
+func newChunk(data []byte, start, end int) chunk {
+	c := chunk{start: start, end: end}
+	c.startLine, c.startColumn = lineColumn(data, start)
+	c.endLine, c.endColumn = lineColumn(data, end)
+	return c
+}
+
+func lineColumn(data []byte, offset int) (line, column int) {
+	line = bytes.Count(data[:offset], []byte("\n")) + 1
+	lineStart := bytes.LastIndexByte(data[:offset], '\n') + 1
+	column = utf8.RuneCount(data[lineStart:offset]) + 1
+	return line, column
+}
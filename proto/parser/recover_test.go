@@ -0,0 +1,98 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseProtoWithRecovery(t *testing.T) {
+	tests := []struct {
+		name         string
+		content      string
+		wantErrLines []int
+		wantMessages []string
+		wantFields   map[string][]string
+	}{
+		{
+			name: "valid file",
+			content: `syntax = "proto3";
+message Foo {
+  string a = 1;
+}`,
+			wantMessages: []string{"Foo"},
+			wantFields:   map[string][]string{"Foo": {"a"}},
+		},
+		{
+			name: "broken field keeps the rest of the message",
+			content: `syntax = "proto3";
+message Foo {
+  string a = 1;
+  int32 b = ;
+  string c = 3;
+}
+message Bar {
+  Foo foo = 1;
+}`,
+			wantErrLines: []int{4},
+			wantMessages: []string{"Foo", "Bar"},
+			wantFields:   map[string][]string{"Foo": {"a", "c"}, "Bar": {"foo"}},
+		},
+		{
+			name: "every broken declaration is reported",
+			content: `syntax = "proto3";
+message Foo {
+  string a = ;
+}
+enum Status {
+  STATUS_UNSPECIFIED = 0
+}
+message Bar {
+  Foo foo 1;
+}
+message Baz {}`,
+			wantErrLines: []int{3, 7, 9},
+			wantMessages: []string{"Foo", "Bar", "Baz"},
+		},
+		{
+			name: "unclosed message does not swallow the following ones",
+			content: `syntax = "proto3";
+message Foo {
+  string a = 1;
+
+message Bar {
+  string b = 1;
+}`,
+			wantErrLines: []int{5},
+			wantMessages: []string{"Bar"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proto, errs := ParseProtoWithRecovery("file:///test.proto", []byte(tt.content))
+			require.NotNil(t, proto)
+
+			var errLines []int
+			for _, err := range errs {
+				errLines = append(errLines, err.Line)
+			}
+			require.Equal(t, tt.wantErrLines, errLines)
+
+			var messages []string
+			for _, m := range proto.Messages() {
+				messages = append(messages, m.Protobuf().Name)
+			}
+			require.Equal(t, tt.wantMessages, messages)
+
+			for name, wantFields := range tt.wantFields {
+				m, ok := proto.GetMessageByName(name)
+				require.True(t, ok, name)
+				var fields []string
+				for _, f := range m.Fields() {
+					fields = append(fields, f.ProtoField.Name)
+				}
+				require.Equal(t, wantFields, fields, name)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package parser
+
+import protobuf "github.com/emicklei/proto"
+
+// Reserved is a registry for protobuf reserved statement.
+type Reserved struct {
+	ProtoReserved *protobuf.Reserved
+}
+
+// NewReserved returns Reserved initialized by provided *protobuf.Reserved.
+func NewReserved(protoReserved *protobuf.Reserved) *Reserved {
+	return &Reserved{
+		ProtoReserved: protoReserved,
+	}
+}
+
+// ContainsNumber reports whether number is inside one of the reserved ranges.
+func (r *Reserved) ContainsNumber(number int) bool {
+	for _, rg := range r.ProtoReserved.Ranges {
+		if number >= rg.From && (rg.Max || number <= rg.To) {
+			return true
+		}
+	}
+	return false
+}
+
+// ContainsName reports whether name is one of the reserved field names.
+func (r *Reserved) ContainsName(name string) bool {
+	for _, n := range r.ProtoReserved.FieldNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,208 @@
+package parser
+
+import (
+	"sort"
+	"strings"
+	"text/scanner"
+	"unicode/utf8"
+
+	protobuf "github.com/emicklei/proto"
+)
+
+// Token is a token of the proto source, comments included. Start is at its
+// first character and End after its last one, columns count characters.
+type Token struct {
+	Text    string
+	Start   scanner.Position
+	End     scanner.Position
+	Comment bool
+}
+
+// Lex splits data into tokens. Identifiers and numbers, dots included, are
+// one token, strings and comments are kept with their delimiters.
+func Lex(data []byte) (tokens []Token) {
+	line, lineStart := 1, 0
+	position := func(offset int) scanner.Position {
+		return scanner.Position{Offset: offset, Line: line, Column: utf8.RuneCount(data[lineStart:offset]) + 1}
+	}
+	for i := 0; i < len(data); {
+		c := data[i]
+		start := i
+		startPos := position(i)
+		switch {
+		case c == '\n':
+			i++
+			line, lineStart = line+1, i
+			continue
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v':
+			i++
+			continue
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			i += 2
+			for i < len(data) && !(data[i] == '*' && i+1 < len(data) && data[i+1] == '/') {
+				if data[i] == '\n' {
+					line, lineStart = line+1, i+1
+				}
+				i++
+			}
+			i = minInt(i+2, len(data))
+		case c == '"' || c == '\'':
+			i++
+			for i < len(data) && data[i] != c && data[i] != '\n' {
+				if data[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			i = minInt(i+1, len(data))
+		case isWordChar(c):
+			for i < len(data) && (isWordChar(data[i]) ||
+				(data[i] == '+' || data[i] == '-') && (data[i-1] == 'e' || data[i-1] == 'E') && data[start] >= '0' && data[start] <= '9') {
+				i++
+			}
+		default:
+			i++
+		}
+		text := string(data[start:i])
+		tokens = append(tokens, Token{
+			Text:    text,
+			Start:   startPos,
+			End:     position(i),
+			Comment: strings.HasPrefix(text, "//") || strings.HasPrefix(text, "/*"),
+		})
+	}
+	return tokens
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || c == '.' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Span is the source of a declaration, the index of its first token, the
+// label of a field, and of the ';' or '}' ending it.
+type Span struct {
+	Start, End int
+}
+
+// ElementPosition returns the position of e, a declaration of a file,
+// message, enum, service, oneof or rpc.
+func ElementPosition(e protobuf.Visitee) (scanner.Position, bool) {
+	switch v := e.(type) {
+	case *protobuf.Syntax:
+		return v.Position, true
+	case *protobuf.Edition:
+		return v.Position, true
+	case *protobuf.Package:
+		return v.Position, true
+	case *protobuf.Import:
+		return v.Position, true
+	case *protobuf.Option:
+		return v.Position, true
+	case *protobuf.Message:
+		return v.Position, true
+	case *protobuf.Enum:
+		return v.Position, true
+	case *protobuf.EnumField:
+		return v.Position, true
+	case *protobuf.Service:
+		return v.Position, true
+	case *protobuf.RPC:
+		return v.Position, true
+	case *protobuf.Oneof:
+		return v.Position, true
+	case *protobuf.NormalField:
+		return v.Position, true
+	case *protobuf.MapField:
+		return v.Position, true
+	case *protobuf.OneOfField:
+		return v.Position, true
+	case *protobuf.Group:
+		return v.Position, true
+	case *protobuf.Reserved:
+		return v.Position, true
+	case *protobuf.Extensions:
+		return v.Position, true
+	}
+	return scanner.Position{}, false
+}
+
+// TokenAt returns the index of the first token starting at or after pos.
+// Tokens are looked up by line and column, the offsets of declarations
+// recovered from parse errors are not those of the file.
+func TokenAt(tokens []Token, pos scanner.Position) int {
+	return sort.Search(len(tokens), func(i int) bool {
+		start := tokens[i].Start
+		return start.Line > pos.Line || start.Line == pos.Line && start.Column >= pos.Column
+	})
+}
+
+// Tokens returns the tokens of the source the proto was parsed from.
+func (p *proto) Tokens() []Token {
+	return p.tokens
+}
+
+// Span returns the tokens of element.
+func (p *proto) Span(element protobuf.Visitee) (Span, bool) {
+	pos, ok := ElementPosition(element)
+	if !ok {
+		return Span{}, false
+	}
+	start := TokenAt(p.tokens, pos)
+	if start >= len(p.tokens) {
+		return Span{}, false
+	}
+	switch element.(type) {
+	case *protobuf.NormalField, *protobuf.Group:
+		// the position is at the type, a label comes before it
+		for i := start - 1; i >= 0 && !p.tokens[i].Comment; i-- {
+			if t := p.tokens[i].Text; t != "repeated" && t != "optional" && t != "required" {
+				break
+			}
+			start = i
+		}
+	}
+	block := false
+	switch element.(type) {
+	case *protobuf.Message, *protobuf.Enum, *protobuf.Service, *protobuf.Oneof, *protobuf.RPC, *protobuf.Group:
+		block = true
+	}
+	return Span{Start: start, End: p.statementEnd(start, block)}, true
+}
+
+// statementEnd returns the index of the token ending the statement starting
+// at i, the ';' or for blocks the matching '}'.
+func (p *proto) statementEnd(i int, block bool) int {
+	depth := 0
+	last := i
+	for ; i < len(p.tokens); i++ {
+		if p.tokens[i].Comment {
+			continue
+		}
+		last = i
+		switch p.tokens[i].Text {
+		case "{", "[", "(":
+			depth++
+		case "}", "]", ")":
+			depth--
+			if depth == 0 && block && p.tokens[i].Text == "}" {
+				return i
+			}
+		case ";":
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return last
+}
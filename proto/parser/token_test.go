@@ -0,0 +1,63 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_proto_Span(t *testing.T) {
+	content := `syntax = "proto3";
+message Foo {
+  string a = 1;
+  garbage
+}
+
+// Bär
+message Bar {
+  repeated string b = 1 [(opt) = { a: "}" }];
+  oneof c {
+    int32 d = 2;
+  }
+}
+`
+	proto, errs := ParseProtoWithRecovery("file:///test.proto", []byte(content))
+	require.NotEmpty(t, errs)
+
+	text := func(span Span) string {
+		tokens := proto.Tokens()
+		return tokens[span.Start].Text + " " + tokens[span.End].Text
+	}
+	bar, ok := proto.GetMessageByName("Bar")
+	require.True(t, ok)
+	span, ok := proto.Span(bar.Protobuf())
+	require.True(t, ok)
+	require.Equal(t, "message }", text(span))
+	require.Equal(t, 13, proto.Tokens()[span.End].Start.Line)
+
+	field := bar.Fields()[0].ProtoField
+	span, ok = proto.Span(field)
+	require.True(t, ok)
+	require.Equal(t, "repeated ;", text(span))
+	require.Equal(t, 9, proto.Tokens()[span.Start].Start.Line)
+	require.Equal(t, 3, proto.Tokens()[span.Start].Start.Column)
+
+	span, ok = proto.Span(bar.Oneofs()[0].Protobuf())
+	require.True(t, ok)
+	require.Equal(t, 12, proto.Tokens()[span.End].Start.Line)
+}
+
+func Test_Lex(t *testing.T) {
+	tokens := Lex([]byte("/* a\n b */ x.y = \"ü\"; // c\n"))
+	var texts []string
+	for _, token := range tokens {
+		texts = append(texts, token.Text)
+	}
+	require.Equal(t, []string{"/* a\n b */", "x.y", "=", `"ü"`, ";", "// c"}, texts)
+	require.True(t, tokens[0].Comment)
+	require.Equal(t, 2, tokens[0].End.Line)
+	require.Equal(t, 2, tokens[1].Start.Line)
+	require.Equal(t, 7, tokens[1].Start.Column)
+	// columns count characters
+	require.Equal(t, 16, tokens[4].Start.Column)
+}
@@ -38,3 +38,29 @@ var BuildInProtoTypes = []ProtoType{
 	String,
 	Bytes,
 }
+
+// MapKeyProtoTypes are the scalar value types a map key may have.
+var MapKeyProtoTypes = []ProtoType{
+	Int32,
+	Int64,
+	Uint32,
+	Uint64,
+	Sint32,
+	Sint64,
+	Fixed32,
+	Fixed64,
+	Sfixed32,
+	Sfixed64,
+	Bool,
+	String,
+}
+
+// IsBuildInProtoType reports whether name is one of the scalar value types.
+func IsBuildInProtoType(name string) bool {
+	for _, t := range BuildInProtoTypes {
+		if string(t) == name {
+			return true
+		}
+	}
+	return false
+}
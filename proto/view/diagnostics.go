@@ -0,0 +1,148 @@
+package view
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"text/scanner"
+
+	protobuf "github.com/emicklei/proto"
+	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
+	"github.com/lasorda/protobuf-language-server/proto/parser"
+)
+
+const (
+	minFieldNumber = 1
+	maxFieldNumber = 536870911
+
+	// numbers reserved for the protocol buffers implementation
+	firstReservedFieldNumber = 19000
+	lastReservedFieldNumber  = 19999
+)
+
+// Diagnoser reports semantic problems of a file which parsed without syntax errors.
+type Diagnoser func(file ProtoFile) []defines.Diagnostic
+
+var (
+	diagnosers   = []Diagnoser{diagnoseFieldNumbers}
+	diagnosersMu = &sync.RWMutex{}
+)
+
+// RegisterDiagnoser adds a semantic check which runs every time an open file is parsed.
+func RegisterDiagnoser(d Diagnoser) {
+	diagnosersMu.Lock()
+	diagnosers = append(diagnosers, d)
+	diagnosersMu.Unlock()
+}
+
+func semanticDiagnostics(file ProtoFile) (res []defines.Diagnostic) {
+	diagnosersMu.RLock()
+	defer diagnosersMu.RUnlock()
+
+	for _, d := range diagnosers {
+		res = append(res, d(file)...)
+	}
+	return res
+}
+
+// numberedField is a normal, oneof or map field of a message.
+type numberedField struct {
+	name     string
+	typeName string
+	number   int
+	position scanner.Position
+}
+
+func messageNumberedFields(m parser.Message) (fields []numberedField) {
+	// keep declaration order, duplicates are reported on the later field
+	for _, e := range m.Protobuf().Elements {
+		switch v := e.(type) {
+		case *protobuf.NormalField:
+			fields = append(fields, numberedField{v.Name, v.Type, v.Sequence, v.Position})
+		case *protobuf.MapField:
+			fields = append(fields, numberedField{v.Name, v.Type, v.Sequence, v.Position})
+		case *protobuf.Oneof:
+			for _, oe := range v.Elements {
+				if f, ok := oe.(*protobuf.OneOfField); ok {
+					fields = append(fields, numberedField{f.Name, f.Type, f.Sequence, f.Position})
+				}
+			}
+		}
+	}
+	return fields
+}
+
+// diagnoseFieldNumbers reports duplicate, out of range and reserved field
+// numbers, and fields reusing a reserved name.
+func diagnoseFieldNumbers(file ProtoFile) (res []defines.Diagnostic) {
+	if file.Proto() == nil {
+		return nil
+	}
+	severity := defines.DiagnosticSeverityError
+	report := func(r defines.Range, format string, a ...interface{}) {
+		res = append(res, defines.Diagnostic{
+			Range:    r,
+			Severity: &severity,
+			Message:  fmt.Sprintf(format, a...),
+		})
+	}
+
+	var walk func(messages []parser.Message)
+	walk = func(messages []parser.Message) {
+		for _, m := range messages {
+			used := make(map[int]string)
+			for _, f := range messageNumberedFields(m) {
+				numberRange := TokenRange(file, f.position, "=", fieldNumberText(file, f))
+				nameRange := TokenRange(file, f.position, f.typeName, f.name)
+
+				switch {
+				case f.number < minFieldNumber || f.number > maxFieldNumber:
+					report(numberRange, "field number %d of %q is out of range %d to %d", f.number, f.name, minFieldNumber, maxFieldNumber)
+				case f.number >= firstReservedFieldNumber && f.number <= lastReservedFieldNumber:
+					report(numberRange, "field number %d of %q is reserved for the protocol buffers implementation (%d to %d)",
+						f.number, f.name, firstReservedFieldNumber, lastReservedFieldNumber)
+				}
+				if other, ok := used[f.number]; ok {
+					report(numberRange, "field number %d of %q is already used by %q", f.number, f.name, other)
+				} else {
+					used[f.number] = f.name
+				}
+				for _, r := range m.Reserveds() {
+					if r.ContainsNumber(f.number) {
+						report(numberRange, "field number %d of %q is reserved", f.number, f.name)
+					}
+					if r.ContainsName(f.name) {
+						report(nameRange, "field name %q is reserved", f.name)
+					}
+				}
+			}
+			walk(m.NestedMessages())
+		}
+	}
+	walk(file.Proto().Messages())
+	return res
+}
+
+// fieldNumberText returns the number of f as written in the source, which may
+// be hexadecimal or octal.
+func fieldNumberText(file ProtoFile, f numberedField) string {
+	line := file.ReadLine(f.position.Line - 1)
+	start := f.position.Column - 1
+	if start < 0 || start > len(line) {
+		start = 0
+	}
+	idx := strings.Index(line[start:], "=")
+	if idx == -1 {
+		return strconv.Itoa(f.number)
+	}
+	text := strings.TrimLeft(line[start+idx+1:], " \t")
+	end := 0
+	for end < len(text) && isTokenChar(text[end]) {
+		end++
+	}
+	if end == 0 {
+		return strconv.Itoa(f.number)
+	}
+	return text[:end]
+}
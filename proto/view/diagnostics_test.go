@@ -0,0 +1,65 @@
+package view
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
+	"github.com/lasorda/protobuf-language-server/proto/parser"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestProtoFile(t *testing.T, content string) ProtoFile {
+	t.Helper()
+	document_uri := defines.DocumentUri("file:///test.proto")
+	proto, err := parser.ParseProto(document_uri, bytes.NewBufferString(content))
+	require.NoError(t, err)
+	return &protoFile{
+		File:  &file{document_uri: document_uri, data: []byte(content)},
+		proto: proto,
+	}
+}
+
+func Test_diagnoseFieldNumbers(t *testing.T) {
+	content := `syntax = "proto3";
+message Foo {
+  reserved 10 to 12, 100 to 200;
+  reserved "legacy";
+  string a = 1;
+  string b = 1;
+  map<string, int32> c = 2;
+  oneof choice {
+    int32 d = 2;
+  }
+  string legacy = 3;
+  string e = 11;
+  string f = 19001;
+  string g = 0;
+  message Bar {
+    int32 x = 5;
+    int32 y = 5;
+    int32 z = 0x5;
+  }
+}`
+	got := diagnoseFieldNumbers(newTestProtoFile(t, content))
+
+	type want struct {
+		line, start, end uint
+		message          string
+	}
+	wants := []want{
+		{5, 13, 14, `field number 1 of "b" is already used by "a"`},
+		{8, 14, 15, `field number 2 of "d" is already used by "c"`},
+		{10, 9, 15, `field name "legacy" is reserved`},
+		{11, 13, 15, `field number 11 of "e" is reserved`},
+		{12, 13, 18, `field number 19001 of "f" is reserved for the protocol buffers implementation (19000 to 19999)`},
+		{13, 13, 14, `field number 0 of "g" is out of range 1 to 536870911`},
+		{16, 14, 15, `field number 5 of "y" is already used by "x"`},
+		{17, 14, 17, `field number 5 of "z" is already used by "x"`},
+	}
+	var gots []want
+	for _, d := range got {
+		gots = append(gots, want{d.Range.Start.Line, d.Range.Start.Character, d.Range.End.Character, d.Message})
+	}
+	require.Equal(t, wants, gots)
+}
@@ -3,6 +3,7 @@ package view
 import (
 	"context"
 	"strings"
+	"text/scanner"
 
 	"github.com/lasorda/protobuf-language-server/proto/parser"
 
@@ -77,3 +78,50 @@ func (p *protoFile) Proto() parser.Proto {
 func (p *protoFile) SetProto(proto parser.Proto) {
 	p.proto = proto
 }
+
+// TokenRange finds token on the line of pos, searching from the column of pos
+// and after the first occurrence of after if it is not empty. Only whole
+// tokens match, "Foo" is not found inside "Foo.Bar". It falls back to pos when
+// the token is written on another line.
+func TokenRange(f File, pos scanner.Position, after string, token string) defines.Range {
+	line := f.ReadLine(pos.Line - 1)
+	start := pos.Column - 1
+	if start < 0 || start > len(line) {
+		start = 0
+	}
+	if after != "" {
+		if idx := strings.Index(line[start:], after); idx != -1 {
+			start += idx + len(after)
+		}
+	}
+
+	found := -1
+	for idx := start; idx < len(line) && token != ""; {
+		i := strings.Index(line[idx:], token)
+		if i == -1 {
+			break
+		}
+		i += idx
+		end := i + len(token)
+		if (i == 0 || !isTokenChar(line[i-1])) && (end >= len(line) || !isTokenChar(line[end])) {
+			found = i
+			break
+		}
+		idx = i + 1
+	}
+	if found == -1 {
+		found = pos.Column - 1
+		if found < 0 {
+			found = 0
+		}
+	}
+
+	return defines.Range{
+		Start: defines.Position{Line: uint(pos.Line - 1), Character: uint(found)},
+		End:   defines.Position{Line: uint(pos.Line - 1), Character: uint(found + len(token))},
+	}
+}
+
+func isTokenChar(ch byte) bool {
+	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (ch >= '0' && ch <= '9') || ch == '_' || ch == '.'
+}
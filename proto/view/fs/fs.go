@@ -1,5 +1,8 @@
 package fs
 
+import "os"
+
 type FS interface {
 	FileExists(path string) bool
+	ReadDir(path string) ([]os.DirEntry, error)
 }
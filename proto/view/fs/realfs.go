@@ -11,3 +11,7 @@ func (r *RealFS) FileExists(path string) bool {
 	_, err := os.Stat(path)
 	return err == nil
 }
+
+func (r *RealFS) ReadDir(path string) ([]os.DirEntry, error) {
+	return os.ReadDir(path)
+}
@@ -0,0 +1,218 @@
+package view
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/lasorda/protobuf-language-server/go-lsp/logs"
+	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
+	"go.lsp.dev/uri"
+)
+
+// generatedSuffixes are the suffixes protoc plugins give the files generated
+// for a proto file, longest first.
+var generatedSuffixes = []string{
+	"_grpc.pb.go", ".grpc.pb.cc", ".grpc.pb.h", "_pb2_grpc.py",
+	".pb.go", ".pb.cc", ".pb.h", "_pb2.py",
+}
+
+// protoc writes the import path of the proto file into the header of the
+// files it generates, like "// source: api/user.proto"
+var sourceCommentRegex = regexp.MustCompile(`^(?://|#) source: (\S+\.proto)\s*$`)
+
+// the number of lines the source comment is looked up in
+const sourceCommentLines = 30
+
+func IsGeneratedFile(document_uri defines.DocumentUri) bool {
+	_, ok := generatedProtoName(string(document_uri))
+	return ok
+}
+
+// generatedProtoName returns the name of the proto file the generated file
+// name is named after, user.pb.go gives user.proto.
+func generatedProtoName(name string) (string, bool) {
+	for _, suffix := range generatedSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return strings.TrimSuffix(name, suffix) + ".proto", true
+		}
+	}
+	return "", false
+}
+
+// sourceImportPath returns the import path of the source comment in lines,
+// "" when there is none.
+func sourceImportPath(lines []string) string {
+	for i, line := range lines {
+		if i == sourceCommentLines {
+			break
+		}
+		if match := sourceCommentRegex.FindStringSubmatch(strings.TrimRight(line, "\r")); match != nil {
+			return match[1]
+		}
+	}
+	return ""
+}
+
+func (v *view) didOpenGenerated(document_uri defines.DocumentUri, text string) {
+	v.generatedMu.Lock()
+	v.generatedFiles[document_uri] = strings.Split(text, "\n")
+	v.generatedMu.Unlock()
+}
+
+func (v *view) didCloseGenerated(document_uri defines.DocumentUri) {
+	v.generatedMu.Lock()
+	delete(v.generatedFiles, document_uri)
+	v.generatedMu.Unlock()
+}
+
+// generatedLines returns the lines of an open generated file, or of the file
+// on disk.
+func (v *view) generatedLines(document_uri defines.DocumentUri) []string {
+	v.generatedMu.RLock()
+	lines, ok := v.generatedFiles[document_uri]
+	v.generatedMu.RUnlock()
+	if ok {
+		return lines
+	}
+	data, err := readFile(document_uri)
+	if err != nil {
+		return nil
+	}
+	return strings.Split(string(data), "\n")
+}
+
+func (v *view) GetGeneratedLine(document_uri defines.DocumentUri, line int) string {
+	lines := v.generatedLines(document_uri)
+	if len(lines) <= line {
+		return ""
+	}
+	return lines[line]
+}
+
+// GeneratedProtoFile returns the proto file the generated file document_uri
+// was generated from. The import path of its source comment is looked up in
+// the import roots of the file and in the proto roots of the output root it
+// is under, then its name is mapped through the output root and rewritten by
+// the path rules.
+func (v *view) GeneratedProtoFile(document_uri defines.DocumentUri) (defines.DocumentUri, error) {
+	filename := uri.URI(document_uri).Filename()
+	proto_name, ok := generatedProtoName(filename)
+	if !ok {
+		return "", fmt.Errorf("%w: %s is not a generated file", ErrNotFound, filename)
+	}
+	output_roots := v.outputRoots(filename)
+	var candidates []string
+	if source := sourceImportPath(v.generatedLines(document_uri)); source != "" {
+		if source_uri, err := v.GetDocumentUriFromImportPath(document_uri, source); err == nil {
+			return source_uri, nil
+		}
+		for _, root := range output_roots {
+			candidates = append(candidates, path.Join(root.protoRoot, source))
+		}
+	}
+	for _, root := range output_roots {
+		rel, err := filepath.Rel(root.outputRoot, proto_name)
+		if err == nil {
+			candidates = append(candidates, path.Join(root.protoRoot, filepath.ToSlash(rel)))
+		}
+	}
+	candidates = append(candidates, v.rewrittenProtoNames(proto_name)...)
+	// protoc run from the directory of the proto file
+	candidates = append(candidates, proto_name)
+	for _, candidate := range candidates {
+		if v.fs.FileExists(candidate) {
+			return defines.DocumentUri(uri.New(path.Clean(candidate))), nil
+		}
+	}
+	logs.Printf("GeneratedProtoFile no proto file for %s, output roots %v, path rules %v, tried %v",
+		filename, v.Settings().GeneratedOutputRoots, v.pathRules(), candidates)
+	return "", fmt.Errorf("%w: no proto file for %s", ErrNotFound, filename)
+}
+
+// bazelPathRules map the output roots of bazel back to the source tree, for
+// paths under the bazel-out and bazel-bin links of the workspace, under the
+// execroot they point to, and for absolute paths outside the workspace.
+var bazelPathRules = []PathRule{
+	{Regex: regexp.MustCompile(`^bazel-out/[^/]+/(?:bin|genfiles)/`)},
+	{Regex: regexp.MustCompile(`^bazel-(?:bin|genfiles)/`)},
+	{Regex: regexp.MustCompile(`^/.*/execroot/[^/]+/bazel-out/[^/]+/(?:bin|genfiles)/`)},
+	{Regex: regexp.MustCompile(`^(/.*/)bazel-out/[^/]+/(?:bin|genfiles)/`), Replace: "$1"},
+}
+
+// pathRules returns the generated-path-rules followed by the bazel rules.
+func (v *view) pathRules() []PathRule {
+	return append(append([]PathRule{}, v.Settings().GeneratedPathRules...), bazelPathRules...)
+}
+
+// rewrittenProtoNames applies the path rules to proto_name, and to its path
+// relative to the workspace root it is under. Relative results are joined
+// with the workspace roots.
+func (v *view) rewrittenProtoNames(proto_name string) (res []string) {
+	v.workspace.mu.RLock()
+	roots := v.workspace.roots
+	v.workspace.mu.RUnlock()
+
+	names := []string{proto_name}
+	for _, root := range roots {
+		if rel, err := filepath.Rel(root, proto_name); err == nil && isSubDir(root, proto_name) {
+			names = append(names, filepath.ToSlash(rel))
+		}
+	}
+	for _, rule := range v.pathRules() {
+		for _, name := range names {
+			rewritten, ok := rule.Rewrite(name)
+			if !ok {
+				continue
+			}
+			if filepath.IsAbs(rewritten) {
+				res = append(res, rewritten)
+				continue
+			}
+			for _, root := range roots {
+				res = append(res, filepath.Join(root, rewritten))
+			}
+		}
+	}
+	return res
+}
+
+// outputRoot is a directory generated code is written to, with the proto
+// root its files are generated from.
+type outputRoot struct {
+	outputRoot string
+	protoRoot  string
+}
+
+// outputRoots returns the generated-output-roots of the workspace roots
+// which filename is under, the deepest output root first.
+func (v *view) outputRoots(filename string) (res []outputRoot) {
+	v.workspace.mu.RLock()
+	roots := v.workspace.roots
+	v.workspace.mu.RUnlock()
+
+	output_roots := v.Settings().GeneratedOutputRoots
+	for _, root := range roots {
+		for output, proto := range output_roots {
+			output_root := output
+			if !filepath.IsAbs(output_root) {
+				output_root = filepath.Join(root, output_root)
+			}
+			if !isSubDir(output_root, filename) {
+				continue
+			}
+			proto_root := proto
+			if !filepath.IsAbs(proto_root) {
+				proto_root = filepath.Join(root, proto_root)
+			}
+			res = append(res, outputRoot{outputRoot: filepath.Clean(output_root), protoRoot: filepath.Clean(proto_root)})
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return len(res[i].outputRoot) > len(res[j].outputRoot)
+	})
+	return res
+}
@@ -0,0 +1,136 @@
+package view
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/lasorda/protobuf-language-server/go-lsp/logs"
+	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_view_GeneratedProtoFile(t *testing.T) {
+	tests := []struct {
+		name          string
+		existingFiles []string
+		settings      Settings
+		document_uri  defines.DocumentUri
+		text          string
+		want          defines.DocumentUri
+		wantErr       error
+	}{
+		{
+			name:          "source comment is resolved from the import roots",
+			existingFiles: []string{"/project-dir/api/user.proto"},
+			document_uri:  "file:///project-dir/gen/go/example.com/api/user.pb.go",
+			text:          "// Code generated by protoc-gen-go. DO NOT EDIT.\n// source: api/user.proto\n\npackage api\n",
+			want:          "file:///project-dir/api/user.proto",
+		},
+		{
+			name:          "source comment is resolved in the proto root of the output root",
+			existingFiles: []string{"/project-dir/proto/api/user.proto"},
+			settings:      Settings{GeneratedOutputRoots: map[string]string{"gen/python": "proto"}},
+			document_uri:  "file:///project-dir/gen/python/api/user_pb2_grpc.py",
+			text:          "# source: api/user.proto\n",
+			want:          "file:///project-dir/proto/api/user.proto",
+		},
+		{
+			name:          "path under the output root is mapped to the proto root",
+			existingFiles: []string{"/project-dir/proto/api/user.proto"},
+			settings:      Settings{GeneratedOutputRoots: map[string]string{"build/gen": "proto", "build": "."}},
+			document_uri:  "file:///project-dir/build/gen/api/user.pb.h",
+			text:          "#include <string>\n",
+			want:          "file:///project-dir/proto/api/user.proto",
+		},
+		{
+			name:          "bazel output root is detected",
+			existingFiles: []string{"/project-dir/api/user.proto"},
+			document_uri:  "file:///project-dir/bazel-out/k8-opt/bin/api/user.pb.h",
+			want:          "file:///project-dir/api/user.proto",
+		},
+		{
+			name:          "bazel execroot is mapped to the workspace",
+			existingFiles: []string{"/project-dir/api/user.proto"},
+			document_uri:  "file:///home/me/.cache/bazel/_bazel_me/0123/execroot/project/bazel-out/k8-fastbuild/bin/api/user.pb.cc",
+			want:          "file:///project-dir/api/user.proto",
+		},
+		{
+			name:          "bazel genfiles outside the workspace",
+			existingFiles: []string{"/other-dir/api/user.proto"},
+			document_uri:  "file:///other-dir/bazel-out/local_linux-fastbuild/genfiles/api/user.pb.h",
+			want:          "file:///other-dir/api/user.proto",
+		},
+		{
+			name:          "prefix rule",
+			existingFiles: []string{"/project-dir/proto/api/user.proto"},
+			settings:      Settings{GeneratedPathRules: []PathRule{{Prefix: "build/gen/", Replace: "proto/"}}},
+			document_uri:  "file:///project-dir/build/gen/api/user.pb.h",
+			want:          "file:///project-dir/proto/api/user.proto",
+		},
+		{
+			name:          "regex rule",
+			existingFiles: []string{"/project-dir/api/user.proto"},
+			settings:      Settings{GeneratedPathRules: []PathRule{{Regex: regexp.MustCompile(`^out/[^/]+/`)}}},
+			document_uri:  "file:///project-dir/out/release/api/user.pb.cc",
+			want:          "file:///project-dir/api/user.proto",
+		},
+		{
+			name:          "proto next to the generated file",
+			existingFiles: []string{"/project-dir/api/user.proto"},
+			document_uri:  "file:///project-dir/api/user.grpc.pb.cc",
+			want:          "file:///project-dir/api/user.proto",
+		},
+		{
+			name:         "no proto file",
+			document_uri: "file:///project-dir/api/user.pb.cc",
+			wantErr:      ErrNotFound,
+		},
+	}
+	// unresolved files are logged
+	logs.Init(nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := newView()
+			v.fs = &MockFS{ExistingFiles: tt.existingFiles}
+			v.settings = tt.settings
+			v.setWorkspaceRoots([]string{"/project-dir"})
+			v.didOpenGenerated(tt.document_uri, tt.text)
+
+			got, err := v.GeneratedProtoFile(tt.document_uri)
+			require.ErrorIs(t, err, tt.wantErr)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_IsGeneratedFile(t *testing.T) {
+	require.True(t, IsGeneratedFile("file:///a/user.pb.go"))
+	require.True(t, IsGeneratedFile("file:///a/user_grpc.pb.go"))
+	require.True(t, IsGeneratedFile("file:///a/user_pb2.py"))
+	require.True(t, IsGeneratedFile("file:///a/user.pb.h"))
+	require.False(t, IsGeneratedFile("file:///a/user.go"))
+	require.False(t, IsGeneratedFile("file:///a/user.proto"))
+}
+
+func Test_PathRulesFromInterface(t *testing.T) {
+	rules, err := PathRulesFromInterface([]interface{}{
+		map[string]interface{}{"prefix": "build/gen/", "replace": "proto/"},
+		map[string]interface{}{"regex": "^out/[^/]+/"},
+	})
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+
+	got, ok := rules[0].Rewrite("build/gen/api/user.proto")
+	require.True(t, ok)
+	require.Equal(t, "proto/api/user.proto", got)
+	_, ok = rules[0].Rewrite("api/user.proto")
+	require.False(t, ok)
+	got, ok = rules[1].Rewrite("out/debug/api/user.proto")
+	require.True(t, ok)
+	require.Equal(t, "api/user.proto", got)
+
+	_, err = PathRulesFromInterface([]interface{}{map[string]interface{}{"replace": "proto/"}})
+	require.Error(t, err)
+	_, err = PathRulesFromInterface([]interface{}{map[string]interface{}{"regex": "("}})
+	require.Error(t, err)
+}
@@ -0,0 +1,168 @@
+package view
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
+)
+
+// importGraph keeps the import edges of every parsed file in both
+// directions, importer -> imported and imported -> importers.
+type importGraph struct {
+	imports   map[defines.DocumentUri][]defines.DocumentUri
+	importers map[defines.DocumentUri]map[defines.DocumentUri]bool
+	// the import paths the edges of a file were resolved from
+	paths map[defines.DocumentUri][]string
+	mu    *sync.RWMutex
+}
+
+func newImportGraph() *importGraph {
+	return &importGraph{
+		imports:   make(map[defines.DocumentUri][]defines.DocumentUri),
+		importers: make(map[defines.DocumentUri]map[defines.DocumentUri]bool),
+		paths:     make(map[defines.DocumentUri][]string),
+		mu:        &sync.RWMutex{},
+	}
+}
+
+// set replaces the imports of importer, resolved from paths.
+func (g *importGraph) set(importer defines.DocumentUri, paths []string, imported []defines.DocumentUri) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.removeLocked(importer)
+	g.imports[importer] = imported
+	g.paths[importer] = paths
+	for _, document_uri := range imported {
+		if g.importers[document_uri] == nil {
+			g.importers[document_uri] = make(map[defines.DocumentUri]bool)
+		}
+		g.importers[document_uri][importer] = true
+	}
+}
+
+// remove forgets the imports of importer, files importing it keep their edges.
+func (g *importGraph) remove(importer defines.DocumentUri) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.removeLocked(importer)
+}
+
+func (g *importGraph) removeLocked(importer defines.DocumentUri) {
+	for _, document_uri := range g.imports[importer] {
+		delete(g.importers[document_uri], importer)
+		if len(g.importers[document_uri]) == 0 {
+			delete(g.importers, document_uri)
+		}
+	}
+	delete(g.imports, importer)
+	delete(g.paths, importer)
+}
+
+// resolved reports whether the imports of importer were resolved from paths.
+func (g *importGraph) resolved(importer defines.DocumentUri, paths []string) bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	previous, ok := g.paths[importer]
+	if !ok || len(previous) != len(paths) {
+		return false
+	}
+	for i := range paths {
+		if previous[i] != paths[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// updateImports records the imports of proto_file which can be resolved,
+// unless its import paths are the ones they were last resolved from.
+func (v *view) updateImports(proto_file ProtoFile) {
+	if proto_file.Proto() == nil {
+		return
+	}
+	paths := importPaths(proto_file)
+	if v.imports.resolved(proto_file.URI(), paths) {
+		return
+	}
+	v.resolveImports(proto_file.URI(), paths)
+}
+
+func (v *view) resolveImports(document_uri defines.DocumentUri, paths []string) {
+	var imported []defines.DocumentUri
+	for _, import_name := range paths {
+		import_uri, err := v.GetDocumentUriFromImportPath(document_uri, import_name)
+		if err != nil {
+			continue
+		}
+		imported = append(imported, import_uri)
+	}
+	v.imports.set(document_uri, paths, imported)
+}
+
+func importPaths(proto_file ProtoFile) []string {
+	paths := []string{}
+	for _, i := range proto_file.Proto().Imports() {
+		paths = append(paths, i.ProtoImport.Filename)
+	}
+	return paths
+}
+
+// refreshImports resolves the imports of every loaded file again, a created
+// or deleted file changes what an import path resolves to.
+func (v *view) refreshImports() {
+	v.fileMu.RLock()
+	files := make([]ProtoFile, 0, len(v.filesByURI))
+	for _, f := range v.filesByURI {
+		files = append(files, f)
+	}
+	v.fileMu.RUnlock()
+
+	for _, f := range files {
+		if f.Proto() != nil {
+			v.resolveImports(f.URI(), importPaths(f))
+		}
+	}
+}
+
+// Importers returns the files importing document_uri directly, sorted by uri.
+func (v *view) Importers(document_uri defines.DocumentUri) []defines.DocumentUri {
+	v.imports.mu.RLock()
+	defer v.imports.mu.RUnlock()
+
+	return sortedUris(v.imports.importers[document_uri])
+}
+
+// TransitiveImporters returns the files importing document_uri directly or
+// through other files, sorted by uri.
+func (v *view) TransitiveImporters(document_uri defines.DocumentUri) []defines.DocumentUri {
+	v.imports.mu.RLock()
+	defer v.imports.mu.RUnlock()
+
+	found := make(map[defines.DocumentUri]bool)
+	queue := []defines.DocumentUri{document_uri}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for importer := range v.imports.importers[current] {
+			if found[importer] || importer == document_uri {
+				continue
+			}
+			found[importer] = true
+			queue = append(queue, importer)
+		}
+	}
+	return sortedUris(found)
+}
+
+func sortedUris(set map[defines.DocumentUri]bool) []defines.DocumentUri {
+	res := make([]defines.DocumentUri, 0, len(set))
+	for document_uri := range set {
+		res = append(res, document_uri)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i] < res[j] })
+	return res
+}
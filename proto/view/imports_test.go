@@ -0,0 +1,100 @@
+package view
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
+	"github.com/lasorda/protobuf-language-server/proto/parser"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_view_TransitiveImporters(t *testing.T) {
+	files := map[string]string{
+		"/project/common/types.proto": `syntax = "proto3";`,
+		"/project/user/user.proto":    `syntax = "proto3"; import "common/types.proto";`,
+		"/project/api/api.proto":      `syntax = "proto3"; import "user/user.proto";`,
+		"/project/other/other.proto":  `syntax = "proto3"; import "google/protobuf/empty.proto";`,
+	}
+	var existingFiles []string
+	for filename := range files {
+		existingFiles = append(existingFiles, filename)
+	}
+	v := newView()
+	v.fs = &MockFS{ExistingFiles: existingFiles}
+
+	update := func(filename, content string) {
+		document_uri := defines.DocumentUri("file://" + filename)
+		proto, err := parser.ParseProto(document_uri, bytes.NewBufferString(content))
+		require.NoError(t, err)
+		pf := &protoFile{File: &file{document_uri: document_uri, data: []byte(content)}, proto: proto}
+		v.filesByURI[document_uri] = pf
+		v.updateImports(pf)
+	}
+	for filename, content := range files {
+		update(filename, content)
+	}
+
+	types := defines.DocumentUri("file:///project/common/types.proto")
+	user := defines.DocumentUri("file:///project/user/user.proto")
+	api := defines.DocumentUri("file:///project/api/api.proto")
+
+	require.Equal(t, []defines.DocumentUri{user}, v.Importers(types))
+	require.Equal(t, []defines.DocumentUri{api, user}, v.TransitiveImporters(types))
+	require.Equal(t, []defines.DocumentUri{api}, v.TransitiveImporters(user))
+	require.Empty(t, v.TransitiveImporters(api))
+
+	// user.proto no longer imports types.proto
+	update("/project/user/user.proto", `syntax = "proto3";`)
+	require.Empty(t, v.TransitiveImporters(types))
+	require.Equal(t, []defines.DocumentUri{api}, v.TransitiveImporters(user))
+
+	// unchanged import paths are not resolved again, until files are created or deleted
+	v.fs = &MockFS{}
+	update("/project/api/api.proto", `syntax = "proto3";  import "user/user.proto";`)
+	require.Equal(t, []defines.DocumentUri{api}, v.TransitiveImporters(user))
+	v.refreshImports()
+	require.Empty(t, v.TransitiveImporters(user))
+
+	v.fs = &MockFS{ExistingFiles: existingFiles}
+	v.refreshImports()
+	v.imports.remove(api)
+	require.Empty(t, v.TransitiveImporters(user))
+}
+
+func Test_view_closeRestoresImports(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.proto")
+	b := filepath.Join(dir, "b.proto")
+	require.NoError(t, os.WriteFile(a, []byte(`syntax = "proto3"; import "b.proto";`), 0o644))
+	require.NoError(t, os.WriteFile(b, []byte(`syntax = "proto3";`), 0o644))
+	v := newView()
+	v.fs = &MockFS{ExistingFiles: []string{a, b}}
+
+	a_uri := defines.DocumentUri("file://" + a)
+	b_uri := defines.DocumentUri("file://" + b)
+	edit := func(document_uri defines.DocumentUri, content string) {
+		proto, err := parser.ParseProto(document_uri, bytes.NewBufferString(content))
+		require.NoError(t, err)
+		v.updateImports(&protoFile{File: &file{document_uri: document_uri, data: []byte(content)}, proto: proto})
+	}
+
+	require.NoError(t, v.readProtoFile(a_uri))
+	require.Equal(t, []defines.DocumentUri{a_uri}, v.TransitiveImporters(b_uri))
+
+	// the unsaved text drops the import, closing it brings back the one on disk
+	edit(a_uri, `syntax = "proto3";`)
+	require.Empty(t, v.TransitiveImporters(b_uri))
+	v.setContent(context.Background(), a_uri, nil)
+	require.Equal(t, []defines.DocumentUri{a_uri}, v.TransitiveImporters(b_uri))
+
+	// a closed file missing on disk imports nothing
+	c_uri := defines.DocumentUri("file://" + filepath.Join(dir, "c.proto"))
+	edit(c_uri, `syntax = "proto3"; import "b.proto";`)
+	require.Equal(t, []defines.DocumentUri{a_uri, c_uri}, v.TransitiveImporters(b_uri))
+	v.setContent(context.Background(), c_uri, nil)
+	require.Equal(t, []defines.DocumentUri{a_uri}, v.TransitiveImporters(b_uri))
+}
@@ -1,5 +1,13 @@
 package view
 
+import (
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
 type MockFS struct {
 	ExistingFiles []string
 }
@@ -8,11 +16,39 @@ func (m *MockFS) FileExists(path string) bool {
 	return contains(m.ExistingFiles, path)
 }
 
-func contains(items []string, x string) bool {
-	for _, item := range items {
-		if item == x {
-			return true
+// ReadDir lists the files and directories derived from ExistingFiles.
+func (m *MockFS) ReadDir(dir string) ([]os.DirEntry, error) {
+	seen := make(map[string]bool)
+	var res []os.DirEntry
+	for _, f := range m.ExistingFiles {
+		if !strings.HasPrefix(f, dir+"/") {
+			continue
+		}
+		rest := f[len(dir)+1:]
+		name, _, isDir := strings.Cut(rest, "/")
+		if !seen[name] {
+			seen[name] = true
+			res = append(res, mockDirEntry{name, isDir})
 		}
 	}
-	return false
+	if len(res) == 0 {
+		return nil, fs.ErrNotExist
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name() < res[j].Name() })
+	return res, nil
+}
+
+type mockDirEntry struct {
+	name  string
+	isDir bool
+}
+
+func (e mockDirEntry) Name() string { return path.Base(e.name) }
+func (e mockDirEntry) IsDir() bool  { return e.isDir }
+func (e mockDirEntry) Type() fs.FileMode {
+	if e.isDir {
+		return fs.ModeDir
+	}
+	return 0
 }
+func (e mockDirEntry) Info() (fs.FileInfo, error) { return nil, fs.ErrInvalid }
@@ -0,0 +1,108 @@
+package view
+
+import (
+	"math"
+
+	protobuf "github.com/emicklei/proto"
+	"github.com/lasorda/protobuf-language-server/proto/parser"
+)
+
+// NextFieldNumber returns the number following the highest field number of
+// m, skipping reserved ranges, extension ranges and the numbers reserved for
+// the implementation. Gaps below the highest number are only used once the
+// numbers above it are exhausted. It returns 0 when no number is left.
+func NextFieldNumber(m parser.Message) int {
+	used := make(map[int]bool)
+	highest := 0
+	for _, f := range messageNumberedFields(m) {
+		used[f.number] = true
+		if f.number > highest {
+			highest = f.number
+		}
+	}
+	blocked := []protobuf.Range{{From: firstReservedFieldNumber, To: lastReservedFieldNumber}}
+	for _, e := range m.Protobuf().Elements {
+		switch v := e.(type) {
+		case *protobuf.Reserved:
+			blocked = append(blocked, v.Ranges...)
+		case *protobuf.Extensions:
+			blocked = append(blocked, v.Ranges...)
+		}
+	}
+
+	if n, ok := nextFreeNumber(highest+1, maxFieldNumber, used, blocked, maxFieldNumber); ok {
+		return n
+	}
+	if n, ok := nextFreeNumber(minFieldNumber, highest, used, blocked, maxFieldNumber); ok {
+		return n
+	}
+	return 0
+}
+
+// NextEnumNumber returns the number following the highest value of e which
+// is not reserved, 0 for an enum without values.
+func NextEnumNumber(e parser.Enum) int {
+	used := make(map[int]bool)
+	highest := math.MinInt32
+	var blocked []protobuf.Range
+	for _, element := range e.Protobuf().Elements {
+		switch v := element.(type) {
+		case *protobuf.EnumField:
+			used[v.Integer] = true
+			if v.Integer > highest {
+				highest = v.Integer
+			}
+		case *protobuf.Reserved:
+			blocked = append(blocked, v.Ranges...)
+		}
+	}
+	if len(used) == 0 {
+		highest = -1
+	}
+
+	if n, ok := nextFreeNumber(highest+1, math.MaxInt32, used, blocked, math.MaxInt32); ok {
+		return n
+	}
+	n, _ := nextFreeNumber(0, highest, used, blocked, math.MaxInt32)
+	return n
+}
+
+// EnumAllowsAlias reports whether e sets allow_alias, values may then share
+// a number.
+func EnumAllowsAlias(e parser.Enum) bool {
+	for _, element := range e.Protobuf().Elements {
+		if o, ok := element.(*protobuf.Option); ok && o.Name == "allow_alias" {
+			return o.Constant.Source == "true"
+		}
+	}
+	return false
+}
+
+// nextFreeNumber returns the first number of from..to which is neither used
+// nor inside a blocked range, max is the upper bound of a range to max.
+func nextFreeNumber(from, to int, used map[int]bool, blocked []protobuf.Range, max int) (int, bool) {
+	for n := from; n <= to; {
+		if used[n] {
+			n++
+			continue
+		}
+		skipped := false
+		for _, r := range blocked {
+			end := r.To
+			if r.Max {
+				end = max
+			}
+			if n >= r.From && n <= end {
+				if end >= to {
+					return 0, false
+				}
+				n, skipped = end+1, true
+				break
+			}
+		}
+		if !skipped {
+			return n, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,52 @@
+package view
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNextFieldNumber(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    int
+	}{
+		{"empty", "message M {}", 1},
+		{"after highest", "message M {\n  int32 a = 1;\n  int32 b = 5;\n}", 6},
+		{"oneof and map", "message M {\n  map<string, int32> a = 1;\n  oneof o {\n    int32 b = 2;\n  }\n}", 3},
+		{"reserved", "message M {\n  reserved 2, 3 to 5;\n  int32 a = 1;\n}", 6},
+		{"extensions", "syntax = \"proto2\";\nmessage M {\n  optional int32 a = 1;\n  extensions 2 to 10;\n}", 11},
+		{"implementation range", "message M {\n  int32 a = 18999;\n}", 20000},
+		{"gap when the top is taken", "message M {\n  int32 a = 1;\n  int32 b = 3;\n  reserved 4 to max;\n}", 2},
+		{"nothing left", "message M {\n  int32 a = 1;\n  reserved 2 to max;\n}", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := newTestProtoFile(t, tt.content)
+			require.Equal(t, tt.want, NextFieldNumber(file.Proto().Messages()[0]))
+		})
+	}
+}
+
+func TestNextEnumNumber(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		want      int
+		wantAlias bool
+	}{
+		{"empty", "enum E {}", 0, false},
+		{"after highest", "enum E {\n  A = 0;\n  B = 3;\n}", 4, false},
+		{"negative", "enum E {\n  A = -5;\n}", -4, false},
+		{"reserved", "enum E {\n  A = 0;\n  reserved 1 to 3;\n}", 4, false},
+		{"aliases", "enum E {\n  option allow_alias = true;\n  A = 0;\n  B = 1;\n  C = 1;\n}", 2, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enum := newTestProtoFile(t, tt.content).Proto().Enums()[0]
+			require.Equal(t, tt.want, NextEnumNumber(enum))
+			require.Equal(t, tt.wantAlias, EnumAllowsAlias(enum))
+		})
+	}
+}
@@ -3,14 +3,67 @@ package view
 import (
 	"errors"
 	"fmt"
+	"regexp"
+	"strings"
 )
 
 const (
-	additionalProtoDirsKey = "additional-proto-dirs"
+	additionalProtoDirsKey  = "additional-proto-dirs"
+	formatterKey            = "formatter"
+	generatedCodeHoverKey   = "generated-code-hover"
+	generatedOutputRootsKey = "generated-output-roots"
+	generatedPathRulesKey   = "generated-path-rules"
 )
 
+// formatters selectable with the formatter setting
+const (
+	FormatterBuiltin     = "builtin"
+	FormatterClangFormat = "clang-format"
+)
+
+// languages whose generated names the generated-code-hover setting may list
+var GeneratedCodeLanguages = []string{"go", "cpp", "java", "python", "json"}
+
 type Settings struct {
 	AdditionalProtoDirs []string
+	Formatter           string
+	// languages whose generated names are shown on hover, none by default
+	GeneratedCodeHover []string
+	// the directories generated code is written to, mapped to the proto root
+	// it is generated from, both relative to the workspace root or absolute
+	GeneratedOutputRoots map[string]string
+	// rewrite the paths of generated files to the paths of proto files,
+	// tried before the builtin bazel rules
+	GeneratedPathRules []PathRule
+}
+
+// PathRule rewrites a path relative to the workspace root, the prefix or
+// the matches of the regex are replaced with Replace.
+type PathRule struct {
+	Prefix  string
+	Regex   *regexp.Regexp
+	Replace string
+}
+
+// Rewrite returns the rewritten name, false when the rule does not apply.
+func (r PathRule) Rewrite(name string) (string, bool) {
+	if r.Regex != nil {
+		if !r.Regex.MatchString(name) {
+			return "", false
+		}
+		return r.Regex.ReplaceAllString(name, r.Replace), true
+	}
+	if !strings.HasPrefix(name, r.Prefix) {
+		return "", false
+	}
+	return r.Replace + strings.TrimPrefix(name, r.Prefix), true
+}
+
+func (r PathRule) String() string {
+	if r.Regex != nil {
+		return fmt.Sprintf("%s -> %q", r.Regex, r.Replace)
+	}
+	return fmt.Sprintf("%s* -> %q", r.Prefix, r.Replace)
 }
 
 var (
@@ -33,6 +86,43 @@ func SettingsFromInterface(in interface{}) (*Settings, error) {
 		settings.AdditionalProtoDirs = protoDirs
 	}
 
+	if value, ok := settingsMap[formatterKey]; ok {
+		formatter, ok := value.(string)
+		if !ok || formatter != FormatterBuiltin && formatter != FormatterClangFormat {
+			return nil, fmt.Errorf("%w: formatter should be %q or %q: key = %s", ErrRepackingSettings, FormatterBuiltin, FormatterClangFormat, formatterKey)
+		}
+		settings.Formatter = formatter
+	}
+
+	if value, ok := settingsMap[generatedCodeHoverKey]; ok {
+		languages, err := StringsSliceFromInterface(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: key = %s", ErrRepackingSettings, err.Error(), generatedCodeHoverKey)
+		}
+		for _, language := range languages {
+			if !contains(GeneratedCodeLanguages, language) {
+				return nil, fmt.Errorf("%w: unknown language %q, expected one of %q: key = %s", ErrRepackingSettings, language, GeneratedCodeLanguages, generatedCodeHoverKey)
+			}
+		}
+		settings.GeneratedCodeHover = languages
+	}
+
+	if value, ok := settingsMap[generatedOutputRootsKey]; ok {
+		roots, err := StringsMapFromInterface(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: key = %s", ErrRepackingSettings, err.Error(), generatedOutputRootsKey)
+		}
+		settings.GeneratedOutputRoots = roots
+	}
+
+	if value, ok := settingsMap[generatedPathRulesKey]; ok {
+		rules, err := PathRulesFromInterface(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: key = %s", ErrRepackingSettings, err.Error(), generatedPathRulesKey)
+		}
+		settings.GeneratedPathRules = rules
+	}
+
 	return &settings, nil
 }
 
@@ -54,3 +144,78 @@ func StringsSliceFromInterface(in interface{}) ([]string, error) {
 
 	return result, nil
 }
+
+func StringsMapFromInterface(in interface{}) (map[string]string, error) {
+	interfaceMap, ok := in.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("field should have a map[string]interface{} type")
+	}
+
+	result := make(map[string]string, len(interfaceMap))
+	for key, value := range interfaceMap {
+		str, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("value of %s should have a string type", key)
+		}
+		result[key] = str
+	}
+
+	return result, nil
+}
+
+// PathRulesFromInterface reads a list of rules like {"prefix": "build/gen/",
+// "replace": "proto/"} or {"regex": "^out/[^/]+/", "replace": ""}.
+func PathRulesFromInterface(in interface{}) ([]PathRule, error) {
+	interfaceSlice, ok := in.([]interface{})
+	if !ok {
+		return nil, errors.New("field should have a []interface{} type")
+	}
+
+	var result []PathRule
+
+	for i, item := range interfaceSlice {
+		fields, err := StringsMapFromInterface(item)
+		if err != nil {
+			return nil, fmt.Errorf("item [%d]: %s", i, err.Error())
+		}
+		rule := PathRule{Replace: fields["replace"]}
+		prefix, hasPrefix := fields["prefix"]
+		expr, hasRegex := fields["regex"]
+		switch {
+		case hasPrefix == hasRegex:
+			return nil, fmt.Errorf("item [%d] should have either a prefix or a regex", i)
+		case hasPrefix:
+			rule.Prefix = prefix
+		default:
+			if rule.Regex, err = regexp.Compile(expr); err != nil {
+				return nil, fmt.Errorf("item [%d]: %s", i, err.Error())
+			}
+		}
+		result = append(result, rule)
+	}
+
+	return result, nil
+}
+
+// Settings returns the settings last sent by the client.
+func (v *view) Settings() Settings {
+	v.settingsMu.RLock()
+	defer v.settingsMu.RUnlock()
+
+	return v.settings
+}
+
+func (v *view) setSettings(settings Settings) {
+	v.settingsMu.Lock()
+	v.settings = settings
+	v.settingsMu.Unlock()
+}
+
+func contains(items []string, x string) bool {
+	for _, item := range items {
+		if item == x {
+			return true
+		}
+	}
+	return false
+}
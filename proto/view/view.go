@@ -1,7 +1,6 @@
 package view
 
 import (
-	"bytes"
 	"context"
 	"crypto/sha1"
 	"errors"
@@ -9,8 +8,6 @@ import (
 	"os"
 	"path"
 	"path/filepath"
-	"regexp"
-	"strconv"
 	"strings"
 	"sync"
 	"unicode/utf8"
@@ -36,16 +33,28 @@ type view struct {
 	openFiles  map[defines.DocumentUri]bool
 	openFileMu *sync.RWMutex
 
-	pbHeaders map[defines.DocumentUri][]string
-	Server    *lsp.Server
-	settings  Settings
-	fs        fs.FS
+	workspace *workspace
+	imports   *importGraph
+
+	// the lines of open generated files, like user.pb.go
+	generatedFiles map[defines.DocumentUri][]string
+	generatedMu    *sync.RWMutex
+
+	Server *lsp.Server
+	fs     fs.FS
+
+	// guards settings and snippetSupport, requests read them while the
+	// client changes them
+	settingsMu sync.RWMutex
+	settings   Settings
+	// the client accepts completion items in snippet format
+	snippetSupport bool
 }
 
 var ErrNotFound = errors.New("not found")
 
 func (v *view) GetFile(document_uri defines.DocumentUri) (ProtoFile, error) {
-	if f, ok := v.filesByURI[document_uri]; ok {
+	if f, ok := v.getLoadedFile(document_uri); ok {
 		return f, nil
 	}
 	// no file load try again
@@ -53,13 +62,36 @@ func (v *view) GetFile(document_uri defines.DocumentUri) (ProtoFile, error) {
 	if err != nil {
 		return nil, err
 	}
-	if f, ok := v.filesByURI[document_uri]; ok {
+	if f, ok := v.getLoadedFile(document_uri); ok {
 		return f, nil
 	}
 
 	return nil, fmt.Errorf("%v not found", document_uri)
 }
 
+// PeekFile returns document_uri like GetFile, a file which is not loaded yet
+// is parsed without publishing its diagnostics.
+func (v *view) PeekFile(document_uri defines.DocumentUri) (ProtoFile, error) {
+	if f, ok := v.getLoadedFile(document_uri); ok {
+		return f, nil
+	}
+	if err := v.readProtoFile(document_uri); err != nil {
+		return nil, err
+	}
+	if f, ok := v.getLoadedFile(document_uri); ok {
+		return f, nil
+	}
+	return nil, fmt.Errorf("%v not found", document_uri)
+}
+
+func (v *view) getLoadedFile(document_uri defines.DocumentUri) (ProtoFile, bool) {
+	v.fileMu.RLock()
+	defer v.fileMu.RUnlock()
+
+	f, ok := v.filesByURI[document_uri]
+	return f, ok
+}
+
 type Diagnositcs struct {
 	Method string                           `json:"method"`
 	Params defines.PublishDiagnosticsParams `json:"params"`
@@ -67,11 +99,14 @@ type Diagnositcs struct {
 
 // setContent sets the file contents for a file.
 func (v *view) setContent(ctx context.Context, document_uri defines.DocumentUri, data []byte) {
-
-	v.fileMu.Lock()
-	defer v.fileMu.Unlock()
 	if data == nil {
-		delete(v.filesByURI, document_uri)
+		// the file on disk replaces the discarded text, its imports included
+		if err := v.readProtoFile(document_uri); err != nil {
+			v.fileMu.Lock()
+			delete(v.filesByURI, document_uri)
+			v.fileMu.Unlock()
+			v.imports.remove(document_uri)
+		}
 		return
 	}
 
@@ -82,20 +117,20 @@ func (v *view) setContent(ctx context.Context, document_uri defines.DocumentUri,
 			hash:         hashContent(data),
 		},
 	}
-	if pre, ok := v.filesByURI[document_uri]; ok {
-		pf.proto = pre.Proto()
-	}
-	v.filesByURI[document_uri] = pf
 	// TODO:
 	//  Control times of parse of proto.
 	//  Currently it parses every time of file change.
-	proto, err := parseProto(document_uri, data)
-
-	defer v.sendDiagnose(document_uri, err)
-	if err != nil {
-		return
-	}
+	// a partial proto of the current text beats the stale one of the previous version
+	proto, errs := parseProto(document_uri, data)
 	pf.proto = proto
+
+	v.fileMu.Lock()
+	v.filesByURI[document_uri] = pf
+	v.fileMu.Unlock()
+	v.updateImports(pf)
+
+	// diagnose without holding the lock, semantic checks may load imports
+	v.sendDiagnose(pf, errs)
 }
 
 func (v *view) shutdown(ctx context.Context) error {
@@ -112,24 +147,17 @@ func (v *view) didOpen(document_uri defines.DocumentUri, text []byte) {
 	v.parseImportProto(document_uri)
 }
 
-func (v *view) didOpenPbHeader(document_uri defines.DocumentUri, text string) {
-	v.pbHeaders[document_uri] = strings.Split(text, "\n")
-}
-
-func (v *view) GetPbHeaderLine(document_uri defines.DocumentUri, line int) string {
-	lines, ok := v.pbHeaders[document_uri]
-	if !ok || len(lines) <= line {
-		return ""
-	}
-
-	return lines[line]
-}
 func (v *view) didSave(document_uri defines.DocumentUri) {
 	v.fileMu.Lock()
-	if file, ok := v.filesByURI[document_uri]; ok {
+	file, ok := v.filesByURI[document_uri]
+	if ok {
 		file.SetSaved(true)
 	}
 	v.fileMu.Unlock()
+
+	if ok {
+		v.updateImports(file)
+	}
 }
 
 func (v *view) didClose(document_uri defines.DocumentUri) {
@@ -149,62 +177,47 @@ func (v *view) isOpen(document_uri defines.DocumentUri) bool {
 	return open
 }
 
-func (v *view) sendDiagnose(document_uri defines.DocumentUri, err error) {
+func (v *view) sendDiagnose(proto_file ProtoFile, errs []*parser.ParseError) {
 	res := Diagnositcs{
 		Method: "textDocument/publishDiagnostics",
 		Params: defines.PublishDiagnosticsParams{
-			Uri:         document_uri,
+			Uri:         proto_file.URI(),
 			Diagnostics: []defines.Diagnostic{},
 		},
 	}
 	defer func() {
 		ViewManager.Server.SendMsg(res)
 	}()
-	if err == nil {
-		return
-	}
-	input := err.Error()
-	re := regexp.MustCompile(`<input>:(\d+):(\d+)`)
-	matches := re.FindStringSubmatch(input)
-
-	line, row := 0, 0
-	if len(matches) == 3 {
-		line, err = strconv.Atoi(matches[1])
-		if err != nil {
-			logs.Printf("Error:%v\n", err)
-			return
-		}
-		row, err = strconv.Atoi(matches[2])
-		if err != nil {
-			logs.Printf("Error:%v\n", err)
-			return
+	if len(errs) == 0 {
+		if v.isOpen(proto_file.URI()) {
+			res.Params.Diagnostics = append(res.Params.Diagnostics, semanticDiagnostics(proto_file)...)
 		}
-	}
-	if line == 0 || row == 0 {
 		return
 	}
-	logs.Println(row)
+	// semantic checks are skipped, the partial proto misses the broken declarations
 	severity := defines.DiagnosticSeverityError
-	res.Params.Diagnostics = append(res.Params.Diagnostics, defines.Diagnostic{
-		Message:  input,
-		Severity: &severity,
-		Range: defines.Range{
-			Start: defines.Position{
-				Line:      uint(line - 1),
-				Character: uint(row - 1),
-			},
-			End: defines.Position{
-				Line:      uint(line - 1),
-				Character: uint(row),
+	for _, err := range errs {
+		if err.Line == 0 || err.Column == 0 {
+			continue
+		}
+		res.Params.Diagnostics = append(res.Params.Diagnostics, defines.Diagnostic{
+			Message:  err.Message,
+			Severity: &severity,
+			Range: defines.Range{
+				Start: defines.Position{
+					Line:      uint(err.Line - 1),
+					Character: uint(err.Column - 1),
+				},
+				End: defines.Position{
+					Line:      uint(err.Line - 1),
+					Character: uint(err.Column),
+				},
 			},
-		},
-	})
+		})
+	}
 }
 
 func (v *view) openFile(document_uri defines.DocumentUri, data []byte) {
-	v.fileMu.Lock()
-	defer v.fileMu.Unlock()
-
 	pf := &protoFile{
 		File: &file{
 			document_uri: document_uri,
@@ -213,13 +226,14 @@ func (v *view) openFile(document_uri defines.DocumentUri, data []byte) {
 		},
 	}
 
-	proto, err := parseProto(document_uri, data)
-	defer v.sendDiagnose(document_uri, err)
-	if err != nil {
-		return
-	}
+	proto, errs := parseProto(document_uri, data)
 	pf.proto = proto
+	v.fileMu.Lock()
 	v.filesByURI[document_uri] = pf
+	v.fileMu.Unlock()
+	v.updateImports(pf)
+
+	v.sendDiagnose(pf, errs)
 }
 
 func (v *view) parseImportProto(document_uri defines.DocumentUri) {
@@ -242,16 +256,47 @@ func (v *view) parseImportProto(document_uri defines.DocumentUri) {
 }
 
 func (v *view) loadProtoFile(document_uri defines.DocumentUri) error {
+	data, err := readFile(document_uri)
+	if err != nil {
+		return err
+	}
+	v.openFile(document_uri, data)
+	return nil
+}
+
+// readProtoFile parses a file from disk like loadProtoFile, without
+// publishing diagnostics for it.
+func (v *view) readProtoFile(document_uri defines.DocumentUri) error {
+	data, err := readFile(document_uri)
+	if err != nil {
+		return err
+	}
+	proto, _ := parseProto(document_uri, data)
+	pf := &protoFile{
+		File: &file{
+			document_uri: document_uri,
+			data:         data,
+			hash:         hashContent(data),
+		},
+		proto: proto,
+	}
+	v.fileMu.Lock()
+	v.filesByURI[document_uri] = pf
+	v.fileMu.Unlock()
+	v.updateImports(pf)
+	return nil
+}
+
+func readFile(document_uri defines.DocumentUri) ([]byte, error) {
 	data, err := os.ReadFile(uri.URI(document_uri).Filename())
 
 	if err != nil {
-		return fmt.Errorf("read file err:%v", err)
+		return nil, fmt.Errorf("read file err:%v", err)
 	}
 	if !utf8.Valid(data) {
 		data = toUtf8(data)
 	}
-	v.openFile(document_uri, data)
-	return nil
+	return data, nil
 }
 
 func (v *view) mapFile(document_uri defines.DocumentUri, f ProtoFile) {
@@ -266,44 +311,183 @@ func (v *view) mapFile(document_uri defines.DocumentUri, f ProtoFile) {
 
 func newView() *view {
 	return &view{
-		filesByURI:  make(map[defines.DocumentUri]ProtoFile),
-		filesByBase: make(map[string][]ProtoFile),
-		fileMu:      &sync.RWMutex{},
-		openFiles:   make(map[defines.DocumentUri]bool),
-		openFileMu:  &sync.RWMutex{},
-		pbHeaders:   make(map[defines.DocumentUri][]string),
-		fs:          &fs.RealFS{},
+		filesByURI:     make(map[defines.DocumentUri]ProtoFile),
+		filesByBase:    make(map[string][]ProtoFile),
+		fileMu:         &sync.RWMutex{},
+		openFiles:      make(map[defines.DocumentUri]bool),
+		openFileMu:     &sync.RWMutex{},
+		workspace:      newWorkspace(),
+		imports:        newImportGraph(),
+		generatedFiles: make(map[defines.DocumentUri][]string),
+		generatedMu:    &sync.RWMutex{},
+		fs:             &fs.RealFS{},
 	}
 }
 
 var ViewManager *view
 
-func parseProto(document_uri defines.DocumentUri, data []byte) (proto parser.Proto, err error) {
-	buf := bytes.NewBuffer(data)
-	proto, err = parser.ParseProto(document_uri, buf)
-	if err != nil {
+func parseProto(document_uri defines.DocumentUri, data []byte) (proto parser.Proto, errs []*parser.ParseError) {
+	proto, errs = parser.ParseProtoWithRecovery(document_uri, data)
+	for _, err := range errs {
 		logs.Printf("parseProto err %v", err)
 	}
-	return proto, err
+	return proto, errs
 }
 
 func (v *view) GetDocumentUriFromImportPath(cwd defines.DocumentUri, import_name string) (defines.DocumentUri, error) {
+	document_uri, _, err := v.ResolveImport(cwd, import_name)
+	return document_uri, err
+}
+
+// ResolveImport returns the file import_name of cwd resolves to and the
+// import root it was found under.
+func (v *view) ResolveImport(cwd defines.DocumentUri, import_name string) (defines.DocumentUri, string, error) {
+	for _, root := range v.importRoots(cwd) {
+		abs_name := path.Join(root, import_name)
+		if v.fs.FileExists(abs_name) {
+			return defines.DocumentUri(uri.New(path.Clean(abs_name))), root, nil
+		}
+	}
+	return "", "", fmt.Errorf("%w: import %s", ErrNotFound, import_name)
+}
+
+// importRoots returns the directories imports of cwd are looked up in, in
+// order: each ancestor of cwd followed by the additional proto dirs under it.
+func (v *view) importRoots(cwd defines.DocumentUri) (roots []string) {
+	additional_proto_dirs := v.Settings().AdditionalProtoDirs
 	pos := path.Dir(uri.URI(cwd).Filename())
-	var res defines.DocumentUri
 	for path.Clean(pos) != "/" {
-		abs_name := path.Join(pos, import_name)
-		if v.fs.FileExists(abs_name) {
-			return defines.DocumentUri(uri.New(path.Clean(abs_name))), nil
+		roots = append(roots, path.Clean(pos))
+		for _, additionalProtoDir := range additional_proto_dirs {
+			roots = append(roots, path.Join(pos, additionalProtoDir))
 		}
-		for _, additionalProtoDir := range v.settings.AdditionalProtoDirs {
-			abs_name := path.Join(pos, additionalProtoDir, import_name)
-			if v.fs.FileExists(abs_name) {
-				return defines.DocumentUri(uri.New(path.Clean(abs_name))), nil
+		pos = path.Join(pos, "..")
+	}
+	return roots
+}
+
+// ImportPathEntry is a directory or a proto file which an import path
+// starting with a given directory can continue with.
+type ImportPathEntry struct {
+	Name  string
+	IsDir bool
+	// the file an import of the entry resolves to, empty for directories
+	DocumentUri defines.DocumentUri
+	// the import root the entry was found under
+	Root string
+}
+
+// ImportPathEntries lists the directories and proto files under dir, an
+// import path of directories without the trailing slash, in every import
+// root of cwd. A file found under several roots is listed once, with the
+// root an import of it resolves in. Ancestors above the workspace root of
+// cwd are only searched through their additional proto dirs, they hold
+// unrelated trees.
+func (v *view) ImportPathEntries(cwd defines.DocumentUri, dir string) (res []ImportPathEntry) {
+	outside := v.outsideWorkspaceRoot(cwd)
+	seen := make(map[string]bool)
+	for _, root := range v.importRoots(cwd) {
+		if outside[root] {
+			continue
+		}
+		entries, err := v.fs.ReadDir(path.Join(root, dir))
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if seen[name] {
+				continue
 			}
+			if entry.IsDir() {
+				if strings.HasPrefix(name, ".") || name == "node_modules" {
+					continue
+				}
+				seen[name] = true
+				res = append(res, ImportPathEntry{Name: name, IsDir: true, Root: root})
+				continue
+			}
+			if !strings.HasSuffix(name, ".proto") {
+				continue
+			}
+			seen[name] = true
+			res = append(res, ImportPathEntry{
+				Name:        name,
+				DocumentUri: defines.DocumentUri(uri.New(path.Join(root, dir, name))),
+				Root:        root,
+			})
+		}
+	}
+	return res
+}
+
+// outsideWorkspaceRoot returns the ancestors of cwd above the workspace root
+// which holds it, none when cwd is not in a workspace root.
+func (v *view) outsideWorkspaceRoot(cwd defines.DocumentUri) map[string]bool {
+	v.workspace.mu.RLock()
+	roots := v.workspace.roots
+	v.workspace.mu.RUnlock()
+
+	res := make(map[string]bool)
+	filename := uri.URI(cwd).Filename()
+	for _, root := range roots {
+		root = path.Clean(root)
+		if !isSubDir(root, filename) {
+			continue
+		}
+		for pos := path.Dir(root); pos != "/" && pos != "."; pos = path.Dir(pos) {
+			res[pos] = true
+		}
+		break
+	}
+	return res
+}
+
+// ImportPath returns the path which imports document_uri from cwd, checked
+// with GetDocumentUriFromImportPath. Paths under an additional proto dir are
+// preferred, then paths relative to a workspace root, then the shortest.
+func (v *view) ImportPath(cwd defines.DocumentUri, document_uri defines.DocumentUri) (string, error) {
+	filename := path.Clean(uri.URI(document_uri).Filename())
+
+	v.workspace.mu.RLock()
+	roots := make(map[string]bool)
+	for _, root := range v.workspace.roots {
+		roots[path.Clean(root)] = true
+	}
+	v.workspace.mu.RUnlock()
+
+	additional_proto_dirs := v.Settings().AdditionalProtoDirs
+	best, bestRank := "", -1
+	consider := func(dir string, rank int) {
+		if !strings.HasPrefix(filename, dir+"/") {
+			return
+		}
+		import_name := filename[len(dir)+1:]
+		resolved, err := v.GetDocumentUriFromImportPath(cwd, import_name)
+		if err != nil || path.Clean(uri.URI(resolved).Filename()) != filename {
+			return
+		}
+		if rank > bestRank || rank == bestRank && len(import_name) < len(best) {
+			best, bestRank = import_name, rank
+		}
+	}
+	pos := path.Dir(uri.URI(cwd).Filename())
+	for path.Clean(pos) != "/" {
+		pos = path.Clean(pos)
+		rank := 0
+		if roots[pos] {
+			rank = 1
+		}
+		consider(pos, rank)
+		for _, additionalProtoDir := range additional_proto_dirs {
+			consider(path.Join(pos, additionalProtoDir), 2)
 		}
 		pos = path.Join(pos, "..")
 	}
-	return res, fmt.Errorf("%w: import %s", ErrNotFound, import_name)
+	if bestRank == -1 {
+		return "", fmt.Errorf("%w: no import path for %s", ErrNotFound, document_uri)
+	}
+	return best, nil
 }
 
 func toUtf8(iso8859_1_buf []byte) []byte {
@@ -326,8 +510,8 @@ func didOpen(ctx context.Context, params *defines.DidOpenTextDocumentParams) err
 		return nil
 	}
 
-	if IsPbHeader(params.TextDocument.Uri) {
-		ViewManager.didOpenPbHeader(params.TextDocument.Uri, params.TextDocument.Text)
+	if IsGeneratedFile(params.TextDocument.Uri) {
+		ViewManager.didOpenGenerated(params.TextDocument.Uri, params.TextDocument.Text)
 	}
 	return nil
 }
@@ -349,6 +533,10 @@ func didChange(ctx context.Context, params *defines.DidChangeTextDocumentParams)
 }
 
 func didClose(ctx context.Context, params *defines.DidCloseTextDocumentParams) error {
+	if IsGeneratedFile(params.TextDocument.Uri) {
+		ViewManager.didCloseGenerated(params.TextDocument.Uri)
+		return nil
+	}
 	if !IsProtoFile(params.TextDocument.Uri) {
 		return nil
 	}
@@ -357,6 +545,7 @@ func didClose(ctx context.Context, params *defines.DidCloseTextDocumentParams) e
 
 	ViewManager.didClose(document_uri)
 	ViewManager.setContent(ctx, document_uri, nil)
+	forgetFile(document_uri)
 
 	return nil
 }
@@ -373,7 +562,44 @@ func didSave(_ context.Context, params *defines.DidSaveTextDocumentParams) error
 	return nil
 }
 
+func onInitialize(ctx context.Context, req *defines.InitializeParams) (*defines.InitializeResult, *defines.InitializeError) {
+	ViewManager.setWorkspaceRoots(workspaceRootsFromParams(req))
+	ViewManager.settingsMu.Lock()
+	ViewManager.snippetSupport = snippetSupportFromParams(req)
+	ViewManager.settingsMu.Unlock()
+	res, err := ViewManager.Server.DefaultInitialize(ctx, req)
+	if err != nil {
+		logs.Printf("initialize err:%v", err)
+		return nil, &defines.InitializeError{}
+	}
+	return res, nil
+}
+
+// snippetSupportFromParams reports whether the client advertises
+// textDocument.completion.completionItem.snippetSupport.
+func snippetSupportFromParams(params *defines.InitializeParams) bool {
+	textDocument := params.Capabilities.TextDocument
+	if textDocument == nil || textDocument.Completion == nil {
+		return false
+	}
+	completionItem, ok := textDocument.Completion.CompletionItem.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	snippetSupport, _ := completionItem["snippetSupport"].(bool)
+	return snippetSupport
+}
+
+// SnippetSupport reports whether completion items may be snippets.
+func (v *view) SnippetSupport() bool {
+	v.settingsMu.RLock()
+	defer v.settingsMu.RUnlock()
+
+	return v.snippetSupport
+}
+
 func onInitialized(ctx context.Context, req *defines.InitializeParams) (err error) {
+	go ViewManager.scanWorkspace(ViewManager.Settings())
 	return nil
 }
 
@@ -385,7 +611,9 @@ func onDidChangeConfiguration(ctx context.Context, req *defines.DidChangeConfigu
 	if err != nil {
 		return err
 	}
-	ViewManager.settings = *settings
+	ViewManager.setSettings(*settings)
+	// additional proto dirs may have changed
+	go ViewManager.scanWorkspace(*settings)
 	return nil
 }
 
@@ -394,18 +622,23 @@ func Init(server *lsp.Server) {
 	ViewManager = newView()
 	ViewManager.Server = server
 
+	server.OnInitialize(onInitialize)
 	server.OnInitialized(onInitialized)
 	server.OnDidChangeConfiguration(onDidChangeConfiguration)
 	server.OnDidOpenTextDocument(didOpen)
 	server.OnDidChangeTextDocument(didChange)
 	server.OnDidCloseTextDocument(didClose)
 	server.OnDidSaveTextDocument(didSave)
+	server.OnDidChangeWatchedFiles(didChangeWatchedFiles)
 }
 
-func IsProtoFile(document_uri defines.DocumentUri) bool {
-	return strings.HasSuffix(string(document_uri), ".proto")
+// InitFS sets ViewManager up without a client, looking files up in
+// file_system. The packages built on the view use it in their tests.
+func InitFS(file_system fs.FS) {
+	ViewManager = newView()
+	ViewManager.fs = file_system
 }
 
-func IsPbHeader(document_uri defines.DocumentUri) bool {
-	return strings.HasSuffix(string(document_uri), ".pb.h")
+func IsProtoFile(document_uri defines.DocumentUri) bool {
+	return strings.HasSuffix(string(document_uri), ".proto")
 }
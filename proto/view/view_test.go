@@ -2,10 +2,14 @@ package view
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/lasorda/protobuf-language-server/go-lsp/logs"
 	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
 	"github.com/stretchr/testify/require"
+	"go.lsp.dev/uri"
 )
 
 func Test_view_GetDocumentUriFromImportPath(t *testing.T) {
@@ -18,6 +22,7 @@ func Test_view_GetDocumentUriFromImportPath(t *testing.T) {
 		cwd           defines.DocumentUri
 		import_name   string
 		want          defines.DocumentUri
+		wantRoot      string
 		wantErr       error
 	}{
 		{
@@ -29,8 +34,9 @@ func Test_view_GetDocumentUriFromImportPath(t *testing.T) {
 			cwd:         defines.DocumentUri("file:///project-dir/api/my-service.proto"),
 			import_name: "google/protobuf/empty.proto",
 
-			want:    defines.DocumentUri("file:///project-dir/google/protobuf/empty.proto"),
-			wantErr: nil,
+			want:     defines.DocumentUri("file:///project-dir/google/protobuf/empty.proto"),
+			wantRoot: "/project-dir",
+			wantErr:  nil,
 		},
 		{
 			name: "import is not found when it's in some sub-directory",
@@ -56,8 +62,9 @@ func Test_view_GetDocumentUriFromImportPath(t *testing.T) {
 			cwd:         defines.DocumentUri("file:///project-dir/api/my-service.proto"),
 			import_name: "google/protobuf/empty.proto",
 
-			want:    defines.DocumentUri("file:///project-dir/protobuf-dependencies/google/protobuf/empty.proto"),
-			wantErr: nil,
+			want:     defines.DocumentUri("file:///project-dir/protobuf-dependencies/google/protobuf/empty.proto"),
+			wantRoot: "/project-dir/protobuf-dependencies",
+			wantErr:  nil,
 		},
 	}
 	for i, tt := range tests {
@@ -69,6 +76,171 @@ func Test_view_GetDocumentUriFromImportPath(t *testing.T) {
 			got, err := v.GetDocumentUriFromImportPath(tt.cwd, tt.import_name)
 			require.ErrorIs(t, err, tt.wantErr)
 			require.Equal(t, tt.want, got)
+
+			got, root, err := v.ResolveImport(tt.cwd, tt.import_name)
+			require.ErrorIs(t, err, tt.wantErr)
+			require.Equal(t, tt.want, got)
+			require.Equal(t, tt.wantRoot, root)
+		})
+	}
+}
+
+func Test_view_ImportPath(t *testing.T) {
+	tests := []struct {
+		name          string
+		existingFiles []string
+		settings      Settings
+		roots         []string
+		document_uri  defines.DocumentUri
+		want          string
+		wantErr       error
+	}{
+		{
+			name: "path is relative to the workspace root",
+			existingFiles: []string{
+				"/project-dir/api/v1/my-service.proto",
+				"/project-dir/api/v1/types.proto",
+			},
+			roots:        []string{"/project-dir"},
+			document_uri: "file:///project-dir/api/v1/types.proto",
+			want:         "api/v1/types.proto",
+		},
+		{
+			name: "shortest path without workspace roots",
+			existingFiles: []string{
+				"/project-dir/api/v1/my-service.proto",
+				"/project-dir/api/v1/types.proto",
+			},
+			document_uri: "file:///project-dir/api/v1/types.proto",
+			want:         "types.proto",
+		},
+		{
+			name: "additional proto dirs are preferred",
+			existingFiles: []string{
+				"/project-dir/api/v1/my-service.proto",
+				"/project-dir/protobuf-dependencies/google/protobuf/empty.proto",
+			},
+			settings: Settings{
+				AdditionalProtoDirs: []string{"protobuf-dependencies"},
+			},
+			roots:        []string{"/project-dir"},
+			document_uri: "file:///project-dir/protobuf-dependencies/google/protobuf/empty.proto",
+			want:         "google/protobuf/empty.proto",
+		},
+		{
+			name: "files outside the import roots cannot be imported",
+			existingFiles: []string{
+				"/project-dir/api/v1/my-service.proto",
+				"/other-dir/types.proto",
+			},
+			document_uri: "file:///other-dir/types.proto",
+			wantErr:      ErrNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &view{fs: &MockFS{ExistingFiles: tt.existingFiles}, settings: tt.settings, workspace: newWorkspace()}
+			v.setWorkspaceRoots(tt.roots)
+
+			got, err := v.ImportPath("file:///project-dir/api/v1/my-service.proto", tt.document_uri)
+			require.ErrorIs(t, err, tt.wantErr)
+			require.Equal(t, tt.want, got)
 		})
 	}
 }
+
+func Test_view_ImportPathEntries(t *testing.T) {
+	v := &view{
+		fs: &MockFS{ExistingFiles: []string{
+			"/home/other.proto",
+			"/home/project-dir/api/v1/my-service.proto",
+			"/home/project-dir/api/v1/types.proto",
+			"/home/project-dir/api/v1/README.md",
+			"/home/project-dir/api/v1/.git/config",
+			"/home/project-dir/deps/google/protobuf/empty.proto",
+			"/home/project-dir/deps/api/v1/types.proto",
+			"/home/deps/google/protobuf/any.proto",
+		}},
+		settings:  Settings{AdditionalProtoDirs: []string{"deps"}},
+		workspace: newWorkspace(),
+	}
+	v.setWorkspaceRoots([]string{"/home/project-dir"})
+	cwd := defines.DocumentUri("file:///home/project-dir/api/v1/my-service.proto")
+
+	names := func(dir string) (res []string) {
+		for _, entry := range v.ImportPathEntries(cwd, dir) {
+			name := entry.Name
+			if entry.IsDir {
+				name += "/"
+			}
+			res = append(res, name+" "+entry.Root)
+		}
+		return res
+	}
+	require.Equal(t, []string{
+		"my-service.proto /home/project-dir/api/v1",
+		"types.proto /home/project-dir/api/v1",
+		"v1/ /home/project-dir/api",
+		"api/ /home/project-dir",
+		"deps/ /home/project-dir",
+		"google/ /home/project-dir/deps",
+	}, names(""))
+	// the project's api/v1/types.proto shadows the one under deps
+	require.Equal(t, []string{
+		"my-service.proto /home/project-dir",
+		"types.proto /home/project-dir",
+	}, names("api/v1"))
+	// deps above the workspace root are searched, the parent itself is not
+	require.Equal(t, []string{
+		"empty.proto /home/project-dir/deps",
+		"any.proto /home/deps",
+	}, names("google/protobuf"))
+	require.Equal(t, defines.DocumentUri("file:///home/deps/google/protobuf/any.proto"), v.ImportPathEntries(cwd, "google/protobuf")[1].DocumentUri)
+}
+
+func Test_view_WorkspaceFiles(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "a.proto")
+	require.NoError(t, os.WriteFile(filename, []byte(`syntax = "proto3";`), 0o644))
+	document_uri := defines.DocumentUri("file://" + filename)
+
+	// an indexed file is parsed without publishing diagnostics, there is no client
+	v := newView()
+	v.workspace.add(document_uri)
+	files := v.WorkspaceFiles()
+	require.Len(t, files, 1)
+	require.Equal(t, document_uri, files[0].URI())
+}
+
+func Test_view_scanWorkspace(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"ws/svc/a.proto",
+		"ws/svc/.deps/dep.proto",
+		"ws/.git/ignored.proto",
+		"vendor-protos/vendor.proto",
+		"shared/shared.proto",
+	} {
+		filename := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(filename), 0o755))
+		require.NoError(t, os.WriteFile(filename, []byte(`syntax = "proto3";`), 0o644))
+	}
+	logs.Init(nil)
+	v := newView()
+	v.setWorkspaceRoots([]string{filepath.Join(dir, "ws")})
+	// additional proto dirs are indexed under nested dirs and ancestors of
+	// the root, where imports resolve in them
+	v.scanWorkspace(Settings{AdditionalProtoDirs: []string{".deps", "vendor-protos", "../shared", ".."}})
+
+	var got []string
+	for document_uri := range v.workspace.files {
+		rel, err := filepath.Rel(dir, uri.URI(document_uri).Filename())
+		require.NoError(t, err)
+		got = append(got, rel)
+	}
+	require.ElementsMatch(t, []string{
+		"ws/svc/a.proto",
+		"ws/svc/.deps/dep.proto",
+		"vendor-protos/vendor.proto",
+		"shared/shared.proto",
+	}, got)
+}
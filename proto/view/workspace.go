@@ -0,0 +1,336 @@
+package view
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/lasorda/protobuf-language-server/go-lsp/logs"
+	"github.com/lasorda/protobuf-language-server/go-lsp/lsp/defines"
+	"go.lsp.dev/uri"
+)
+
+// workspace keeps track of every proto file under the workspace roots and
+// the additional proto dirs, not only the ones reached from open documents.
+type workspace struct {
+	roots []string
+	files map[defines.DocumentUri]bool
+	mu    *sync.RWMutex
+
+	// serializes scans, a configuration change may start one while another runs
+	scanMu *sync.Mutex
+}
+
+func newWorkspace() *workspace {
+	return &workspace{
+		files:  make(map[defines.DocumentUri]bool),
+		mu:     &sync.RWMutex{},
+		scanMu: &sync.Mutex{},
+	}
+}
+
+func (w *workspace) add(document_uri defines.DocumentUri) {
+	w.mu.Lock()
+	w.files[document_uri] = true
+	w.mu.Unlock()
+}
+
+// workspaceRootsFromParams returns the workspace folders of the client,
+// falling back to rootUri and rootPath for clients without folder support.
+func workspaceRootsFromParams(params *defines.InitializeParams) (roots []string) {
+	if folders, ok := params.WorkspaceFolders.([]interface{}); ok {
+		for _, folder := range folders {
+			f, ok := folder.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if folderUri, ok := f["uri"].(string); ok && folderUri != "" {
+				roots = append(roots, uri.URI(folderUri).Filename())
+			}
+		}
+	}
+	if len(roots) > 0 {
+		return roots
+	}
+	if rootUri, ok := params.RootUri.(string); ok && rootUri != "" {
+		return []string{uri.URI(rootUri).Filename()}
+	}
+	if rootPath, ok := params.RootPath.(string); ok && rootPath != "" {
+		return []string{rootPath}
+	}
+	return nil
+}
+
+func (v *view) setWorkspaceRoots(roots []string) {
+	v.workspace.mu.Lock()
+	v.workspace.roots = roots
+	v.workspace.mu.Unlock()
+}
+
+// indexDirs returns the directories to scan first, each workspace root and,
+// like importRoots, the additional proto dirs of settings under the root and
+// under each of its ancestors.
+func (v *view) indexDirs(settings Settings) (dirs []string) {
+	v.workspace.mu.RLock()
+	roots := v.workspace.roots
+	v.workspace.mu.RUnlock()
+
+	for _, root := range roots {
+		root = filepath.Clean(root)
+		dirs = append(dirs, root)
+		for pos := root; pos != filepath.Dir(pos); pos = filepath.Dir(pos) {
+			dirs = append(dirs, additionalDirs(root, pos, settings)...)
+		}
+	}
+	return dirs
+}
+
+// additionalDirs returns the additional proto dirs of settings under pos
+// which scanning dir does not reach. Those containing dir, like "..", are
+// left out, they would scan everything above it.
+func additionalDirs(dir, pos string, settings Settings) (res []string) {
+	for _, additionalProtoDir := range settings.AdditionalProtoDirs {
+		additional := filepath.Join(pos, additionalProtoDir)
+		if !scanReaches(dir, additional) && !isSubDir(additional, dir) {
+			res = append(res, additional)
+		}
+	}
+	return res
+}
+
+// scanReaches reports whether scanning dir walks into sub.
+func scanReaches(dir, sub string) bool {
+	if !isSubDir(dir, sub) {
+		return false
+	}
+	rel, err := filepath.Rel(dir, sub)
+	if err != nil || rel == "." {
+		return err == nil
+	}
+	for _, name := range strings.Split(rel, string(filepath.Separator)) {
+		if skipDir(name) {
+			return false
+		}
+	}
+	return true
+}
+
+func skipDir(name string) bool {
+	return strings.HasPrefix(name, ".") || name == "node_modules"
+}
+
+func isSubDir(parent, dir string) bool {
+	rel, err := filepath.Rel(parent, dir)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// scanWorkspace indexes and parses every proto file under indexDirs and
+// under the additional proto dirs of the directories it walks, with the
+// settings it was started with. It is meant to run in the background.
+func (v *view) scanWorkspace(settings Settings) {
+	v.workspace.scanMu.Lock()
+	defer v.workspace.scanMu.Unlock()
+
+	count := 0
+	dirs := v.indexDirs(settings)
+	scanned := make(map[string]bool)
+	for len(dirs) > 0 {
+		dir := dirs[0]
+		dirs = dirs[1:]
+		if scanned[dir] {
+			continue
+		}
+		scanned[dir] = true
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				// unreadable entries are skipped, not fatal for the scan
+				return nil
+			}
+			if d.IsDir() {
+				if path != dir && skipDir(d.Name()) {
+					return filepath.SkipDir
+				}
+				// imports of the files below resolve in these too
+				dirs = append(dirs, additionalDirs(dir, path, settings)...)
+				return nil
+			}
+			if !strings.HasSuffix(path, ".proto") {
+				return nil
+			}
+			v.indexFile(defines.DocumentUri(uri.File(path)))
+			count++
+			return nil
+		})
+		if err != nil {
+			logs.Printf("scanWorkspace %v err:%v", dir, err)
+		}
+	}
+	logs.Printf("scanWorkspace indexed %d files", count)
+}
+
+// indexFile adds document_uri to the workspace and parses it unless it is loaded already.
+func (v *view) indexFile(document_uri defines.DocumentUri) {
+	v.workspace.add(document_uri)
+
+	if _, ok := v.getLoadedFile(document_uri); ok {
+		return
+	}
+	if err := v.readProtoFile(document_uri); err != nil {
+		logs.Printf("indexFile err:%v", err)
+	}
+}
+
+// reindexFile parses document_uri again after it changed on disk.
+func (v *view) reindexFile(document_uri defines.DocumentUri) {
+	v.workspace.add(document_uri)
+
+	if err := v.readProtoFile(document_uri); err != nil {
+		logs.Printf("reindexFile err:%v", err)
+	}
+}
+
+// unindexFile forgets a file which was removed from disk.
+func (v *view) unindexFile(document_uri defines.DocumentUri) {
+	v.workspace.mu.Lock()
+	delete(v.workspace.files, document_uri)
+	v.workspace.mu.Unlock()
+
+	v.imports.remove(document_uri)
+	forgetFile(document_uri)
+	if v.isOpen(document_uri) {
+		return
+	}
+	v.fileMu.Lock()
+	delete(v.filesByURI, document_uri)
+	v.fileMu.Unlock()
+}
+
+// Forgetter drops what a component keeps about a file.
+type Forgetter func(document_uri defines.DocumentUri)
+
+var (
+	forgetters   []Forgetter
+	forgettersMu = &sync.RWMutex{}
+)
+
+// RegisterForgetter adds a callback which runs every time a file is closed
+// or removed from disk.
+func RegisterForgetter(f Forgetter) {
+	forgettersMu.Lock()
+	forgetters = append(forgetters, f)
+	forgettersMu.Unlock()
+}
+
+func forgetFile(document_uri defines.DocumentUri) {
+	forgettersMu.RLock()
+	defer forgettersMu.RUnlock()
+
+	for _, f := range forgetters {
+		f(document_uri)
+	}
+}
+
+// InWorkspace reports whether document_uri lives under a workspace root.
+// Without roots only the well-known types count as outside.
+func (v *view) InWorkspace(document_uri defines.DocumentUri) bool {
+	v.workspace.mu.RLock()
+	roots := v.workspace.roots
+	v.workspace.mu.RUnlock()
+
+	filename := uri.URI(document_uri).Filename()
+	if len(roots) == 0 {
+		return !strings.Contains(filename, "/google/protobuf/")
+	}
+	for _, root := range roots {
+		if isSubDir(root, filename) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsThirdParty reports whether document_uri is a dependency rather than a
+// file of the project, it is outside the workspace or under an additional
+// proto dir.
+func (v *view) IsThirdParty(document_uri defines.DocumentUri) bool {
+	if !v.InWorkspace(document_uri) {
+		return true
+	}
+	v.workspace.mu.RLock()
+	roots := v.workspace.roots
+	v.workspace.mu.RUnlock()
+
+	filename := uri.URI(document_uri).Filename()
+	additional_proto_dirs := v.Settings().AdditionalProtoDirs
+	for _, root := range roots {
+		for _, additionalProtoDir := range additional_proto_dirs {
+			if isSubDir(filepath.Join(root, additionalProtoDir), filename) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// WorkspaceFiles returns every indexed file and every open file, sorted by uri.
+func (v *view) WorkspaceFiles() (res []ProtoFile) {
+	uris := make(map[defines.DocumentUri]bool)
+
+	v.workspace.mu.RLock()
+	for document_uri := range v.workspace.files {
+		uris[document_uri] = true
+	}
+	v.workspace.mu.RUnlock()
+
+	v.openFileMu.RLock()
+	for document_uri := range v.openFiles {
+		uris[document_uri] = true
+	}
+	v.openFileMu.RUnlock()
+
+	sorted := make([]string, 0, len(uris))
+	for document_uri := range uris {
+		sorted = append(sorted, string(document_uri))
+	}
+	sort.Strings(sorted)
+
+	// files the user did not open are not diagnosed
+	for _, document_uri := range sorted {
+		f, err := v.PeekFile(defines.DocumentUri(document_uri))
+		if err != nil {
+			continue
+		}
+		res = append(res, f)
+	}
+	return res
+}
+
+func didChangeWatchedFiles(ctx context.Context, params *defines.DidChangeWatchedFilesParams) error {
+	created_or_deleted := false
+	for _, change := range params.Changes {
+		if !IsProtoFile(change.Uri) {
+			continue
+		}
+		switch change.Type {
+		case defines.FileChangeTypeCreated:
+			created_or_deleted = true
+			ViewManager.indexFile(change.Uri)
+		case defines.FileChangeTypeChanged:
+			// the editor owns the content of open files
+			if ViewManager.isOpen(change.Uri) {
+				continue
+			}
+			ViewManager.reindexFile(change.Uri)
+		case defines.FileChangeTypeDeleted:
+			created_or_deleted = true
+			ViewManager.unindexFile(change.Uri)
+		}
+	}
+	if created_or_deleted {
+		ViewManager.refreshImports()
+	}
+	return nil
+}
@@ -1,6 +1,6 @@
 // The module 'vscode' contains the VS Code extensibility API
 // Import the module and reference it with the alias vscode in your code below
-import { ExtensionContext } from 'vscode';
+import { commands, ExtensionContext, Uri, workspace } from 'vscode';
 import {
     LanguageClient,
     LanguageClientOptions,
@@ -28,6 +28,27 @@ export function activate(context: ExtensionContext) {
     const clientOptions: LanguageClientOptions = {
         // Register the server for plain text documents
         documentSelector: [{ scheme: 'file', language: 'proto' }],
+        synchronize: {
+            // Keep the server's import index current when protos change on disk
+            fileEvents: workspace.createFileSystemWatcher('**/*.proto'),
+        },
+        middleware: {
+            // The server returns the locations counted by a code lens, show
+            // them in the references view
+            executeCommand: async (command, args, next) => {
+                const locations = await next(command, args);
+                if (command !== 'protobuf.showReferences' || !Array.isArray(locations)) {
+                    return locations;
+                }
+                const converter = client.protocol2CodeConverter;
+                await commands.executeCommand(
+                    'editor.action.showReferences',
+                    Uri.parse(args[0].uri),
+                    converter.asPosition(args[0].position),
+                    await converter.asLocations(locations),
+                );
+            },
+        },
     };
 
     // Create the language client and start the client.